@@ -12,6 +12,6 @@ func main() {
 	cmd := cli.NewQueryCommand()
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -90,9 +90,9 @@ func Test01_DNSLookupAllServers(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+	if resp.StatusCode != http.StatusAccepted {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		t.Fatalf("Expected 200/202, got %d. Body: %s", resp.StatusCode, string(bodyBytes))
+		t.Fatalf("Expected 202, got %d. Body: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	var lookupResp map[string]interface{}
@@ -209,7 +209,7 @@ func pollForTaskResult(t *testing.T, apiURL, taskID string) models.TaskStatusRes
 		t.Logf("Task status: %s", lastResult.Status)
 
 		// Check if task is completed
-		if lastResult.Status == "SUCCESS" || lastResult.Status == "FAILED" || lastResult.Status == "FAILURE" {
+		if models.IsFinalTaskStatus(lastResult.Status) {
 			return lastResult
 		}
 
@@ -320,7 +320,7 @@ func Test03_RateLimiting(t *testing.T) {
 		statusCode := resp.StatusCode
 		resp.Body.Close()
 
-		if statusCode == http.StatusOK || statusCode == http.StatusAccepted {
+		if statusCode == http.StatusAccepted {
 			successCount++
 		} else if statusCode == http.StatusTooManyRequests {
 			t.Logf("✅ Rate limit triggered at request %d (after %d successful requests)", i+1, successCount)
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// APIKeyHeader carries the API key when auth.api_keys is configured.
+const APIKeyHeader = "X-API-Key"
+
+// publicPaths stay reachable without a key so probes and scrapers keep working.
+var publicPaths = map[string]bool{
+	"/health":  true,
+	"/status":  true,
+	"/livez":   true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// apiKeyAuth rejects requests without a configured key; disabled when no keys are set.
+// Keys are read from the live config so a SIGHUP reload rotates them.
+func (s *Server) apiKeyAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := s.Config().Auth.APIKeys
+		if len(keys) == 0 || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := requestAPIKey(r)
+		if key == "" {
+			respondError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "missing API key")
+			return
+		}
+		if !validAPIKey(keys, key) {
+			respondError(w, http.StatusUnauthorized, models.ErrCodeUnauthorized, "invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestAPIKey reads X-API-Key, falling back to an Authorization Bearer token.
+func requestAPIKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// validAPIKey compares in constant time to avoid leaking key prefixes through timing.
+func validAPIKey(keys []string, key string) bool {
+	valid := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -21,33 +22,42 @@ type Client struct {
 }
 
 // NewClient configures HTTP client with optional TLS verification skip.
+// A unix:///path/to.sock base URL reaches a co-located server over that Unix domain socket.
 func NewClient(baseURL string, timeout time.Duration, insecure bool) *Client {
 	tr := &http.Transport{}
 	if insecure {
 		//nolint:gosec
 		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	if socket, ok := strings.CutPrefix(baseURL, unixScheme); ok {
+		var dialer net.Dialer
+		tr.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		// Requests still need an HTTP URL; its host only fills the Host header
+		baseURL = "http://unix"
+	}
 	return &Client{
-		baseURL: strings.TrimRight(baseURL, "/"),
+		baseURL: baseURL,
 		hc:      &http.Client{Timeout: timeout, Transport: tr},
 	}
 }
 
+// unixScheme prefixes API URLs that name a Unix domain socket.
+const unixScheme = "unix://"
+
 // EnqueueDNSLookup posts DNS lookup request to API.
 func (c *Client) EnqueueDNSLookup(ctx context.Context, req models.DNSLookupRequest) (string, error) {
 	return c.postTask(ctx, "/dns-lookup", req)
 }
 
 // EnqueueReverseLookup wraps reverse IP to PTR lookup for Python dnstester compat.
-func (c *Client) EnqueueReverseLookup(ctx context.Context, reverseIP string, servers []models.DNSServer, skipVerify bool) (string, error) {
-	req := struct {
-		ReverseIP             string             `json:"reverse_ip"`
-		DNSServers            []models.DNSServer `json:"dns_servers,omitempty"`
-		TLSInsecureSkipVerify bool               `json:"tls_insecure_skip_verify,omitempty"`
-	}{
-		ReverseIP:             reverseIP,
-		DNSServers:            servers,
-		TLSInsecureSkipVerify: skipVerify,
+func (c *Client) EnqueueReverseLookup(ctx context.Context, reverseIP string, servers []models.DNSServer, opts models.QueryOptions) (string, error) {
+	req := models.ReverseLookupRequest{
+		ReverseIP:    reverseIP,
+		DNSServers:   servers,
+		QueryOptions: opts,
 	}
 	return c.postTask(ctx, "/reverse-lookup", req)
 }
@@ -71,7 +81,8 @@ func (c *Client) postTask(ctx context.Context, path string, payload interface{})
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	if resp.StatusCode != http.StatusOK {
+	// 202 since the API started answering with Accepted; older servers reply 200
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("api error: %s", string(body))
 	}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestClientUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets unavailable: %v", err)
+	}
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/tasks/"+mockTaskID {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(models.TaskStatusResponse{TaskID: mockTaskID, Status: "SUCCESS"})
+	}))
+	_ = srv.Listener.Close()
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	client := NewClient("unix://"+socket+"/", 5*time.Second, false)
+	status, err := client.GetTaskStatus(context.Background(), mockTaskID)
+	if err != nil {
+		t.Fatalf("GetTaskStatus over the socket failed: %v", err)
+	}
+	if status.TaskID != mockTaskID || status.Status != "SUCCESS" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+}
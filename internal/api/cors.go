@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+)
+
+// corsMiddleware answers preflights and sets Access-Control-* headers for allowed origins.
+// Requests from other origins pass through without CORS headers, so browsers block them.
+func corsMiddleware(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	methods := strings.Join(cfg.GetAllowedMethods(), ", ")
+	headers := strings.Join(cfg.GetAllowedHeaders(), ", ")
+	wildcard := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Origin")
+			if !wildcard && !slices.Contains(cfg.AllowedOrigins, origin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if wildcard {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+			}
+			w.Header().Set("Access-Control-Expose-Headers", RequestIDHeader+", ETag")
+
+			// Preflight never reaches the router (or auth): browsers send it without credentials
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				w.Header().Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
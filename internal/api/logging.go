@@ -0,0 +1,50 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// requestIDHeader echoes the ID from middleware.RequestID in the X-Request-ID response header.
+func requestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLogger logs one structured line per request through slog, so access logs
+// follow the configured text or JSON format. Must run after middleware.RequestID.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", status,
+				"duration", time.Since(start),
+				"bytes", ww.BytesWritten(),
+				"remote_addr", r.RemoteAddr,
+				"request_id", middleware.GetReqID(r.Context()),
+			}
+			if name := clientCertName(r); name != "" {
+				attrs = append(attrs, "client_cert", name)
+			}
+			slog.Info("HTTP request", attrs...)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/didip/tollbooth/v8"
+	"github.com/didip/tollbooth/v8/limiter"
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// rateLimitMiddleware limits anonymous requests per IP and requests with a valid API key
+// per key, at rate_limiting.keyed_rps. Invalid keys fall back to the IP limit so rotating
+// made-up keys cannot bypass it. Keys are read from the live config like apiKeyAuth.
+func (s *Server) rateLimitMiddleware(cfg *config.APIConfig) func(http.Handler) http.Handler {
+	ipLmt := newLimiter(cfg.GetRateLimitRequestsPerSecond(), cfg.GetRateLimitBurstSize())
+
+	// Configurable IP source (RemoteAddr, X-Forwarded-For, etc.)
+	ipSource := os.Getenv("RATE_LIMIT_IP_SOURCE")
+	if ipSource == "" {
+		ipSource = "RemoteAddr"
+	}
+	ipLmt.SetIPLookup(limiter.IPLookup{Name: ipSource, IndexFromRight: 0})
+
+	// A keyed burst below the keyed rate would throttle trusted clients under the IP tier
+	keyedRPS := cfg.GetRateLimitKeyedRequestsPerSecond()
+	keyedLmt := newLimiter(keyedRPS, max(cfg.GetRateLimitBurstSize(), keyedRPS))
+
+	return func(next http.Handler) http.Handler {
+		limitByIP := tollbooth.HTTPMiddleware(ipLmt)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := requestAPIKey(r)
+			if key == "" || !validAPIKey(s.Config().Auth.APIKeys, key) {
+				limitByIP.ServeHTTP(w, r)
+				return
+			}
+
+			if httpErr := tollbooth.LimitByKeys(keyedLmt, []string{"api_key", key}); httpErr != nil {
+				keyedLmt.ExecOnLimitReached(w, r)
+				w.Header().Set("Content-Type", keyedLmt.GetMessageContentType())
+				w.WriteHeader(httpErr.StatusCode)
+				_, _ = w.Write([]byte(httpErr.Message))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// newLimiter builds a tollbooth limiter answering 429 with a JSON body and Retry-After.
+func newLimiter(rps, burst int) *limiter.Limiter {
+	lmt := tollbooth.NewLimiter(float64(rps), &limiter.ExpirableOptions{DefaultExpirationTTL: 10 * time.Minute})
+	lmt.SetBurst(burst)
+	lmt.SetMessage(`{"error":"rate limit exceeded","code":"` + models.ErrCodeRateLimited + `"}`)
+	lmt.SetMessageContentType("application/json")
+
+	// Runs before the 429 is written, so the header makes it into the response
+	retryAfter := retryAfterSeconds(float64(rps))
+	lmt.SetOnLimitReached(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Retry-After", retryAfter)
+	})
+	return lmt
+}
+
+// retryAfterSeconds is the time for one token to refill, rounded up to whole seconds.
+func retryAfterSeconds(rps float64) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(1/rps))))
+}
@@ -4,14 +4,24 @@ package api
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/didip/tollbooth/v8"
-	"github.com/didip/tollbooth/v8/limiter"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -20,7 +30,11 @@ import (
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
 	"github.com/sudo-tiz/dns-tester-go/internal/tasks"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
+	"github.com/sudo-tiz/dns-tester-go/internal/version"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	_ "github.com/sudo-tiz/dns-tester-go/internal/api/docs" // swagger docs
 )
@@ -28,53 +42,77 @@ import (
 // APIVersion is the current version of the API
 const APIVersion = "1.0.0"
 
+// QueueHeader selects the Asynq queue a lookup is routed to (validated against worker.queues).
+const QueueHeader = "X-Queue"
+
+// RequestIDHeader echoes the request correlation ID so clients can match server logs.
+const RequestIDHeader = "X-Request-ID"
+
 // Server wraps chi router with task queue client for async DNS lookups.
+// Config sits behind an atomic pointer so SIGHUP reloads can swap it under live traffic.
 type Server struct {
 	router      *chi.Mux
-	config      *config.APIConfig
+	config      atomic.Pointer[config.APIConfig]
 	tasksClient tasks.ClientInterface
+	// streamInterval is how often streams and long-polls check task status when set;
+	// tests set it to avoid waiting DefaultStreamInterval
+	streamInterval time.Duration
+
+	// HTTP servers started by Run and its Unix socket, released by Shutdown
+	mu          sync.Mutex
+	httpServers []*http.Server
+	socket      string
+	// TLS certificate and client CAs served by Run, swapped by ReloadCertificate
+	cert      atomic.Pointer[tls.Certificate]
+	clientCAs atomic.Pointer[x509.CertPool]
 }
 
-// NewServer configures middleware stack: tollbooth, chi logging, panic recovery.
+// NewServer configures middleware stack: tollbooth, slog access logging, panic recovery.
 func NewServer(cfg *config.APIConfig) *Server {
-	s := &Server{router: chi.NewRouter(), config: cfg}
-
-	// Tollbooth rate limiter with configurable IP source (RemoteAddr, X-Forwarded-For, etc.)
-	// Only enable if RequestsPerSecond > 0 (0 = disabled)
-	if cfg.RateLimiting.RequestsPerSecond > 0 {
-		lmt := tollbooth.NewLimiter(
-			float64(cfg.GetRateLimitRequestsPerSecond()),
-			&limiter.ExpirableOptions{DefaultExpirationTTL: 10 * time.Minute},
-		)
-		lmt.SetBurst(cfg.GetRateLimitBurstSize())
-
-		ipSource := os.Getenv("RATE_LIMIT_IP_SOURCE")
-		if ipSource == "" {
-			ipSource = "RemoteAddr"
-		}
-		lmt.SetIPLookup(limiter.IPLookup{Name: ipSource, IndexFromRight: 0})
-		lmt.SetMessage(`{"error":"rate limit exceeded"}`)
-		lmt.SetMessageContentType("application/json")
+	s := &Server{router: chi.NewRouter()}
+	s.config.Store(cfg)
 
-		s.router.Use(func(next http.Handler) http.Handler {
-			return tollbooth.HTTPMiddleware(lmt)(next)
-		})
+	// Request ID ahead of everything, rate limiting included, so every response carries it
+	s.router.Use(middleware.RequestID)
+	s.router.Use(requestIDHeader)
+
+	// Tollbooth rate limiting, only if a rate is set (0 = disabled)
+	if cfg.RateLimiting.Enabled() {
+		s.router.Use(s.rateLimitMiddleware(cfg))
 	}
 
-	// Chi middleware for logging, recovery, request ID, real IP
-	s.router.Use(middleware.Logger)
-	s.router.Use(middleware.Recoverer)
-	s.router.Use(middleware.RequestID)
+	// Real IP before the slog access log so it can report it
 	s.router.Use(middleware.RealIP)
+	s.router.Use(requestLogger)
+	s.router.Use(middleware.Recoverer)
+	// Only JSON is compressed: promhttp negotiates gzip for /metrics on its own
+	if level := cfg.GetServerCompressionLevel(); level > 0 {
+		s.router.Use(middleware.Compress(level, "application/json"))
+	}
+	// CORS is fixed at startup like rate limiting; it must run before auth to answer preflights
+	if cfg.CORS.Enabled() {
+		s.router.Use(corsMiddleware(cfg.CORS))
+	}
+	s.router.Use(s.apiKeyAuth)
 
 	s.router.Post("/dns-lookup", s.handleDNSLookup)
 	s.router.Post("/reverse-lookup", s.handleReverseLookup)
+	s.router.Post("/reverse-lookup/range", s.handleReverseRange)
+	s.router.Get("/tasks", s.handleListTasks)
 	s.router.Get("/tasks/{taskID}", s.handleGetTaskStatus)
+	s.router.Get("/tasks/{taskID}/stream", s.handleTaskStream)
+	s.router.Get("/servers", s.handleListServers)
 	s.router.Get("/health", s.handleHealthCheck)
 	s.router.Head("/health", s.handleHealthCheck)
 	s.router.Get("/status", s.handleHealthCheck) // Python dnstester compat
 	s.router.Head("/status", s.handleHealthCheck)
+	s.router.Get("/livez", s.handleLiveness)
+	s.router.Head("/livez", s.handleLiveness)
+	s.router.Get("/readyz", s.handleReadiness)
+	s.router.Head("/readyz", s.handleReadiness)
 	s.router.Get("/metrics", s.handleMetrics)
+	s.router.Get("/version", s.handleVersion)
+	s.router.Get("/config", s.handleConfig)
 
 	// Swagger UI and OpenAPI endpoints
 	s.router.Get("/docs", func(w http.ResponseWriter, r *http.Request) {
@@ -92,42 +130,174 @@ func NewServer(cfg *config.APIConfig) *Server {
 // SetTasksClient injects task queue client (Asynq or in-memory).
 func (s *Server) SetTasksClient(c tasks.ClientInterface) { s.tasksClient = c }
 
+// Config returns the config currently used to serve requests.
+func (s *Server) Config() *config.APIConfig { return s.config.Load() }
+
+// SetConfig atomically swaps the config; in-flight requests keep the one they started with.
+// Rate limiting and HTTP timeouts are fixed at startup and not affected.
+func (s *Server) SetConfig(cfg *config.APIConfig) { s.config.Store(cfg) }
+
 // Router exposes chi.Mux for testing.
 func (s *Server) Router() http.Handler { return s.router }
 
-// Run starts HTTP server with config-driven timeouts.
-func (s *Server) Run(addr string) error {
+// Run starts HTTP server with config-driven timeouts on the TCP addr and, when socket is set,
+// on that Unix domain socket too; an empty addr serves the socket only. It blocks until a
+// listener fails, returning its error, or until Shutdown, returning nil.
+// With server.tls_cert and server.tls_key set the TCP addr serves HTTPS (the socket stays
+// plain HTTP), plus a redirect to it on server.http_redirect_port when that is set, and
+// requires client certificates signed by server.tls_client_ca when that is set.
+func (s *Server) Run(addr, socket string) error {
+	if addr == "" && socket == "" {
+		return errors.New("no TCP address or unix socket to listen on")
+	}
+	cfg := s.Config()
+	if (cfg.Server.TLSCert == "") != (cfg.Server.TLSKey == "") {
+		return errors.New("server.tls_cert and server.tls_key must be set together")
+	}
+	if cfg.Server.TLSClientCA != "" && !cfg.Server.TLSEnabled() {
+		// Fail closed rather than serving without the client certificates it asks for
+		return errors.New("server.tls_client_ca requires server.tls_cert and server.tls_key")
+	}
+
+	var ln, redirectLn net.Listener
+	if addr != "" {
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+		if port := cfg.Server.HTTPRedirectPort; port != "" && cfg.Server.TLSEnabled() {
+			host, _, _ := net.SplitHostPort(addr)
+			if redirectLn, err = net.Listen("tcp", net.JoinHostPort(host, port)); err != nil {
+				_ = ln.Close()
+				return err
+			}
+		}
+	}
+	return s.serve(ln, redirectLn, socket)
+}
+
+// serve runs Run's servers on ln (nil for none), the HTTPS redirect on redirectLn (nil for
+// none) and the Unix socket when set. Listeners it does not get to serve on are closed.
+func (s *Server) serve(ln, redirectLn net.Listener, socket string) error {
+	closeListeners := func() {
+		for _, l := range []net.Listener{ln, redirectLn} {
+			if l != nil {
+				_ = l.Close()
+			}
+		}
+	}
+	cfg := s.Config()
+	useTLS := ln != nil && cfg.Server.TLSEnabled()
+	if useTLS {
+		if err := s.loadTLS(&cfg.Server); err != nil {
+			closeListeners()
+			return err
+		}
+	}
+	errs := make(chan error, 3)
+
+	if socket != "" {
+		unixLn, err := listenUnix(socket)
+		if err != nil {
+			closeListeners()
+			return err
+		}
+		srv := s.newHTTPServer("")
+		s.mu.Lock()
+		s.socket = socket
+		s.mu.Unlock()
+		go func() { errs <- srv.Serve(unixLn) }()
+	}
+	if ln != nil {
+		srv := s.newHTTPServer(ln.Addr().String())
+		if useTLS {
+			srv.TLSConfig = s.tlsConfig()
+			go func() { errs <- srv.ServeTLS(ln, "", "") }()
+		} else {
+			go func() { errs <- srv.Serve(ln) }()
+		}
+	}
+	if redirectLn != nil {
+		if useTLS {
+			// Redirect to the port actually bound, which differs from addr's for ":0"
+			_, tlsPort, _ := net.SplitHostPort(ln.Addr().String())
+			srv := s.newHTTPServer(redirectLn.Addr().String())
+			srv.Handler = redirectToHTTPS(tlsPort)
+			go func() { errs <- srv.Serve(redirectLn) }()
+		} else {
+			_ = redirectLn.Close()
+		}
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newHTTPServer builds an http.Server tracked for Shutdown.
+func (s *Server) newHTTPServer(addr string) *http.Server {
 	srv := &http.Server{
 		Addr:         addr,
 		Handler:      s.router,
-		ReadTimeout:  time.Duration(s.config.GetServerReadTimeout()) * time.Second,
-		WriteTimeout: time.Duration(s.config.GetServerWriteTimeout()) * time.Second,
-		IdleTimeout:  time.Duration(s.config.GetServerIdleTimeout()) * time.Second,
+		ReadTimeout:  time.Duration(s.Config().GetServerReadTimeout()) * time.Second,
+		WriteTimeout: time.Duration(s.Config().GetServerWriteTimeout()) * time.Second,
+		IdleTimeout:  time.Duration(s.Config().GetServerIdleTimeout()) * time.Second,
+	}
+	s.mu.Lock()
+	s.httpServers = append(s.httpServers, srv)
+	s.mu.Unlock()
+	return srv
+}
+
+// Shutdown stops the servers started by Run, letting in-flight requests finish until ctx
+// is done, and removes the Unix socket file. Calling it more than once is harmless.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	servers, socket := s.httpServers, s.socket
+	s.httpServers, s.socket = nil, ""
+	s.mu.Unlock()
+
+	var errs []error
+	for _, srv := range servers {
+		errs = append(errs, srv.Shutdown(ctx))
+	}
+	if socket != "" {
+		// Closing the listener unlinks it already, unless Shutdown ran before Serve
+		if err := os.Remove(socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, fmt.Errorf("remove unix socket: %w", err))
+		}
 	}
-	return srv.ListenAndServe()
+	return errors.Join(errs...)
 }
 
 // handleDNSLookup submits a DNS lookup task for asynchronous processing
 // @Summary Submit DNS lookup task
-// @Description Enqueue a DNS lookup for asynchronous processing. Returns a task ID that can be polled.
+// @Description Enqueue a DNS lookup for asynchronous processing. Returns a task ID that can be polled. Resubmitting a task_id returns the existing task.
 // @Tags DNS
 // @Accept json
 // @Produce json
 // @Param request body models.DNSLookupRequest true "DNS lookup parameters"
-// @Success 200 {object} models.TaskResponse "Task accepted and enqueued"
+// @Param X-Queue header string false "Target queue (must be listed in worker.queues)"
+// @Success 200 {object} models.DryRunResponse "dry_run: request is valid, nothing enqueued"
+// @Success 202 {object} models.TaskResponse "Task accepted and enqueued, or already submitted with this task_id"
+// @Header 202 {string} Location "/tasks/{task_id}"
 // @Failure 400 {object} models.ErrorResponse "Invalid request or missing parameters"
+// @Failure 413 {object} models.ErrorResponse "Request body too large"
 // @Failure 429 {object} models.ErrorResponse "Rate limit exceeded"
 // @Failure 503 {object} models.ErrorResponse "No workers available"
 // @Router /dns-lookup [post]
 func (s *Server) handleDNSLookup(w http.ResponseWriter, r *http.Request) {
 	var req models.DNSLookupRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request")
+	if !s.decodeBody(w, r, &req) {
 		return
 	}
 
 	metrics.APIRequestsTotal.WithLabelValues("dns-lookup").Inc()
-	s.processDNSLookup(r.Context(), w, req)
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "POST /dns-lookup")
+	defer span.End()
+	s.processDNSLookup(ctx, w, req, r.Header.Get(QueueHeader))
 }
 
 // handleReverseLookup provides legacy PTR lookup endpoint - delegates to normalize.IPToReverseDNS
@@ -137,177 +307,366 @@ func (s *Server) handleDNSLookup(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param request body models.ReverseLookupRequest true "Reverse lookup parameters"
-// @Success 200 {object} models.TaskResponse "Task accepted and enqueued"
+// @Param X-Queue header string false "Target queue (must be listed in worker.queues)"
+// @Success 200 {object} models.DryRunResponse "dry_run: request is valid, nothing enqueued"
+// @Success 202 {object} models.TaskResponse "Task accepted and enqueued"
+// @Header 202 {string} Location "/tasks/{task_id}"
 // @Failure 400 {object} models.ErrorResponse "Invalid IP address or missing parameters"
+// @Failure 413 {object} models.ErrorResponse "Request body too large"
 // @Failure 429 {object} models.ErrorResponse "Rate limit exceeded"
 // @Failure 503 {object} models.ErrorResponse "No workers available"
 // @Router /reverse-lookup [post]
 func (s *Server) handleReverseLookup(w http.ResponseWriter, r *http.Request) {
-	var oldReq struct {
-		ReverseIP             string             `json:"reverse_ip"`
-		DNSServers            []models.DNSServer `json:"dns_servers,omitempty"`
-		TLSInsecureSkipVerify bool               `json:"tls_insecure_skip_verify,omitempty"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&oldReq); err != nil {
-		respondError(w, http.StatusBadRequest, "invalid request")
+	var oldReq models.ReverseLookupRequest
+	if !s.decodeBody(w, r, &oldReq) {
 		return
 	}
 
 	reverseDomain, err := normalize.IPToReverseDNS(oldReq.ReverseIP)
 	if err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+		respondError(w, http.StatusBadRequest, models.ErrCodeInvalidIP, err.Error())
 		return
 	}
 
 	req := models.DNSLookupRequest{
-		Domain:                reverseDomain,
-		QType:                 "PTR",
-		DNSServers:            oldReq.DNSServers,
-		TLSInsecureSkipVerify: oldReq.TLSInsecureSkipVerify,
+		Domain:       reverseDomain,
+		QType:        "PTR",
+		DNSServers:   oldReq.DNSServers,
+		QueryOptions: oldReq.QueryOptions,
+		TaskOptions:  oldReq.TaskOptions,
 	}
 
-	s.processDNSLookup(r.Context(), w, req)
+	ctx, span := tracing.Tracer().Start(r.Context(), "POST /reverse-lookup")
+	defer span.End()
+	s.processDNSLookup(ctx, w, req, r.Header.Get(QueueHeader))
+}
+
+// handleReverseRange enqueues a PTR lookup for every address in a CIDR range
+// @Summary Submit reverse DNS lookups for a CIDR range
+// @Description Enqueue one PTR lookup task per address in the range, e.g. to audit reverse DNS coverage of an allocation. Ranges above dns.max_reverse_range addresses are rejected.
+// @Tags DNS
+// @Accept json
+// @Produce json
+// @Param request body models.ReverseRangeRequest true "Range lookup parameters"
+// @Param X-Queue header string false "Target queue (must be listed in worker.queues)"
+// @Success 202 {object} models.ReverseRangeResponse "Tasks accepted and enqueued"
+// @Success 207 {object} models.ReverseRangeResponse "Enqueuing failed partway; the tasks listed were enqueued and still run"
+// @Failure 400 {object} models.ErrorResponse "Invalid or oversized CIDR, or invalid parameters"
+// @Failure 413 {object} models.ErrorResponse "Request body too large"
+// @Failure 429 {object} models.ErrorResponse "Rate limit exceeded"
+// @Failure 500 {object} models.ErrorResponse "No task could be enqueued"
+// @Failure 503 {object} models.ErrorResponse "No workers available"
+// @Router /reverse-lookup/range [post]
+func (s *Server) handleReverseRange(w http.ResponseWriter, r *http.Request) {
+	var rangeReq models.ReverseRangeRequest
+	if !s.decodeBody(w, r, &rangeReq) {
+		return
+	}
+
+	metrics.APIRequestsTotal.WithLabelValues("reverse-lookup-range").Inc()
+
+	cfg := s.Config()
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(rangeReq.CIDR))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, models.ErrCodeInvalidCIDR, fmt.Sprintf("invalid cidr '%s'", rangeReq.CIDR))
+		return
+	}
+	prefix = prefix.Masked()
+	limit := cfg.GetMaxReverseRange()
+	if hostBits := prefix.Addr().BitLen() - prefix.Bits(); hostBits >= 31 || 1<<hostBits > limit {
+		respondError(w, http.StatusBadRequest, models.ErrCodeRangeTooLarge,
+			fmt.Sprintf("cidr %s is too large (maximum allowed: %d addresses)", prefix, limit))
+		return
+	}
+
+	ctx, span := tracing.Tracer().Start(r.Context(), "POST /reverse-lookup/range")
+	defer span.End()
+
+	first, _ := normalize.IPToReverseDNS(prefix.Addr().String())
+	req := models.DNSLookupRequest{
+		Domain:       first,
+		QType:        "PTR",
+		DNSServers:   rangeReq.DNSServers,
+		QueryOptions: rangeReq.QueryOptions,
+		TaskOptions:  models.TaskOptions{CallbackURL: rangeReq.CallbackURL},
+	}
+	queue := r.Header.Get(QueueHeader)
+	if status, code, err := s.prepareLookup(ctx, cfg, &req, queue); err != nil {
+		respondError(w, status, code, err.Error())
+		return
+	}
+
+	resp := models.ReverseRangeResponse{Tasks: []models.ReverseRangeTask{}}
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		domain, _ := normalize.IPToReverseDNS(addr.String())
+		id, err := s.tasksClient.EnqueueDNSLookup(ctx, domain, req.QType, req.DNSServers, req.QueryOptions, req.TaskOptions, queue)
+		if err != nil && len(resp.Tasks) == 0 {
+			respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, fmt.Sprintf("failed to enqueue task: %v", err))
+			return
+		}
+		if err != nil {
+			// The tasks already enqueued still run, so hand back their IDs
+			slog.Error("Range enqueue failed partway", "cidr", prefix.String(), "enqueued", len(resp.Tasks), "error", err,
+				"request_id", w.Header().Get(RequestIDHeader))
+			resp.Error = fmt.Sprintf("failed to enqueue %s: %v", addr, err)
+			resp.Code = models.ErrCodeInternalError
+			resp.Message = fmt.Sprintf("%d reverse DNS lookups enqueued before failing", len(resp.Tasks))
+			respondJSON(w, http.StatusMultiStatus, resp)
+			return
+		}
+		resp.Tasks = append(resp.Tasks, models.ReverseRangeTask{IP: addr.String(), TaskID: id})
+	}
+
+	span.SetAttributes(
+		attribute.String("dns.cidr", prefix.String()),
+		attribute.Int("dns.servers", len(req.DNSServers)),
+		attribute.Int("task.count", len(resp.Tasks)),
+	)
+	resp.Message = fmt.Sprintf("%d reverse DNS lookups enqueued", len(resp.Tasks))
+	respondJSON(w, http.StatusAccepted, resp)
 }
 
 // processDNSLookup validates request, checks worker availability (Asynq only), enqueues task.
-func (s *Server) processDNSLookup(ctx context.Context, w http.ResponseWriter, req models.DNSLookupRequest) {
-	if err := req.Validate(); err != nil {
-		respondError(w, http.StatusBadRequest, err.Error())
+// Empty queue routes to the default queue. A dry run replies 200 with a preview instead.
+func (s *Server) processDNSLookup(ctx context.Context, w http.ResponseWriter, req models.DNSLookupRequest, queue string) {
+	// Snapshot once so a concurrent reload cannot mix two configs in one request
+	cfg := s.Config()
+	if status, code, err := s.prepareLookup(ctx, cfg, &req, queue); err != nil {
+		respondError(w, status, code, err.Error())
 		return
 	}
 
-	// Check worker availability - only Asynq mode needs this
-	if asynqClient, ok := s.tasksClient.(*tasks.Client); ok {
+	if req.DryRun {
+		respondJSON(w, http.StatusOK, models.DryRunResponse{
+			Domain:     req.Domain,
+			QType:      req.QType,
+			DNSServers: req.DNSServers,
+			Options:    req.QueryOptions,
+			Timeout:    cfg.GetDNSTimeout(),
+			MaxRetries: cfg.GetMaxRetries(),
+			Queue:      queue,
+			Message:    "Dry run: request is valid, nothing enqueued",
+		})
+		return
+	}
+
+	id, err := s.tasksClient.EnqueueDNSLookup(ctx, req.Domain, req.QType, req.DNSServers, req.QueryOptions, req.TaskOptions, queue)
+	if errors.Is(err, tasks.ErrTaskExists) {
+		// Idempotent resubmission: point the client at the task it already created
+		respondAccepted(w, models.TaskResponse{TaskID: id, Message: "Task already submitted"})
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, err.Error())
+		return
+	}
+
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("dns.domain", req.Domain),
+		attribute.String("dns.qtype", req.QType),
+		attribute.Int("dns.servers", len(req.DNSServers)),
+		attribute.String("task.id", id),
+	)
+
+	msg := "DNS lookup enqueued"
+	if req.QType == "PTR" {
+		msg = "Reverse DNS lookup enqueued"
+	}
+	respondAccepted(w, models.TaskResponse{TaskID: id, Message: msg})
+}
+
+// prepareLookup validates req, fills in the config servers when it has none and normalizes
+// their targets. On failure it returns the HTTP status and error code to reply with.
+func (s *Server) prepareLookup(ctx context.Context, cfg *config.APIConfig, req *models.DNSLookupRequest, queue string) (int, string, error) {
+	if req.QType == "" {
+		req.QType = cfg.GetDefaultQType()
+	}
+	if err := req.Validate(cfg.GetAllowedQTypes()); err != nil {
+		code := models.ErrCodeInvalidRequest
+		var verr *models.ValidationError
+		if errors.As(err, &verr) {
+			code = verr.Code
+		}
+		return http.StatusBadRequest, code, err
+	}
+
+	if err := req.TaskOptions.Validate(cfg.Callbacks.AllowHTTP); err != nil {
+		return http.StatusBadRequest, models.ErrCodeInvalidOptions, err
+	}
+
+	if queue != "" {
+		if _, ok := cfg.GetQueues()[queue]; !ok {
+			return http.StatusBadRequest, models.ErrCodeUnknownQueue, fmt.Errorf("unknown queue '%s'", queue)
+		}
+	}
+
+	// Check worker availability - only Asynq mode needs this, and dry runs enqueue nothing
+	if asynqClient, ok := s.tasksClient.(*tasks.Client); ok && !req.DryRun {
 		if !asynqClient.HasActiveWorkers(ctx) {
-			respondError(w, http.StatusServiceUnavailable, "no workers available - tasks cannot be processed")
-			return
+			return http.StatusServiceUnavailable, models.ErrCodeNoWorkers, errors.New("no workers available - tasks cannot be processed")
 		}
 	}
 
 	// Use config servers if none provided
 	if len(req.DNSServers) == 0 {
-		for _, t := range s.config.GetDNSTargets() {
-			req.DNSServers = append(req.DNSServers, models.DNSServer{Target: t.Target, Tags: t.Tags})
+		for _, t := range cfg.GetDNSTargets() {
+			req.DNSServers = append(req.DNSServers, models.DNSServer{Target: t.Target, Tags: t.Tags, PinSHA256: t.PinSHA256})
 		}
 	}
 	if len(req.DNSServers) == 0 {
-		respondError(w, http.StatusBadRequest, "Aucun serveur DNS n'est configuré, veuillez renseigner un serveur et une adresse.")
-		return
+		return http.StatusBadRequest, models.ErrCodeNoServers, errors.New("no DNS servers configured - provide dns_servers in the request or configure servers")
 	}
 
 	// Enforce max servers per request limit (applies to both explicit and config-provided servers)
-	maxServers := s.config.GetMaxServersPerRequest()
+	maxServers := cfg.GetMaxServersPerRequest()
 	if len(req.DNSServers) > maxServers {
-		respondError(w, http.StatusBadRequest,
-			fmt.Sprintf("too many DNS servers: %d (maximum allowed: %d). Reduce servers in config or request", len(req.DNSServers), maxServers))
-		return
+		return http.StatusBadRequest, models.ErrCodeTooManyServers,
+			fmt.Errorf("too many DNS servers: %d (maximum allowed: %d). Reduce servers in config or request", len(req.DNSServers), maxServers)
 	}
 
 	// Normalize all targets before enqueueing
 	for i := range req.DNSServers {
-		if norm, err := normalize.Target(req.DNSServers[i].Target); err == nil {
-			req.DNSServers[i].Target = norm
-		} else {
-			respondError(w, http.StatusBadRequest, err.Error())
-			return
+		norm, err := normalize.Target(req.DNSServers[i].Target)
+		if err != nil {
+			return http.StatusBadRequest, models.ErrCodeInvalidTarget, err
 		}
+		req.DNSServers[i].Target = norm
 	}
 
 	if s.tasksClient == nil {
-		respondError(w, http.StatusInternalServerError, "tasks client not configured")
+		return http.StatusInternalServerError, models.ErrCodeInternalError, errors.New("tasks client not configured")
+	}
+	return http.StatusOK, "", nil
+}
+
+// respondAccepted replies 202 with a Location header pointing at the task to poll.
+func respondAccepted(w http.ResponseWriter, task models.TaskResponse) {
+	w.Header().Set("Location", "/tasks/"+task.TaskID)
+	respondJSON(w, http.StatusAccepted, task)
+}
+
+// handleListTasks lists recent tasks with their status, newest first
+// @Summary List tasks
+// @Description List recent task IDs and statuses, optionally filtered by status. Paginate with the returned next_cursor.
+// @Tags Tasks
+// @Produce json
+// @Param status query string false "Filter by status" Enums(PENDING, ACTIVE, RETRY, SUCCESS, FAILURE)
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Success 200 {object} models.TaskListResponse "Page of tasks"
+// @Failure 400 {object} models.ErrorResponse "Invalid filter"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /tasks [get]
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	if s.tasksClient == nil {
+		respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, "tasks client not configured")
 		return
 	}
 
-	id, err := s.tasksClient.EnqueueDNSLookup(ctx, req.Domain, req.QType, req.DNSServers, req.TLSInsecureSkipVerify)
+	query := r.URL.Query()
+	filter := tasks.TaskFilter{Status: query.Get("status"), Cursor: query.Get("cursor")}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("invalid limit '%s'", raw))
+			return
+		}
+		filter.Limit = limit
+	}
+
+	list, err := s.tasksClient.ListTasks(r.Context(), filter)
 	if err != nil {
-		respondError(w, http.StatusInternalServerError, err.Error())
+		if errors.Is(err, tasks.ErrInvalidFilter) {
+			respondError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		} else {
+			respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, err.Error())
+		}
 		return
 	}
 
-	msg := "DNS lookup enqueued"
-	if req.QType == "PTR" {
-		msg = "Reverse DNS lookup enqueued"
-	}
-	respondJSON(w, http.StatusOK, models.TaskResponse{TaskID: id, Message: msg})
+	respondJSON(w, http.StatusOK, list)
 }
 
 // handleGetTaskStatus retrieves the status and result of a submitted task
 // @Summary Get task status and result
-// @Description Retrieve the status and result of a previously submitted DNS lookup task
+// @Description Retrieve the status and result of a previously submitted DNS lookup task. Send the returned ETag as If-None-Match to get 304 while the status is unchanged.
 // @Tags Tasks
 // @Produce json
 // @Param taskID path string true "Task ID"
+// @Param wait query string false "Hold the request until the task is SUCCESS or FAILURE, at most this long (e.g. 30s, capped at 60s)"
+// @Param group_by query string false "Add a rollup of the result per server tag to task_result.by_tag" Enums(tag)
+// @Param If-None-Match header string false "ETag of a previous response"
 // @Success 200 {object} models.TaskStatusResponse "Task found"
+// @Header 200 {string} ETag "Hash of the task status"
+// @Success 304 "Task status unchanged"
+// @Failure 400 {object} models.ErrorResponse "Invalid wait duration or group_by"
 // @Failure 404 {object} models.ErrorResponse "Task not found"
 // @Failure 500 {object} models.ErrorResponse "Internal server error"
 // @Router /tasks/{taskID} [get]
 func (s *Server) handleGetTaskStatus(w http.ResponseWriter, r *http.Request) {
 	taskID := chi.URLParam(r, "taskID")
 	if s.tasksClient == nil {
-		respondError(w, http.StatusInternalServerError, "tasks client not configured")
+		respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, "tasks client not configured")
+		return
+	}
+	wait, err := parseWait(r.URL.Query().Get("wait"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, err.Error())
+		return
+	}
+	groupBy := r.URL.Query().Get("group_by")
+	if groupBy != "" && groupBy != "tag" {
+		respondError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, fmt.Sprintf("unsupported group_by %q (allowed: tag)", groupBy))
 		return
 	}
 	status, err := s.tasksClient.GetTaskStatus(r.Context(), taskID)
+	if err == nil && wait > 0 {
+		status, err = s.waitForTask(w, r, taskID, status, wait)
+	}
 	if err != nil {
 		if err.Error() == "not found" {
-			respondError(w, http.StatusNotFound, "task not found")
+			respondError(w, http.StatusNotFound, models.ErrCodeTaskNotFound, "task not found")
 		} else {
-			respondError(w, http.StatusInternalServerError, err.Error())
+			respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, err.Error())
 		}
 		return
 	}
 
-	// Metrics on-demand: update when client polls results (solves worker metrics collection)
+	// Lookup metrics are recorded where the task runs; polls are only counted
 	metrics.APIResultPollsTotal.Inc()
-	s.updateMetricsFromTaskResult(taskID, *status)
 
-	respondJSON(w, http.StatusOK, status)
-}
-
-// updateMetricsFromTaskResult collects metrics on demand when clients poll results.
-// Pragmatic solution: works without worker HTTP endpoints.
-func (s *Server) updateMetricsFromTaskResult(_ string, status models.TaskStatusResponse) {
-	if status.Status != "SUCCESS" || status.Result == nil {
-		return
+	if groupBy == "tag" && status.Result != nil {
+		// Copies, since the in-memory tasks client hands out its stored result
+		grouped, result := *status, *status.Result
+		result.ByTag = result.GroupByTag()
+		grouped.Result = &result
+		status = &grouped
 	}
+	respondWithETag(w, r, status)
+}
 
-	for target, detail := range status.Result.Details {
-		qtype := detail.QType
-		if qtype == "" {
-			qtype = "A"
-		}
-
-		if detail.CommandStatus == "ok" {
-			metrics.DNSLookupTotal.WithLabelValues(target, qtype, "success").Inc()
-			metrics.DNSLookupDuration.WithLabelValues(target, qtype).Observe(detail.TimeMs / 1000.0)
-		} else {
-			metrics.DNSLookupTotal.WithLabelValues(target, qtype, "error").Inc()
-			if detail.Error != "" {
-				metrics.DNSLookupErrors.WithLabelValues(target, detail.Error).Inc()
-			} else {
-				metrics.DNSLookupErrors.WithLabelValues(target, "unknown").Inc()
-			}
-		}
-	}
+// handleListServers reports configured servers with their supported protocols
+// @Summary List configured DNS servers
+// @Description List servers from config with their service types and normalized targets grouped by protocol (Do53, DoT, DoH, DoQ)
+// @Tags DNS
+// @Produce json
+// @Success 200 {object} models.ServersResponse "Configured servers"
+// @Router /servers [get]
+func (s *Server) handleListServers(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, models.ServersResponse{Servers: s.Config().GetServerCapabilities()})
 }
 
-// handleHealthCheck returns degraded if Asynq workers unavailable
+// handleHealthCheck returns degraded if Redis is unreachable or Asynq workers unavailable
 // @Summary Health check
-// @Description Check if the API service is running and workers are available
+// @Description Check if the API service is running, Redis answers and workers are available
 // @Tags System
 // @Produce json
-// @Success 200 {object} models.HealthResponse "Service is healthy or degraded"
+// @Success 200 {object} models.HealthResponse "Service is healthy"
+// @Failure 503 {object} models.HealthResponse "Service is degraded"
 // @Router /health [get]
 // @Router /status [get]
 func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	health := models.HealthResponse{Status: "ok"}
-
-	if asynqClient, ok := s.tasksClient.(*tasks.Client); ok {
-		if !asynqClient.HasActiveWorkers(r.Context()) {
-			health.Status = "degraded"
-			health.Warning = "no active workers detected"
-		}
-	}
+	health := s.checkDependencies(r.Context())
 
 	if health.Status == "degraded" {
 		respondJSON(w, http.StatusServiceUnavailable, health)
@@ -317,6 +676,86 @@ func (s *Server) handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, health)
 }
 
+// workerChecker is implemented by task clients that can report connected workers.
+type workerChecker interface {
+	HasActiveWorkers(ctx context.Context) bool
+}
+
+// handleLiveness reports the process is up; it never checks dependencies
+// @Summary Liveness probe
+// @Description Always returns 200 while the API process responds
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.HealthResponse "Process is alive"
+// @Router /livez [get]
+func (s *Server) handleLiveness(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, models.HealthResponse{Status: "ok"})
+}
+
+// handleVersion reports the running build so operators can confirm what is deployed
+// @Summary Build version
+// @Description Return the version, commit, build date and Go toolchain of the running API
+// @Tags System
+// @Produce json
+// @Success 200 {object} version.Info "Build information"
+// @Router /version [get]
+func (s *Server) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	respondJSON(w, http.StatusOK, version.Get(APIVersion))
+}
+
+// handleConfig reports the effective settings in use, with defaults applied and secrets redacted.
+// Only served when auth.api_keys is set, so the config is never exposed anonymously.
+// @Summary Effective configuration
+// @Description Return the running config after defaults are applied; API keys and the callback secret are redacted
+// @Tags System
+// @Produce json
+// @Success 200 {object} config.APIConfig "Effective configuration"
+// @Failure 401 {object} models.ErrorResponse "Missing or invalid API key"
+// @Failure 403 {object} models.ErrorResponse "auth.api_keys is not configured"
+// @Router /config [get]
+func (s *Server) handleConfig(w http.ResponseWriter, _ *http.Request) {
+	cfg := s.Config()
+	if len(cfg.Auth.APIKeys) == 0 {
+		respondError(w, http.StatusForbidden, models.ErrCodeForbidden, "GET /config requires auth.api_keys to be configured")
+		return
+	}
+	respondJSON(w, http.StatusOK, cfg.Effective())
+}
+
+// handleReadiness returns 503 when Redis or the workers are unavailable
+// @Summary Readiness probe
+// @Description Check that Redis answers a ping and workers are connected
+// @Tags System
+// @Produce json
+// @Success 200 {object} models.HealthResponse "Ready to accept lookups"
+// @Failure 503 {object} models.HealthResponse "A dependency is unavailable"
+// @Router /readyz [get]
+func (s *Server) handleReadiness(w http.ResponseWriter, r *http.Request) {
+	health := s.checkDependencies(r.Context())
+	if health.Status != "ok" {
+		respondJSON(w, http.StatusServiceUnavailable, health)
+		return
+	}
+	respondJSON(w, http.StatusOK, health)
+}
+
+// checkDependencies pings Redis through the tasks client, then checks for workers.
+func (s *Server) checkDependencies(ctx context.Context) models.HealthResponse {
+	if s.tasksClient == nil {
+		return models.HealthResponse{Status: "degraded", Warning: "tasks client not configured"}
+	}
+
+	if err := s.tasksClient.Ping(ctx); err != nil {
+		return models.HealthResponse{Status: "degraded", Warning: "redis unreachable"}
+	}
+
+	if wc, ok := s.tasksClient.(workerChecker); ok && !wc.HasActiveWorkers(ctx) {
+		return models.HealthResponse{Status: "degraded", Warning: "no active workers detected"}
+	}
+
+	return models.HealthResponse{Status: "ok"}
+}
+
 // handleMetrics exposes Prometheus metrics
 // @Summary Prometheus metrics
 // @Description Expose application metrics in Prometheus format
@@ -328,14 +767,71 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	promhttp.Handler().ServeHTTP(w, r)
 }
 
+// decodeBody decodes a JSON body capped at server.max_body_bytes.
+// Writes 413 or 400 and returns false when the body is too large or invalid.
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	limit := s.Config().GetServerMaxBodyBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(w, http.StatusRequestEntityTooLarge, models.ErrCodeBodyTooLarge, fmt.Sprintf("request body too large (max %d bytes)", limit))
+		} else {
+			respondError(w, http.StatusBadRequest, models.ErrCodeInvalidRequest, "invalid request")
+		}
+		return false
+	}
+	return true
+}
+
 func respondJSON(w http.ResponseWriter, status int, v interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(v)
 }
 
-func respondError(w http.ResponseWriter, status int, msg string) {
-	respondJSON(w, status, map[string]string{"error": msg})
+// respondWithETag replies 200 with an ETag of the JSON body, or 304 without a body
+// when the request's If-None-Match already carries it. Pollers then only download changes.
+// The ETag is weak because the compression middleware may re-encode the body.
+func respondWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+// etagMatch reports whether an If-None-Match list contains etag, using the weak comparison of RFC 9110.
+func etagMatch(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// respondError reads the correlation ID set by requestIDHeader so callers need no request.
+func respondError(w http.ResponseWriter, status int, code, msg string) {
+	id := w.Header().Get(RequestIDHeader)
+	if status >= http.StatusInternalServerError {
+		slog.Error("Request failed", "status", status, "code", code, "error", msg, "request_id", id)
+	}
+	respondJSON(w, status, models.ErrorResponse{Error: msg, Code: code, RequestID: id})
 }
 
 // LoadConfigFromEnv provides default config path fallback.
@@ -2,26 +2,73 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/logging"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/tasks"
 )
 
 const mockTaskID = "mock-task-id"
 
-type mockTasksClient struct{}
+// mockTaggedTaskID is a finished task whose result comes from tagged servers.
+const mockTaggedTaskID = "mock-tagged-task-id"
 
-func (m *mockTasksClient) Close() error { return nil }
-func (m *mockTasksClient) EnqueueDNSLookup(_ context.Context, _ string, _ string, _ []models.DNSServer, _ bool) (string, error) {
+type mockTasksClient struct {
+	lastQueue   string
+	lastServers []models.DNSServer
+	lastFilter  tasks.TaskFilter
+	pingErr     error
+}
+
+func (m *mockTasksClient) Close() error                 { return nil }
+func (m *mockTasksClient) Ping(_ context.Context) error { return m.pingErr }
+func (m *mockTasksClient) EnqueueDNSLookup(_ context.Context, _ string, _ string, servers []models.DNSServer, _ models.QueryOptions, _ models.TaskOptions, queue string) (string, error) {
+	m.lastQueue = queue
+	m.lastServers = servers
 	return mockTaskID, nil
 }
+func (m *mockTasksClient) ListTasks(_ context.Context, filter tasks.TaskFilter) (*models.TaskListResponse, error) {
+	m.lastFilter = filter
+	if filter.Status == "BOGUS" {
+		return nil, fmt.Errorf("%w: unknown status", tasks.ErrInvalidFilter)
+	}
+	return &models.TaskListResponse{Tasks: []models.TaskSummary{{TaskID: mockTaskID, Status: "SUCCESS"}}}, nil
+}
 func (m *mockTasksClient) GetTaskStatus(_ context.Context, id string) (*models.TaskStatusResponse, error) {
+	if id == mockTaggedTaskID {
+		return &models.TaskStatusResponse{TaskID: id, Status: "SUCCESS", Result: &models.DNSLookupResults{
+			Details: map[string]models.DNSLookupResult{
+				"udp://8.8.8.8:53": {CommandStatus: "ok", TimeMs: 12, Tags: []string{"GOOGLE", "US"}},
+				"udp://8.8.4.4:53": {CommandStatus: "error", Tags: []string{"GOOGLE", "US"}},
+				"udp://9.9.9.9:53": {CommandStatus: "ok", TimeMs: 20, Tags: []string{"QUAD9", "US"}},
+			},
+		}}, nil
+	}
 	if id != mockTaskID {
 		return nil, fmt.Errorf("not found")
 	}
@@ -53,8 +100,8 @@ func TestDNSLookupEndpoint(t *testing.T) {
 
 	server.Router().ServeHTTP(w, req)
 
-	if w.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", w.Code)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
 	}
 
 	var response models.TaskResponse
@@ -65,6 +112,9 @@ func TestDNSLookupEndpoint(t *testing.T) {
 	if response.TaskID == "" {
 		t.Error("Expected task_id in response")
 	}
+	if got := w.Header().Get("Location"); got != "/tasks/"+response.TaskID {
+		t.Errorf("Expected Location /tasks/%s, got %q", response.TaskID, got)
+	}
 }
 
 func TestReverseLookupEndpoint(t *testing.T) {
@@ -87,8 +137,135 @@ func TestReverseLookupEndpoint(t *testing.T) {
 
 	server.Router().ServeHTTP(w, req)
 
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
+	}
+	if got := w.Header().Get("Location"); got != "/tasks/"+mockTaskID {
+		t.Errorf("Expected Location /tasks/%s, got %q", mockTaskID, got)
+	}
+}
+
+// domainsTasksClient records the domain of every enqueued lookup and uses it as the task ID.
+// With failAfter set, enqueues past that many fail.
+type domainsTasksClient struct {
+	mockTasksClient
+	domains   []string
+	failAfter int
+}
+
+func (m *domainsTasksClient) EnqueueDNSLookup(_ context.Context, domain string, _ string, _ []models.DNSServer, _ models.QueryOptions, _ models.TaskOptions, _ string) (string, error) {
+	if m.failAfter > 0 && len(m.domains) >= m.failAfter {
+		return "", errors.New("redis unavailable")
+	}
+	m.domains = append(m.domains, domain)
+	return domain, nil
+}
+
+func TestReverseRangeEndpoint(t *testing.T) {
+	server := NewServer(&config.APIConfig{DNS: config.DNSConfig{MaxReverseRange: 16}})
+	client := &domainsTasksClient{}
+	server.SetTasksClient(client)
+
+	post := func(cidr string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(models.ReverseRangeRequest{CIDR: cidr, DNSServers: []models.DNSServer{{Target: "udp://9.9.9.9:53"}}})
+		req := httptest.NewRequest(http.MethodPost, "/reverse-lookup/range", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	// Host bits are masked off, so any address inside the /30 names the same range
+	w := post("192.0.2.6/30")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.ReverseRangeResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Tasks) != 4 {
+		t.Fatalf("Expected 4 tasks, got %+v", response.Tasks)
+	}
+	for i, task := range response.Tasks {
+		ip := fmt.Sprintf("192.0.2.%d", 4+i)
+		domain := fmt.Sprintf("%d.2.0.192.in-addr.arpa", 4+i)
+		if task.IP != ip || task.TaskID != domain || client.domains[i] != domain {
+			t.Errorf("Task %d: expected %s looking up %s, got %+v", i, ip, domain, task)
+		}
+	}
+
+	client.domains = nil
+	for _, cidr := range []string{"192.0.2.0/27", "2001:db8::/64", "192.0.2.0", "bogus/24"} {
+		if w := post(cidr); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", cidr, w.Code)
+		}
+	}
+	if len(client.domains) != 0 {
+		t.Errorf("Expected rejected ranges to enqueue nothing, got %v", client.domains)
+	}
+
+	// A failure partway still hands back the tasks that were enqueued
+	client.failAfter = 2
+	w = post("192.0.2.0/30")
+	if w.Code != http.StatusMultiStatus {
+		t.Fatalf("Expected status 207, got %d: %s", w.Code, w.Body.String())
+	}
+	response = models.ReverseRangeResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.Tasks) != 2 || response.Tasks[1].TaskID != "1.2.0.192.in-addr.arpa" {
+		t.Errorf("Expected the 2 enqueued tasks, got %+v", response.Tasks)
+	}
+	if response.Code != models.ErrCodeInternalError || !strings.Contains(response.Error, "192.0.2.2") {
+		t.Errorf("Expected an error naming the failed address, got %q (%s)", response.Error, response.Code)
+	}
+
+	// Failing before any enqueue is a plain error
+	client.domains = []string{"a", "b"}
+	if w := post("192.0.2.0/30"); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when nothing was enqueued, got %d", w.Code)
+	}
+}
+
+func TestDNSLookupDryRun(t *testing.T) {
+	server := NewServer(&config.APIConfig{DNS: config.DNSConfig{Timeout: 7}})
+	client := &domainsTasksClient{}
+	server.SetTasksClient(client)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/dns-lookup", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := post(`{"domain": "GitHub.COM.", "dns_servers": [{"target": "9.9.9.9"}, {"target": "tls://dns.quad9.net"}], "doh_method": "post", "dry_run": true}`)
 	if w.Code != http.StatusOK {
-		t.Errorf("Expected status 200, got %d", w.Code)
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var preview models.DryRunResponse
+	if err := json.NewDecoder(w.Body).Decode(&preview); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if preview.Domain != "github.com" || preview.QType != "A" || preview.Timeout != 7 || preview.Options.DoHMethod != models.DoHMethodPOST {
+		t.Errorf("Expected the normalized request with the default qtype and configured timeout, got %+v", preview)
+	}
+	if len(preview.DNSServers) != 2 || preview.DNSServers[0].Target != "udp://9.9.9.9" || preview.DNSServers[1].Target != "tls://dns.quad9.net" {
+		t.Errorf("Expected normalized targets, got %+v", preview.DNSServers)
+	}
+	if w.Header().Get("Location") != "" {
+		t.Errorf("Expected no Location header for a dry run, got %q", w.Header().Get("Location"))
+	}
+
+	// Validation still applies, and nothing is ever enqueued
+	if w := post(`{"domain": "example.com", "dns_servers": [{"target": "ftp://9.9.9.9"}], "dry_run": true}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid target, got %d", w.Code)
+	}
+	if len(client.domains) != 0 {
+		t.Errorf("Expected no task to be enqueued, got %v", client.domains)
 	}
 }
 
@@ -114,6 +291,121 @@ func TestGetTaskStatusEndpoint(t *testing.T) {
 	}
 }
 
+func TestGetTaskStatusGroupByTag(t *testing.T) {
+	server := setupTestServer()
+
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaggedTaskID+query, nil))
+		return w
+	}
+
+	w := get("?group_by=tag")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response models.TaskStatusResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := map[string]models.TagSummary{
+		"GOOGLE": {Servers: 2, Successful: 1, AvgTimeMs: 12},
+		"QUAD9":  {Servers: 1, Successful: 1, AvgTimeMs: 20},
+		"US":     {Servers: 3, Successful: 2, AvgTimeMs: 16},
+	}
+	if response.Result == nil || len(response.Result.ByTag) != len(want) {
+		t.Fatalf("Expected %d tags in by_tag, got %+v", len(want), response.Result)
+	}
+	for tag, summary := range want {
+		if got := response.Result.ByTag[tag]; got != summary {
+			t.Errorf("Tag %s: got %+v, want %+v", tag, got, summary)
+		}
+	}
+	if len(response.Result.Details) != 3 {
+		t.Errorf("Expected details to be kept, got %d", len(response.Result.Details))
+	}
+
+	if w := get(""); strings.Contains(w.Body.String(), "by_tag") {
+		t.Errorf("Expected no by_tag without group_by, got %s", w.Body.String())
+	}
+	if w := get("?group_by=protocol"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unsupported group_by, got %d", w.Code)
+	}
+}
+
+// statusTasksClient reports a task status the test can change between polls.
+type statusTasksClient struct {
+	mockTasksClient
+	status string
+}
+
+func (s *statusTasksClient) GetTaskStatus(_ context.Context, id string) (*models.TaskStatusResponse, error) {
+	return &models.TaskStatusResponse{TaskID: id, Status: s.status}, nil
+}
+
+func TestGetTaskStatusETag(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+	mock := &statusTasksClient{status: "PENDING"}
+	server.SetTasksClient(mock)
+
+	poll := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	first := poll("")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("Expected 200 with an ETag, got %d (ETag %q)", first.Code, etag)
+	}
+
+	w := poll(etag)
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("Expected empty 304 for an unchanged status, got %d with %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Errorf("Expected 304 to repeat ETag %s, got %s", etag, got)
+	}
+
+	mock.status = "SUCCESS"
+	w = poll(etag)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 once the status changed, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got == "" || got == etag {
+		t.Errorf("Expected a new ETag after the status changed, got %q", got)
+	}
+	var response models.TaskStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Status != "SUCCESS" {
+		t.Errorf("Expected SUCCESS body, got %q (%v)", w.Body.String(), err)
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	etag := `W/"abc"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{`W/"abc"`, true},
+		{`"abc"`, true},
+		{`"xyz", W/"abc"`, true},
+		{"*", true},
+		{`"xyz"`, false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := etagMatch(tt.header, etag); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
 func TestHealthCheckEndpoint(t *testing.T) {
 	server := setupTestServer()
 
@@ -135,3 +427,1102 @@ func TestHealthCheckEndpoint(t *testing.T) {
 		t.Errorf("Expected status 'ok', got '%s'", response.Status)
 	}
 }
+
+func TestDNSLookupQueueHeader(t *testing.T) {
+	cfg := &config.APIConfig{Worker: config.WorkerConfig{Queues: map[string]int{"default": 6, "low": 1}}}
+	server := NewServer(cfg)
+	mock := &mockTasksClient{}
+	server.SetTasksClient(mock)
+
+	payload := models.DNSLookupRequest{
+		Domain:     "github.com",
+		QType:      "A",
+		DNSServers: []models.DNSServer{{Target: "udp://9.9.9.9:53"}},
+	}
+	body, _ := json.Marshal(payload)
+
+	tests := []struct {
+		queue      string
+		wantStatus int
+		wantQueue  string
+	}{
+		{"low", http.StatusAccepted, "low"},
+		{"", http.StatusAccepted, ""},
+		{"urgent", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		mock.lastQueue = ""
+		req := httptest.NewRequest(http.MethodPost, "/dns-lookup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.queue != "" {
+			req.Header.Set(QueueHeader, tt.queue)
+		}
+		w := httptest.NewRecorder()
+
+		server.Router().ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("queue %q: expected status %d, got %d", tt.queue, tt.wantStatus, w.Code)
+		}
+		if mock.lastQueue != tt.wantQueue {
+			t.Errorf("queue %q: expected enqueue on %q, got %q", tt.queue, tt.wantQueue, mock.lastQueue)
+		}
+	}
+}
+
+func TestServerSetConfig(t *testing.T) {
+	oldCfg := &config.APIConfig{Servers: []config.DNSServer{
+		{IP: "9.9.9.9", Services: []config.ServiceType{config.ServiceDo53UDP}},
+	}}
+	newCfg := &config.APIConfig{Servers: []config.DNSServer{
+		{IP: "1.1.1.1", Services: []config.ServiceType{config.ServiceDo53UDP}},
+	}}
+
+	server := NewServer(oldCfg)
+	mock := &mockTasksClient{}
+	server.SetTasksClient(mock)
+
+	lookup := func() string {
+		body, _ := json.Marshal(models.DNSLookupRequest{Domain: "github.com", QType: "A"})
+		req := httptest.NewRequest(http.MethodPost, "/dns-lookup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted || len(mock.lastServers) != 1 {
+			t.Fatalf("Expected config servers to be used, got status %d and %v", w.Code, mock.lastServers)
+		}
+		return mock.lastServers[0].Target
+	}
+
+	if got := lookup(); got != "udp://9.9.9.9:53" {
+		t.Errorf("Expected old config target before reload, got %s", got)
+	}
+
+	// Simulated SIGHUP reload
+	server.SetConfig(newCfg)
+
+	targets := server.Config().GetDNSTargets()
+	if len(targets) != 1 || targets[0].Target != "udp://1.1.1.1:53" {
+		t.Errorf("Expected reloaded targets, got %v", targets)
+	}
+	if got := lookup(); got != "udp://1.1.1.1:53" {
+		t.Errorf("Expected new config target after reload, got %s", got)
+	}
+}
+
+func TestDefaultPublicResolversFallback(t *testing.T) {
+	lookup := func(cfg *config.APIConfig) (int, []models.DNSServer) {
+		server := NewServer(cfg)
+		mock := &mockTasksClient{}
+		server.SetTasksClient(mock)
+
+		body, _ := json.Marshal(models.DNSLookupRequest{Domain: "github.com", QType: "A"})
+		req := httptest.NewRequest(http.MethodPost, "/dns-lookup", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		return w.Code, mock.lastServers
+	}
+
+	if code, _ := lookup(&config.APIConfig{}); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without servers and fallback disabled, got %d", code)
+	}
+
+	code, servers := lookup(&config.APIConfig{DNS: config.DNSConfig{UseDefaultPublicResolvers: true}})
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected 202 with fallback enabled, got %d", code)
+	}
+	if len(servers) != len(config.DefaultPublicResolvers) {
+		t.Fatalf("Expected %d fallback servers, got %v", len(config.DefaultPublicResolvers), servers)
+	}
+	for i, want := range []string{"udp://9.9.9.9:53", "udp://1.1.1.1:53", "udp://8.8.8.8:53"} {
+		if servers[i].Target != want {
+			t.Errorf("Fallback server %d: expected %s, got %s", i, want, servers[i].Target)
+		}
+	}
+}
+
+func TestDNSLookupNoServersError(t *testing.T) {
+	server := setupTestServer()
+
+	body, _ := json.Marshal(models.DNSLookupRequest{Domain: "github.com", QType: "A"})
+	req := httptest.NewRequest(http.MethodPost, "/dns-lookup", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := "no DNS servers configured - provide dns_servers in the request or configure servers"; resp.Error != want {
+		t.Errorf("Expected error %q, got %q", want, resp.Error)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+	servers := `"dns_servers": [{"target": "udp://9.9.9.9:53"}]`
+	tooMany := make([]models.DNSServer, 51)
+	for i := range tooMany {
+		tooMany[i] = models.DNSServer{Target: fmt.Sprintf("udp://192.0.2.%d:53", i+1)}
+	}
+	tooManyBody, _ := json.Marshal(models.DNSLookupRequest{Domain: "example.com", DNSServers: tooMany})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		queue  string
+		status int
+		code   string
+	}{
+		{"malformed body", http.MethodPost, "/dns-lookup", "{", "", http.StatusBadRequest, models.ErrCodeInvalidRequest},
+		{"invalid domain", http.MethodPost, "/dns-lookup", `{"domain": "bad domain!", ` + servers + `}`, "", http.StatusBadRequest, models.ErrCodeInvalidDomain},
+		{"invalid qtype", http.MethodPost, "/dns-lookup", `{"domain": "example.com", "qtype": "BOGUS", ` + servers + `}`, "", http.StatusBadRequest, models.ErrCodeInvalidQType},
+		{"invalid query option", http.MethodPost, "/dns-lookup", `{"domain": "example.com", "doh_method": "PUT", ` + servers + `}`, "", http.StatusBadRequest, models.ErrCodeInvalidOptions},
+		{"invalid task option", http.MethodPost, "/dns-lookup", `{"domain": "example.com", "callback_url": "ftp://example.com", ` + servers + `}`, "", http.StatusBadRequest, models.ErrCodeInvalidOptions},
+		{"invalid target", http.MethodPost, "/dns-lookup", `{"domain": "example.com", "dns_servers": [{"target": "ftp://9.9.9.9"}]}`, "", http.StatusBadRequest, models.ErrCodeInvalidTarget},
+		{"no servers", http.MethodPost, "/dns-lookup", `{"domain": "example.com"}`, "", http.StatusBadRequest, models.ErrCodeNoServers},
+		{"too many servers", http.MethodPost, "/dns-lookup", string(tooManyBody), "", http.StatusBadRequest, models.ErrCodeTooManyServers},
+		{"unknown queue", http.MethodPost, "/dns-lookup", `{"domain": "example.com", ` + servers + `}`, "nope", http.StatusBadRequest, models.ErrCodeUnknownQueue},
+		{"invalid reverse ip", http.MethodPost, "/reverse-lookup", `{"reverse_ip": "not-an-ip", ` + servers + `}`, "", http.StatusBadRequest, models.ErrCodeInvalidIP},
+		{"invalid cidr", http.MethodPost, "/reverse-lookup/range", `{"cidr": "192.0.2.0/33"}`, "", http.StatusBadRequest, models.ErrCodeInvalidCIDR},
+		{"range too large", http.MethodPost, "/reverse-lookup/range", `{"cidr": "10.0.0.0/8"}`, "", http.StatusBadRequest, models.ErrCodeRangeTooLarge},
+		{"invalid limit", http.MethodGet, "/tasks?limit=many", "", "", http.StatusBadRequest, models.ErrCodeInvalidRequest},
+		{"unknown task", http.MethodGet, "/tasks/unknown", "", "", http.StatusNotFound, models.ErrCodeTaskNotFound},
+	}
+
+	server := setupTestServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.queue != "" {
+				req.Header.Set(QueueHeader, tt.queue)
+			}
+			w := httptest.NewRecorder()
+			server.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			var resp models.ErrorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Code != tt.code {
+				t.Errorf("Expected code %q, got %q (%s)", tt.code, resp.Code, resp.Error)
+			}
+		})
+	}
+}
+
+func TestDNSLookupNoWorkersCode(t *testing.T) {
+	cfg := &config.APIConfig{}
+	server := NewServer(cfg)
+	// Nothing listens there, so no worker can be found
+	client := tasks.NewClient("127.0.0.1:1", cfg)
+	defer func() { _ = client.Close() }()
+	server.SetTasksClient(client)
+
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dns-lookup",
+		strings.NewReader(`{"domain": "example.com", "dns_servers": [{"target": "udp://9.9.9.9:53"}]}`)))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", w.Code)
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != models.ErrCodeNoWorkers {
+		t.Errorf("Expected code %q, got %q", models.ErrCodeNoWorkers, resp.Code)
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	cfg := &config.APIConfig{Auth: config.AuthConfig{APIKeys: []string{"secret-key"}}}
+	server := NewServer(cfg)
+	server.SetTasksClient(&mockTasksClient{})
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		want    int
+	}{
+		{"missing key", "/tasks/" + mockTaskID, nil, http.StatusUnauthorized},
+		{"wrong key", "/tasks/" + mockTaskID, map[string]string{APIKeyHeader: "nope"}, http.StatusUnauthorized},
+		{"correct key", "/tasks/" + mockTaskID, map[string]string{APIKeyHeader: "secret-key"}, http.StatusOK},
+		{"bearer token", "/tasks/" + mockTaskID, map[string]string{"Authorization": "Bearer secret-key"}, http.StatusOK},
+		{"health is public", "/health", nil, http.StatusOK},
+		{"metrics is public", "/metrics", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			server.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+			if tt.want == http.StatusUnauthorized && w.Header().Get("Content-Type") != "application/json" {
+				t.Errorf("Expected JSON error, got %s", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthDisabledWithoutKeys(t *testing.T) {
+	server := setupTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil)
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 without configured keys, got %d", w.Code)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cfg := &config.APIConfig{CORS: config.CORSConfig{AllowedOrigins: []string{"https://dashboard.example.com"}}}
+	server := NewServer(cfg)
+	server.SetTasksClient(&mockTasksClient{})
+
+	tests := []struct {
+		name       string
+		method     string
+		origin     string
+		wantOrigin string
+		wantStatus int
+	}{
+		{"allowed origin", http.MethodGet, "https://dashboard.example.com", "https://dashboard.example.com", http.StatusOK},
+		{"other origin", http.MethodGet, "https://evil.example.com", "", http.StatusOK},
+		{"no origin", http.MethodGet, "", "", http.StatusOK},
+		{"preflight", http.MethodOptions, "https://dashboard.example.com", "https://dashboard.example.com", http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/tasks/"+mockTaskID, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			w := httptest.NewRecorder()
+			server.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+		})
+	}
+}
+
+func TestCORSWildcardAndDisabled(t *testing.T) {
+	wildcard := NewServer(&config.APIConfig{CORS: config.CORSConfig{AllowedOrigins: []string{"*"}}})
+	disabled := setupTestServer()
+
+	for name, tt := range map[string]struct {
+		server *Server
+		want   string
+	}{
+		"wildcard": {wildcard, "*"},
+		"disabled": {disabled, ""},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("Origin", "http://localhost:3000")
+		w := httptest.NewRecorder()
+		tt.server.Router().ServeHTTP(w, req)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", name, tt.want, got)
+		}
+	}
+}
+
+func TestGzipCompression(t *testing.T) {
+	server := setupTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Expected Content-Encoding gzip, got %q", got)
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	defer gz.Close()
+
+	var response models.TaskStatusResponse
+	if err := json.NewDecoder(gz).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode decompressed response: %v", err)
+	}
+	if response.TaskID != mockTaskID || response.Status != "SUCCESS" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}
+
+func TestCompressionDisabled(t *testing.T) {
+	server := NewServer(&config.APIConfig{Server: config.ServerConfig{CompressionLevel: -1}})
+	server.SetTasksClient(&mockTasksClient{})
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if got := w.Header().Get("Content-Encoding"); got != "" {
+		t.Errorf("Expected no Content-Encoding when disabled, got %q", got)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	cfg := &config.APIConfig{Server: config.ServerConfig{MaxBodyBytes: 512}}
+	server := NewServer(cfg)
+	server.SetTasksClient(&mockTasksClient{})
+
+	payload := models.DNSLookupRequest{Domain: "example.com", QType: "A"}
+	for i := 0; i < 50; i++ {
+		payload.DNSServers = append(payload.DNSServers, models.DNSServer{Target: fmt.Sprintf("udp://192.0.2.%d:53", i)})
+	}
+	body, _ := json.Marshal(payload)
+
+	for _, path := range []string{"/dns-lookup", "/reverse-lookup"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("%s: expected status 413, got %d", path, w.Code)
+		}
+		var response models.ErrorResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil || response.Error == "" {
+			t.Errorf("%s: expected JSON error body, got %v", path, err)
+		}
+	}
+}
+
+// sequenceTasksClient returns the given statuses in order, repeating the last one.
+type sequenceTasksClient struct {
+	mockTasksClient
+	statuses []string
+	calls    int
+}
+
+func (m *sequenceTasksClient) GetTaskStatus(_ context.Context, id string) (*models.TaskStatusResponse, error) {
+	if id != mockTaskID {
+		return nil, fmt.Errorf("not found")
+	}
+	i := min(m.calls, len(m.statuses)-1)
+	m.calls++
+	return &models.TaskStatusResponse{TaskID: id, Status: m.statuses[i]}, nil
+}
+
+func TestTaskStream(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+	server.SetTasksClient(&sequenceTasksClient{statuses: []string{"PENDING", "PENDING", "ACTIVE", "SUCCESS"}})
+	server.streamInterval = time.Millisecond
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID+"/stream", nil)
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", got)
+	}
+
+	var statuses []string
+	for _, frame := range strings.Split(strings.TrimSpace(w.Body.String()), "\n\n") {
+		lines := strings.SplitN(frame, "\n", 2)
+		if len(lines) != 2 || lines[0] != "event: status" {
+			t.Fatalf("Unexpected SSE frame %q", frame)
+		}
+		var status models.TaskStatusResponse
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &status); err != nil {
+			t.Fatalf("Invalid frame data %q: %v", lines[1], err)
+		}
+		statuses = append(statuses, status.Status)
+	}
+
+	// Repeated PENDING polls are collapsed into a single event
+	if want := []string{"PENDING", "ACTIVE", "SUCCESS"}; strings.Join(statuses, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected events %v, got %v", want, statuses)
+	}
+}
+
+func TestGetTaskStatusLongPoll(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+	mock := &sequenceTasksClient{statuses: []string{"PENDING", "PENDING", "ACTIVE", "SUCCESS"}}
+	server.SetTasksClient(mock)
+	server.streamInterval = 10 * time.Millisecond
+
+	start := time.Now()
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID+"?wait=30s", nil))
+	elapsed := time.Since(start)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var status models.TaskStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || status.Status != "SUCCESS" {
+		t.Errorf("Expected SUCCESS once the task completed, got %q (%v)", w.Body.String(), err)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("Expected a prompt reply once the task completed, took %s", elapsed)
+	}
+	if mock.calls != 4 {
+		t.Errorf("Expected 4 status lookups, got %d", mock.calls)
+	}
+}
+
+func TestGetTaskStatusLongPollTimeout(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+	server.SetTasksClient(&sequenceTasksClient{statuses: []string{"PENDING"}})
+	server.streamInterval = 10 * time.Millisecond
+
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID+"?wait=50ms", nil))
+	var status models.TaskStatusResponse
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &status) != nil || status.Status != "PENDING" {
+		t.Errorf("Expected PENDING after the wait elapsed, got %d %q", w.Code, w.Body.String())
+	}
+
+	for _, wait := range []string{"soon", "-1s"} {
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID+"?wait="+wait, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("wait=%s: expected status 400, got %d", wait, w.Code)
+		}
+	}
+}
+
+func TestTaskStreamNotFound(t *testing.T) {
+	server := setupTestServer()
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks/unknown/stream", nil)
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestListTasksEndpoint(t *testing.T) {
+	client := &mockTasksClient{}
+	server := NewServer(&config.APIConfig{})
+	server.SetTasksClient(client)
+
+	tests := []struct {
+		name   string
+		query  string
+		want   int
+		filter tasks.TaskFilter
+	}{
+		{"no filter", "", http.StatusOK, tasks.TaskFilter{}},
+		{"status and page", "?status=pending&limit=10&cursor=20", http.StatusOK, tasks.TaskFilter{Status: "pending", Limit: 10, Cursor: "20"}},
+		{"bad limit", "?limit=ten", http.StatusBadRequest, tasks.TaskFilter{}},
+		{"bad status", "?status=BOGUS", http.StatusBadRequest, tasks.TaskFilter{Status: "BOGUS"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.lastFilter = tasks.TaskFilter{}
+			req := httptest.NewRequest(http.MethodGet, "/tasks"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.Router().ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d (%s)", tt.want, w.Code, w.Body.String())
+			}
+			if client.lastFilter != tt.filter {
+				t.Errorf("Expected filter %+v, got %+v", tt.filter, client.lastFilter)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			var response models.TaskListResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if len(response.Tasks) != 1 || response.Tasks[0].TaskID != mockTaskID {
+				t.Errorf("Unexpected tasks: %+v", response.Tasks)
+			}
+		})
+	}
+}
+
+// workersTasksClient reports whether workers are connected, like the Asynq client.
+type workersTasksClient struct {
+	mockTasksClient
+	active bool
+}
+
+func (w *workersTasksClient) HasActiveWorkers(_ context.Context) bool { return w.active }
+
+func TestVersionEndpoint(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/version", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	for _, key := range []string{"version", "git_commit", "build_date", "go_version"} {
+		if body[key] == "" {
+			t.Errorf("Expected non-empty %q in %v", key, body)
+		}
+	}
+	if body["version"] != APIVersion {
+		t.Errorf("Expected version %s, got %s", APIVersion, body["version"])
+	}
+	if body["go_version"] != runtime.Version() {
+		t.Errorf("Expected go_version %s, got %s", runtime.Version(), body["go_version"])
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	cfg := &config.APIConfig{
+		Auth:      config.AuthConfig{APIKeys: []string{"secret-key"}},
+		Callbacks: config.CallbackConfig{Secret: "hmac-secret"},
+	}
+	server := NewServer(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/config", nil)
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without a key, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/config", nil)
+	req.Header.Set(APIKeyHeader, "secret-key")
+	w = httptest.NewRecorder()
+	server.Router().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d (%s)", w.Code, w.Body.String())
+	}
+
+	var got config.APIConfig
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if got.DNS.Timeout != 5 {
+		t.Errorf("Expected default dns.timeout 5, got %d", got.DNS.Timeout)
+	}
+	if got.Server.Port != "5000" {
+		t.Errorf("Expected default server.port 5000, got %q", got.Server.Port)
+	}
+	if strings.Contains(w.Body.String(), "secret-key") || strings.Contains(w.Body.String(), "hmac-secret") {
+		t.Errorf("Expected secrets to be redacted, got %s", w.Body.String())
+	}
+	if len(got.Auth.APIKeys) != 1 || got.Auth.APIKeys[0] != config.Redacted {
+		t.Errorf("Expected redacted API key, got %v", got.Auth.APIKeys)
+	}
+}
+
+func TestConfigEndpointRequiresAuth(t *testing.T) {
+	server := NewServer(&config.APIConfig{})
+
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status 403 without configured keys, got %d", w.Code)
+	}
+}
+
+func TestLivenessAndReadiness(t *testing.T) {
+	tests := []struct {
+		name        string
+		client      tasks.ClientInterface
+		wantReady   int
+		wantWarning string
+	}{
+		{"healthy", &workersTasksClient{active: true}, http.StatusOK, ""},
+		{"no workers", &workersTasksClient{active: false}, http.StatusServiceUnavailable, "no active workers detected"},
+		{"redis down", &workersTasksClient{mockTasksClient: mockTasksClient{pingErr: errors.New("connection refused")}, active: true}, http.StatusServiceUnavailable, "redis unreachable"},
+		{"memory client", &mockTasksClient{}, http.StatusOK, ""},
+		{"no tasks client", nil, http.StatusServiceUnavailable, "tasks client not configured"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(&config.APIConfig{})
+			server.SetTasksClient(tt.client)
+
+			w := httptest.NewRecorder()
+			server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected /livez status 200, got %d", w.Code)
+			}
+
+			w = httptest.NewRecorder()
+			server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if w.Code != tt.wantReady {
+				t.Fatalf("Expected /readyz status %d, got %d", tt.wantReady, w.Code)
+			}
+
+			var response models.HealthResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Warning != tt.wantWarning {
+				t.Errorf("Expected warning %q, got %q", tt.wantWarning, response.Warning)
+			}
+		})
+	}
+}
+
+func TestHealthCheckRedisDown(t *testing.T) {
+	server := setupTestServer()
+	server.SetTasksClient(&mockTasksClient{pingErr: errors.New("dial tcp: connection refused")})
+
+	for _, path := range []string{"/health", "/status"} {
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected status 503, got %d", path, w.Code)
+		}
+		var response models.HealthResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if response.Status != "degraded" || response.Warning != "redis unreachable" {
+			t.Errorf("%s: expected degraded with redis warning, got %+v", path, response)
+		}
+	}
+}
+
+func TestRequestLoggerJSON(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	var buf bytes.Buffer
+	if err := logging.Setup(logging.FormatJSON, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	server := setupTestServer()
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil))
+
+	var entry map[string]interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var parsed map[string]interface{}
+		if err := json.Unmarshal(line, &parsed); err != nil {
+			t.Fatalf("Log line is not JSON: %q: %v", line, err)
+		}
+		if parsed["msg"] == "HTTP request" {
+			entry = parsed
+		}
+	}
+	if entry == nil {
+		t.Fatalf("No request log line in:\n%s", buf.String())
+	}
+
+	for _, key := range []string{"method", "path", "status", "duration", "request_id"} {
+		if _, ok := entry[key]; !ok {
+			t.Errorf("Expected key %q in %v", key, entry)
+		}
+	}
+	if entry["path"] != "/tasks/"+mockTaskID || entry["status"] != float64(http.StatusOK) {
+		t.Errorf("Unexpected request fields: %v", entry)
+	}
+	if id, _ := entry["request_id"].(string); id == "" {
+		t.Errorf("Expected a request ID, got %v", entry["request_id"])
+	}
+}
+
+func TestRequestIDCorrelation(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	var buf bytes.Buffer
+	if err := logging.Setup(logging.FormatJSON, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	server := setupTestServer()
+	w := httptest.NewRecorder()
+	server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dns-lookup", strings.NewReader("not json")))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+	id := w.Header().Get(RequestIDHeader)
+	if id == "" {
+		t.Fatalf("Expected %s header", RequestIDHeader)
+	}
+
+	var body models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RequestID != id {
+		t.Errorf("Expected request_id %q in error body, got %q", id, body.RequestID)
+	}
+
+	var logged string
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err == nil && entry["msg"] == "HTTP request" {
+			logged, _ = entry["request_id"].(string)
+		}
+	}
+	if logged != id {
+		t.Errorf("Expected logged request_id %q to match header, got %q", id, logged)
+	}
+}
+
+func TestRateLimitRetryAfter(t *testing.T) {
+	cfg := &config.APIConfig{RateLimiting: config.RateLimitConfig{RequestsPerSecond: 1, BurstSize: 1}}
+	server := NewServer(cfg)
+	server.SetTasksClient(&mockTasksClient{})
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		server.Router().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	}
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429, got %d", w.Code)
+	}
+	var resp models.ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Code != models.ErrCodeRateLimited {
+		t.Errorf("Expected code %q in the 429 body, got %s", models.ErrCodeRateLimited, w.Body.String())
+	}
+	retryAfter, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retryAfter < 1 {
+		t.Errorf("Expected a numeric Retry-After >= 1, got %q", w.Header().Get("Retry-After"))
+	}
+}
+
+func TestRateLimitKeyedTier(t *testing.T) {
+	cfg := &config.APIConfig{
+		RateLimiting: config.RateLimitConfig{RequestsPerSecond: 1, BurstSize: 1, KeyedRPS: 100},
+		Auth:         config.AuthConfig{APIKeys: []string{"trusted-key"}},
+	}
+	server := NewServer(cfg)
+	server.SetTasksClient(&mockTasksClient{})
+
+	send := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/"+mockTaskID, nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		server.Router().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Exhaust the anonymous per-IP limit (burst 1)
+	send("")
+	if code := send(""); code != http.StatusTooManyRequests {
+		t.Fatalf("Expected anonymous request to be limited, got %d", code)
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := send("trusted-key"); code != http.StatusOK {
+			t.Fatalf("Keyed request %d: expected 200 past the anonymous limit, got %d", i+1, code)
+		}
+	}
+
+	// Unknown keys must not escape the IP limit
+	if code := send("made-up-key"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected invalid key to hit the IP limit, got %d", code)
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	for rps, want := range map[float64]string{10: "1", 1: "1", 0.5: "2", 0.2: "5"} {
+		if got := retryAfterSeconds(rps); got != want {
+			t.Errorf("retryAfterSeconds(%v) = %q, want %q", rps, got, want)
+		}
+	}
+}
+
+func TestServerUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	server := setupTestServer()
+
+	done := make(chan error, 1)
+	go func() { done <- server.Run("", socket) }()
+
+	client := NewClient("unix://"+socket, 5*time.Second, false)
+	var status *models.TaskStatusResponse
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if status, err = client.GetTaskStatus(context.Background(), mockTaskID); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Request over the socket failed: %v", err)
+	}
+	if status.TaskID != mockTaskID {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected Run to return nil after Shutdown, got %v", err)
+	}
+	if _, err := os.Stat(socket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the socket file to be removed, got %v", err)
+	}
+
+	// A stale socket file from an unclean exit is replaced
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = ln.Close()
+	go func() { done <- server.Run("", socket) }()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if _, err = client.GetTaskStatus(context.Background(), mockTaskID); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Errorf("Expected the stale socket to be replaced, got %v", err)
+	}
+	_ = server.Shutdown(context.Background())
+	<-done
+}
+
+// writeSelfSignedCert writes a self-signed certificate for 127.0.0.1 named cn into dir.
+func writeSelfSignedCert(t *testing.T, dir, cn string) (certFile, keyFile string) {
+	t.Helper()
+	cert, key := newTestCert(t, cn, false, nil, nil)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// newTestCert creates a certificate for cn, valid for 127.0.0.1 as a server or a client,
+// signed by parent or self-signed when parent is nil.
+func newTestCert(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+		tmpl.ExtKeyUsage = nil
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// listenLocal listens on a free TCP port of 127.0.0.1 and returns the listener with its port.
+func listenLocal(t *testing.T) (net.Listener, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+}
+
+func TestServerTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir, "first")
+	ln, port := listenLocal(t)
+	redirectLn, redirectPort := listenLocal(t)
+	server := NewServer(&config.APIConfig{Server: config.ServerConfig{
+		TLSCert: certFile, TLSKey: keyFile, HTTPRedirectPort: redirectPort,
+	}})
+	server.SetTasksClient(&mockTasksClient{})
+
+	done := make(chan error, 1)
+	go func() { done <- server.serve(ln, redirectLn, "") }()
+
+	//nolint:gosec // self-signed test certificate
+	hc := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	url := "https://127.0.0.1:" + port + "/health"
+	get := func() (*http.Response, error) {
+		var resp *http.Response
+		var err error
+		for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			if resp, err = hc.Get(url); err == nil {
+				break
+			}
+		}
+		return resp, err
+	}
+	resp, err := get()
+	if err != nil {
+		t.Fatalf("HTTPS request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Fatalf("Expected 200 over TLS, got %d (TLS %v)", resp.StatusCode, resp.TLS != nil)
+	}
+	if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "first" {
+		t.Errorf("Expected certificate %q, got %q", "first", cn)
+	}
+
+	// Plain HTTP on the redirect port points at the HTTPS listener
+	noFollow := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err = noFollow.Get("http://127.0.0.1:" + redirectPort + "/health?x=1")
+	if err != nil {
+		t.Fatalf("Redirect request failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if want := url + "?x=1"; resp.StatusCode != http.StatusPermanentRedirect || resp.Header.Get("Location") != want {
+		t.Errorf("Expected 308 to %s, got %d to %s", want, resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// A renewed certificate is served after a reload, without restarting the listener
+	writeSelfSignedCert(t, dir, "renewed")
+	if err := server.ReloadCertificate(); err != nil {
+		t.Fatalf("ReloadCertificate failed: %v", err)
+	}
+	hc.CloseIdleConnections()
+	if resp, err = get(); err != nil {
+		t.Fatalf("HTTPS request after reload failed: %v", err)
+	}
+	_ = resp.Body.Close()
+	if cn := resp.TLS.PeerCertificates[0].Subject.CommonName; cn != "renewed" {
+		t.Errorf("Expected certificate %q after reload, got %q", "renewed", cn)
+	}
+
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Expected Run to return nil after Shutdown, got %v", err)
+	}
+}
+
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeSelfSignedCert(t, dir, "server")
+	ca, caKey := newTestCert(t, "test CA", true, nil, nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trusted, trustedKey := newTestCert(t, "alice", false, ca, caKey)
+	untrusted, untrustedKey := newTestCert(t, "mallory", false, nil, nil)
+
+	ln, port := listenLocal(t)
+	server := NewServer(&config.APIConfig{Server: config.ServerConfig{
+		TLSCert: certFile, TLSKey: keyFile, TLSClientCA: caFile,
+	}})
+	server.SetTasksClient(&mockTasksClient{})
+	done := make(chan error, 1)
+	go func() { done <- server.serve(ln, nil, "") }()
+	defer func() {
+		_ = server.Shutdown(context.Background())
+		<-done
+	}()
+
+	get := func(certs ...tls.Certificate) error {
+		//nolint:gosec // self-signed test certificate
+		tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true, Certificates: certs}}
+		defer tr.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: tr}).Get("https://127.0.0.1:" + port + "/health")
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("status %d", resp.StatusCode)
+		}
+		return nil
+	}
+	valid := tls.Certificate{Certificate: [][]byte{trusted.Raw}, PrivateKey: trustedKey}
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if err = get(valid); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Request with a trusted client certificate failed: %v", err)
+	}
+
+	if err := get(); err == nil {
+		t.Error("Expected a request without a client certificate to be rejected")
+	}
+	if err := get(tls.Certificate{Certificate: [][]byte{untrusted.Raw}, PrivateKey: untrustedKey}); err == nil {
+		t.Error("Expected a request with an untrusted client certificate to be rejected")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{trusted, ca}}}
+	if name := clientCertName(req); name != "alice" {
+		t.Errorf("Expected client certificate name %q, got %q", "alice", name)
+	}
+}
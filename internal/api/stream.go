@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// DefaultStreamInterval is how often the task stream and long-polls poll the tasks client.
+const DefaultStreamInterval = 500 * time.Millisecond
+
+// MaxTaskWait caps the ?wait= duration of a long-poll on GET /tasks/{id}.
+const MaxTaskWait = 60 * time.Second
+
+// handleTaskStream pushes task status changes as Server-Sent Events until the task is terminal
+// @Summary Stream task status
+// @Description Server-Sent Events stream emitting an "status" event (TaskStatusResponse JSON) on every status change; closes once the task is SUCCESS or FAILURE
+// @Tags Tasks
+// @Produce text/event-stream
+// @Param taskID path string true "Task ID"
+// @Success 200 {object} models.TaskStatusResponse "Stream of status events"
+// @Failure 404 {object} models.ErrorResponse "Task not found"
+// @Failure 500 {object} models.ErrorResponse "Internal server error"
+// @Router /tasks/{taskID}/stream [get]
+func (s *Server) handleTaskStream(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	if s.tasksClient == nil {
+		respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, "tasks client not configured")
+		return
+	}
+
+	// Resolve the first status before switching to SSE so unknown tasks still get a JSON 404
+	status, err := s.tasksClient.GetTaskStatus(r.Context(), taskID)
+	if err != nil {
+		if err.Error() == "not found" {
+			respondError(w, http.StatusNotFound, models.ErrCodeTaskNotFound, "task not found")
+		} else {
+			respondError(w, http.StatusInternalServerError, models.ErrCodeInternalError, err.Error())
+		}
+		return
+	}
+
+	// Streams outlive server.write_timeout; lift the deadline for this response only
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	interval := s.streamInterval
+	if interval <= 0 {
+		interval = DefaultStreamInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastStatus := ""
+	for {
+		if status.Status != lastStatus {
+			if err := writeEvent(w, rc, "status", status); err != nil {
+				return
+			}
+			lastStatus = status.Status
+		}
+		if models.IsFinalTaskStatus(status.Status) {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		status, err = s.tasksClient.GetTaskStatus(r.Context(), taskID)
+		if err != nil {
+			_ = writeEvent(w, rc, "error", map[string]string{"error": err.Error()})
+			return
+		}
+	}
+}
+
+// parseWait reads the ?wait= duration of a long-poll, capped at MaxTaskWait. Empty means no wait.
+func parseWait(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	wait, err := time.ParseDuration(raw)
+	if err != nil || wait < 0 {
+		return 0, fmt.Errorf("invalid wait '%s' (must be a duration such as 30s)", raw)
+	}
+	return min(wait, MaxTaskWait), nil
+}
+
+// waitForTask polls the tasks client until the task is SUCCESS or FAILURE, wait elapses
+// or the client goes away, and returns the latest status either way.
+func (s *Server) waitForTask(w http.ResponseWriter, r *http.Request, taskID string, status *models.TaskStatusResponse, wait time.Duration) (*models.TaskStatusResponse, error) {
+	// The response is written after the wait; push server.write_timeout past it
+	writeTimeout := time.Duration(s.Config().GetServerWriteTimeout()) * time.Second
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(wait + writeTimeout))
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+
+	interval := s.streamInterval
+	if interval <= 0 {
+		interval = DefaultStreamInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for !models.IsFinalTaskStatus(status.Status) {
+		select {
+		case <-ctx.Done():
+			return status, nil
+		case <-ticker.C:
+		}
+
+		next, err := s.tasksClient.GetTaskStatus(ctx, taskID)
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+			return status, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		status = next
+	}
+	return status, nil
+}
+
+// writeEvent writes one SSE frame and flushes it to the client.
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
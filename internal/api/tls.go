@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+)
+
+// loadTLS reads the key pair and, when server.tls_client_ca is set, the client CA pool
+// served by Run. Both are replaced only once everything loaded.
+func (s *Server) loadTLS(cfg *config.ServerConfig) error {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	var pool *x509.CertPool
+	if cfg.TLSClientCA != "" {
+		data, err := os.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			return fmt.Errorf("load TLS client CA: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("load TLS client CA: no PEM certificates in %s", cfg.TLSClientCA)
+		}
+	}
+	s.cert.Store(&cert)
+	s.clientCAs.Store(pool)
+	return nil
+}
+
+// tlsConfig looks the certificate and client CAs up per handshake so ReloadCertificate
+// takes effect without restarting the listener. Client certificates are required and
+// verified when a client CA pool is loaded.
+func (s *Server) tlsConfig() *tls.Config {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.cert.Load(), nil
+		},
+	}
+	if s.clientCAs.Load() != nil {
+		cfg.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conn := cfg.Clone()
+			conn.GetConfigForClient = nil
+			conn.ClientAuth = tls.RequireAndVerifyClientCert
+			conn.ClientCAs = s.clientCAs.Load()
+			return conn, nil
+		}
+	}
+	return cfg
+}
+
+// ReloadCertificate re-reads server.tls_cert, server.tls_key and server.tls_client_ca from
+// the current config, so renewed certificates are picked up on SIGHUP. It does nothing unless
+// Run is serving HTTPS, and on error the previous certificates stay in use.
+func (s *Server) ReloadCertificate() error {
+	if s.cert.Load() == nil {
+		return nil
+	}
+	cfg := s.Config()
+	if !cfg.Server.TLSEnabled() {
+		return errors.New("TLS cannot be disabled without a restart")
+	}
+	if (s.clientCAs.Load() != nil) != (cfg.Server.TLSClientCA != "") {
+		return errors.New("client certificate verification cannot be toggled without a restart")
+	}
+	return s.loadTLS(&cfg.Server)
+}
+
+// clientCertName identifies the verified client certificate of r for audit logs: its
+// common name, else its first DNS, email or URI SAN. Empty without mutual TLS.
+func clientCertName(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
+// redirectToHTTPS answers every request with a permanent redirect to the same URL over
+// HTTPS on port, keeping the host the client asked for.
+func redirectToHTTPS(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		} else {
+			host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		}
+		hostport := net.JoinHostPort(host, port)
+		if port == "443" {
+			hostport = strings.TrimSuffix(hostport, ":443")
+		}
+		target := url.URL{Scheme: "https", Host: hostport, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
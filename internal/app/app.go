@@ -4,12 +4,14 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/url"
 
 	"github.com/sudo-tiz/dns-tester-go/internal/api"
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
 	"github.com/sudo-tiz/dns-tester-go/internal/tasks"
 )
 
@@ -18,11 +20,14 @@ type APIApp struct {
 	cfg         *config.APIConfig
 	tasksClient tasks.ClientInterface
 	server      *api.Server
+	metricsCtx  context.Context
+	stopMetrics context.CancelFunc
 }
 
 // NewAPIApp chooses memory or Asynq client - no Redis means in-memory mode.
 func NewAPIApp(cfg *config.APIConfig, redisURL string) (*APIApp, error) {
 	a := &APIApp{cfg: cfg}
+	a.metricsCtx, a.stopMetrics = context.WithCancel(context.Background())
 
 	var client tasks.ClientInterface
 	if redisURL == "" {
@@ -32,7 +37,7 @@ func NewAPIApp(cfg *config.APIConfig, redisURL string) (*APIApp, error) {
 		if u, err := url.Parse(redisURL); err == nil {
 			redisAddr = u.Host
 		}
-		client = tasks.NewClient(redisAddr)
+		client = tasks.NewClient(redisAddr, cfg)
 	}
 	a.tasksClient = client
 
@@ -45,19 +50,53 @@ func NewAPIApp(cfg *config.APIConfig, redisURL string) (*APIApp, error) {
 	return a, nil
 }
 
-// Run starts HTTP server with configured address.
-func (a *APIApp) Run(addr string) error {
+// Run starts HTTP server with configured address, and on the Unix socket when set.
+func (a *APIApp) Run(addr, socket string) error {
 	if a.server == nil {
 		return fmt.Errorf("server not initialized")
 	}
-	slog.Info("Starting API", "address", addr)
-	return a.server.Run(addr)
+	a.startQueueMetrics()
+	slog.Info("Starting API", "address", addr, "unix_socket", socket)
+	return a.server.Run(addr, socket)
 }
 
-// Shutdown closes task client connections.
-func (a *APIApp) Shutdown(_ context.Context) error {
+// startQueueMetrics refreshes queue depth/worker gauges when the tasks client can report them.
+func (a *APIApp) startQueueMetrics() {
+	insp, ok := a.tasksClient.(metrics.QueueInspector)
+	if !ok {
+		return
+	}
+	go metrics.RunQueueMetrics(a.metricsCtx, insp, metrics.DefaultQueueMetricsInterval)
+}
+
+// ReloadConfig swaps the config served by the API (e.g. on SIGHUP).
+// The in-memory tasks client applies the new DNS and callback settings to lookups that have
+// not started; Asynq workers keep their own config. A served TLS certificate is re-read
+// from disk so renewals apply.
+func (a *APIApp) ReloadConfig(cfg *config.APIConfig) {
+	a.cfg = cfg
+	if r, ok := a.tasksClient.(tasks.ConfigReloader); ok {
+		r.SetConfig(cfg)
+	}
+	if a.server != nil {
+		a.server.SetConfig(cfg)
+		if err := a.server.ReloadCertificate(); err != nil {
+			slog.Error("TLS certificate reload failed - keeping previous certificate", "error", err)
+		}
+	}
+}
+
+// Shutdown drains the HTTP server, removing its Unix socket, then closes task client connections.
+func (a *APIApp) Shutdown(ctx context.Context) error {
+	if a.stopMetrics != nil {
+		a.stopMetrics()
+	}
+	var err error
+	if a.server != nil {
+		err = a.server.Shutdown(ctx)
+	}
 	if a.tasksClient != nil {
-		return a.tasksClient.Close()
+		err = errors.Join(err, a.tasksClient.Close())
 	}
-	return nil
+	return err
 }
@@ -2,12 +2,18 @@
 package cli
 
 import (
+	"cmp"
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -15,6 +21,8 @@ import (
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+	"github.com/sudo-tiz/dns-tester-go/internal/version"
 )
 
 const (
@@ -31,6 +39,12 @@ const (
 	DefaultWarnThreshold = 1.0
 	// DefaultPollInterval is the default interval for polling task status
 	DefaultPollInterval = 500 * time.Millisecond
+	// DefaultLookupTimeout bounds how long a single lookup may wait for its task to complete
+	DefaultLookupTimeout = 2 * time.Minute
+	// MaxRepeat caps --repeat to keep a single invocation from hammering resolvers
+	MaxRepeat = 100
+	// RepeatGap is the pause between repeated submissions
+	RepeatGap = 100 * time.Millisecond
 )
 
 const (
@@ -39,14 +53,51 @@ const (
 	levelErr  = "error"
 )
 
+const (
+	// OutputText is the default human-readable output format
+	OutputText = "text"
+	// OutputJSON prints the raw task status as JSON for scripting
+	OutputJSON = "json"
+	// OutputCSV prints one row per server for spreadsheets
+	OutputCSV = "csv"
+)
+
 var (
 	apiURL        string
 	qtype         string
+	qclass        string
+	fingerprint   bool
+	trace         bool
+	zoneInfo      bool
+	stream        bool
+	concurrency   int
 	insecure      bool
 	debug         bool
 	pretty        bool
 	warnThreshold float64
+	output        string
+	watch         time.Duration
+	diff          bool
+	statsOnly     bool
+	repeat        int
+	serversFile   string
+	domainsFile   string
+	ignoreErrors  bool
+	pollInterval  time.Duration
+	lookupTimeout time.Duration
+	dohMethod     string
+	dohHTTP3      bool
+	proxyURL      string
+	sourceIP      string
+	ipFamily      string
+	serverName    string
+	httpHost      string
+	noCache       bool
+	baseline      bool
+	expect        []string
+	sortAnswers   bool
 	dnsServers    []string
+	serverPins    map[string][]string
 )
 
 // NewRootCmd creates the root CLI command.
@@ -55,7 +106,7 @@ func NewRootCmd() *cobra.Command {
 		Use:     "dnstestergo",
 		Short:   "DNS testing tool with support for Do53, DoT, DoH, DoQ",
 		Long:    `A comprehensive DNS testing tool that supports multiple protocols including UDP/TCP (Do53), DNS-over-TLS (DoT), DNS-over-HTTPS (DoH), and DNS-over-QUIC (DoQ).`,
-		Version: PackageVersion,
+		Version: version.Get(PackageVersion).String(),
 		Args: func(_ *cobra.Command, args []string) error {
 			if len(args) == 0 {
 				return fmt.Errorf("at least one argument is required")
@@ -64,28 +115,48 @@ func NewRootCmd() *cobra.Command {
 		},
 	}
 
-	rootCmd.Flags().StringVarP(&apiURL, "api-url", "u", DefaultAPIURL, "Base URL of the API")
-	rootCmd.Flags().StringVarP(&qtype, "qtype", "t", DefaultQType, "DNS query type (A, AAAA, PTR)")
+	rootCmd.Flags().StringVarP(&apiURL, "api-url", "u", DefaultAPIURL, "Base URL of the API, or unix:///path/to.sock for a local Unix socket")
+	rootCmd.Flags().StringVarP(&qtype, "qtype", "t", "", "DNS query type, or a comma-separated list like A,AAAA,MX (default $DNS_TESTER_DEFAULT_QTYPE or A)")
 	rootCmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Skip TLS certificate verification")
 	rootCmd.Flags().BoolVarP(&debug, "debug", "d", false, "Show detailed error messages for failed lookups")
 	rootCmd.Flags().BoolVarP(&pretty, "pretty", "p", false, "Enable emoji-enhanced output")
 	rootCmd.Flags().Float64VarP(&warnThreshold, "warn-threshold", "w", DefaultWarnThreshold, "Response time threshold in seconds for warnings")
+	rootCmd.Flags().StringVarP(&output, "output", "o", OutputText, "Output format (text, json, csv)")
 
 	rootCmd.AddCommand(NewQueryCommand())
 	rootCmd.AddCommand(NewServerCommand())
 	rootCmd.AddCommand(NewWorkerCommand())
+	rootCmd.AddCommand(NewConfigCommand())
 	return rootCmd
 }
 
-// buildDNSServers converts server targets to DNSServer models.
+// buildDNSServers converts server targets to DNSServer models, keeping certificate pins from --config.
 func buildDNSServers(servers []string) []models.DNSServer {
 	result := make([]models.DNSServer, 0, len(servers))
 	for _, s := range servers {
-		result = append(result, models.DNSServer{Target: s})
+		result = append(result, models.DNSServer{Target: s, PinSHA256: serverPins[s]})
 	}
 	return result
 }
 
+// queryOptionsFromFlags builds the query options shared by every lookup the CLI submits.
+// Per-lookup fields such as Expected are left to the caller.
+func queryOptionsFromFlags() models.QueryOptions {
+	return models.QueryOptions{
+		TLSInsecureSkipVerify: insecure,
+		DoHMethod:             dohMethod,
+		DoHHTTP3:              dohHTTP3,
+		ProxyURL:              proxyURL,
+		SourceIP:              sourceIP,
+		IPFamily:              ipFamily,
+		ServerName:            serverName,
+		HTTPHost:              httpHost,
+		NoCache:               noCache,
+		NormalizeAnswers:      sortAnswers,
+		QClass:                qclass,
+	}
+}
+
 // NewQueryCommand creates the 'query' subcommand.
 func NewQueryCommand() *cobra.Command {
 	cmd := &cobra.Command{
@@ -109,38 +180,175 @@ func NewQueryCommand() *cobra.Command {
   dnstestergo query -r 9.9.9.9
 
   # Custom query type
-  dnstestergo query --qtype=AAAA github.com udp://9.9.9.9:53`,
-		Args: cobra.MinimumNArgs(1),
+  dnstestergo query --qtype=AAAA github.com udp://9.9.9.9:53
+
+  # JSON output for scripting
+  dnstestergo query -o json github.com udp://9.9.9.9:53 | jq .task_result
+
+  # Show which resolvers disagree
+  dnstestergo query --diff github.com udp://9.9.9.9:53 udp://8.8.8.8:53
+
+  # Sample latency 10 times per server
+  dnstestergo query --repeat 10 github.com udp://9.9.9.9:53 tls://dns.quad9.net
+
+  # Query every domain in a file against a checked-in resolver list
+  dnstestergo query --domains-file domains.txt --servers-file resolvers.txt
+
+  # Identify resolver software and instances
+  dnstestergo query --fingerprint udp://9.9.9.9:53 udp://1.1.1.1:53
+
+  # Re-run every 5 seconds until Ctrl-C
+  dnstestergo query --watch 5s github.com udp://9.9.9.9:53`,
+		Args: func(_ *cobra.Command, args []string) error {
+			if len(args) == 0 && domainsFile == "" && !fingerprint {
+				return fmt.Errorf("requires a domain argument or --domains-file")
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			err := runDNSTest(cmd, args)
-			if err != nil {
-				// Affiche seulement l'erreur, sans le help
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if watch > 0 {
+				iterations := runWatch(ctx, args, watch)
+				fmt.Fprintf(os.Stderr, "\nWatch stopped after %d iteration(s)\n", iterations)
+				return nil
+			}
+
+			err := runDNSTest(ctx, args)
+			if err == nil {
+				return nil
+			}
+			if ignoreErrors {
 				cmd.PrintErrln(err)
 				return nil
 			}
-			return nil
+			// Report the error once (by the caller) without the help text
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+			return err
 		},
 	}
 
 	// Reuse existing flags from the original CLI
-	cmd.Flags().StringVarP(&apiURL, "api-url", "u", DefaultAPIURL, "Base URL of the API")
-	cmd.Flags().StringVarP(&qtype, "qtype", "t", DefaultQType, "DNS query type (A, AAAA, PTR)")
+	cmd.Flags().StringVarP(&apiURL, "api-url", "u", DefaultAPIURL, "Base URL of the API, or unix:///path/to.sock for a local Unix socket")
+	cmd.Flags().StringVarP(&qtype, "qtype", "t", "", "DNS query type, or a comma-separated list like A,AAAA,MX (default $DNS_TESTER_DEFAULT_QTYPE or A)")
 	cmd.Flags().BoolVarP(&insecure, "insecure", "i", false, "Skip TLS certificate verification")
 	cmd.Flags().BoolVarP(&debug, "debug", "d", false, "Show detailed error messages for failed lookups")
 	cmd.Flags().BoolVarP(&pretty, "pretty", "p", false, "Enable emoji-enhanced output")
 	cmd.Flags().Float64VarP(&warnThreshold, "warn-threshold", "w", DefaultWarnThreshold, "Response time threshold in seconds for warnings")
+	cmd.Flags().StringVarP(&output, "output", "o", OutputText, "Output format (text, json, csv)")
+	cmd.Flags().DurationVarP(&watch, "watch", "W", 0, "Re-run the lookup at this interval until interrupted (e.g. 5s)")
+	cmd.Flags().BoolVar(&diff, "diff", false, "Group servers by returned answers and warn when they disagree")
+	cmd.Flags().BoolVar(&statsOnly, "stats-only", false, "Only print aggregate latency statistics")
+	cmd.Flags().StringVar(&serversFile, "servers-file", "", "File with one DNS server target per line (# comments allowed)")
+	cmd.Flags().StringVar(&domainsFile, "domains-file", "", "File with one domain per line (# comments allowed)")
+	cmd.Flags().DurationVar(&pollInterval, "poll-interval", DefaultPollInterval, "Interval between task status polls")
+	cmd.Flags().DurationVar(&lookupTimeout, "timeout", DefaultLookupTimeout, "Give up waiting for a lookup after this long (0 waits forever)")
+	cmd.Flags().StringVar(&dohMethod, "doh-method", "", "HTTP method for DoH targets (GET or POST, default GET)")
+	cmd.Flags().BoolVar(&dohHTTP3, "doh-http3", false, "Send DoH queries over HTTP/3 (QUIC) instead of HTTP/2")
+	cmd.Flags().StringVar(&sourceIP, "source-ip", "", "Local address on the worker host that queries are sent from (multi-homed workers)")
+	cmd.Flags().StringVar(&ipFamily, "ip-family", "", "Address family the worker dials hostname targets over (auto, ipv4 or ipv6, default auto)")
+	cmd.Flags().StringVar(&serverName, "server-name", "", "SNI presented to DoT, DoH and DoQ targets instead of their host, which is still dialled (e.g. cloudflare-dns.com for https://1.1.1.1)")
+	cmd.Flags().StringVar(&httpHost, "http-host", "", "Host header sent to DoH targets instead of their host")
+	cmd.Flags().StringVar(&proxyURL, "proxy", "", "SOCKS5 or HTTP CONNECT proxy the worker sends DoH, DoT and Do53 TCP queries through (e.g. socks5://10.0.0.1:1080)")
+	cmd.Flags().BoolVar(&noCache, "no-cache", false, "Always query the servers, bypassing the worker's result cache")
+	cmd.Flags().BoolVar(&baseline, "baseline", false, fmt.Sprintf("Also query this host's system resolver (first nameserver in /etc/resolv.conf) and report it as %s", resolver.SystemTarget))
+	cmd.Flags().BoolVar(&ignoreErrors, "ignore-errors", false, "Always exit 0, even when servers fail or return non-NOERROR rcodes")
+	cmd.Flags().StringVar(&qclass, "qclass", "", "Query class (IN, CH or HS, default IN)")
+	cmd.Flags().BoolVar(&stream, "stream", false, "Submit one task per server and print each result as it completes, then the summary")
+	cmd.Flags().IntVar(&concurrency, "concurrency", DefaultStreamConcurrency, "Maximum per-server tasks in flight with --stream")
+	cmd.Flags().BoolVar(&zoneInfo, "zone-info", false, "Summarize the zone: NS, SOA timers, and apex A, AAAA and MX as each server sees them")
+	cmd.Flags().BoolVar(&trace, "trace", false, "Walk the delegation from the root servers down with RD=0, like dig +trace (runs from this host, not the worker)")
+	cmd.Flags().BoolVar(&fingerprint, "fingerprint", false, "Identify resolver software via version.bind and id.server (CHAOS TXT); positional arguments are servers")
+	cmd.Flags().BoolVar(&sortAnswers, "normalize-answers", false, "Sort answers by type and value and drop duplicates (default: wire order)")
+	cmd.Flags().StringArrayVar(&expect, "expect", nil, "Expected answer as VALUE or TYPE=VALUE (repeatable); servers returning anything else fail")
+	cmd.Flags().IntVar(&repeat, "repeat", 1, fmt.Sprintf("Submit the lookup N times and report latency percentiles per server (max %d)", MaxRepeat))
 	var configPath string
 	cmd.Flags().StringVarP(&configPath, "config", "c", "", "Path to config file")
 
 	return cmd
 }
 
-func runDNSTest(_ *cobra.Command, args []string) error {
+// runWatch re-runs runDNSTest every interval until ctx is cancelled and returns the iteration count.
+// Lookup errors are printed but do not stop the loop, so transient failures stay visible.
+func runWatch(ctx context.Context, args []string, interval time.Duration) int {
+	// Every refresh queries the servers instead of replaying the worker's cache
+	noCache = true
+
+	iterations := 0
+	for {
+		if output == OutputText {
+			// Clear screen and move cursor home between redraws
+			fmt.Print("\033[H\033[2J")
+			fmt.Printf("Every %s - %s\n\n", interval, time.Now().Format(time.RFC3339))
+		}
+
+		if err := runDNSTest(ctx, args); err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		iterations++
+
+		select {
+		case <-ctx.Done():
+			return iterations
+		case <-time.After(interval):
+		}
+	}
+}
+
+func runDNSTest(ctx context.Context, args []string) error {
 	var query string
 	if len(args) > 0 {
 		query = args[0]
 	}
 
+	switch output {
+	case OutputText, OutputJSON, OutputCSV:
+	default:
+		return fmt.Errorf("unsupported output format '%s' (must be text, json or csv)", output)
+	}
+
+	if qtype == "" {
+		envQType, err := config.EnvDefaultQTypeValue()
+		if err != nil {
+			return err
+		}
+		qtype = cmp.Or(envQType, DefaultQType)
+	}
+	qtypes, err := parseQTypes(qtype)
+	if err != nil {
+		return err
+	}
+	if len(qtypes) > 1 {
+		if output == OutputCSV {
+			return fmt.Errorf("several --qtype values do not support csv output (use text or json)")
+		}
+		if stream || repeat > 1 || trace {
+			return fmt.Errorf("several --qtype values cannot be combined with --stream, --repeat or --trace")
+		}
+	}
+
+	if pollInterval <= 0 {
+		return fmt.Errorf("--poll-interval must be positive")
+	}
+	if lookupTimeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
+	}
+
+	if repeat < 1 || repeat > MaxRepeat {
+		return fmt.Errorf("--repeat must be between 1 and %d", MaxRepeat)
+	}
+	if repeat > 1 && output == OutputCSV {
+		return fmt.Errorf("--repeat does not support csv output (use text or json)")
+	}
+	if stream && (output != OutputText || repeat > 1) {
+		return fmt.Errorf("--stream requires text output and cannot be combined with --repeat")
+	}
+	if baseline && (stream || fingerprint || trace || zoneInfo) {
+		return fmt.Errorf("--baseline cannot be combined with --stream, --fingerprint, --trace or --zone-info")
+	}
+
 	configPath := ""
 	for _, f := range os.Args {
 		if strings.HasPrefix(f, "--config=") {
@@ -155,22 +363,111 @@ func runDNSTest(_ *cobra.Command, args []string) error {
 		}
 	}
 
-	dnsServers = nil
+	if trace {
+		if query == "" || len(args) > 1 {
+			return fmt.Errorf("--trace takes exactly one domain and no servers")
+		}
+		if fingerprint || stream || repeat > 1 {
+			return fmt.Errorf("--trace cannot be combined with --fingerprint, --stream or --repeat")
+		}
+		return runTrace(ctx, query)
+	}
+
+	if zoneInfo {
+		if query == "" || domainsFile != "" {
+			return fmt.Errorf("--zone-info takes exactly one domain")
+		}
+		if fingerprint || trace || stream || repeat > 1 {
+			return fmt.Errorf("--zone-info cannot be combined with --fingerprint, --trace, --stream or --repeat")
+		}
+		if err := collectServers(args[1:], configPath); err != nil {
+			return err
+		}
+		return runZoneInfo(ctx, query)
+	}
+
+	if fingerprint {
+		// Names are fixed (version.bind, id.server), so every positional argument is a server
+		if err := collectServers(args, configPath); err != nil {
+			return err
+		}
+		return runFingerprint(ctx)
+	}
+
+	var queries []string
+	if query != "" {
+		queries = append(queries, query)
+	}
+	if domainsFile != "" {
+		domains, err := readListFile(domainsFile)
+		if err != nil {
+			return fmt.Errorf("error reading domains file: %w", err)
+		}
+		queries = append(queries, domains...)
+	}
+	if len(queries) == 0 {
+		return fmt.Errorf("no domain to query (pass a domain or --domains-file)")
+	}
+
+	var servers []string
 	if len(args) > 1 {
-		dnsServers = args[1:]
+		servers = args[1:]
+	}
+	if err := collectServers(servers, configPath); err != nil {
+		return err
+	}
+
+	// Keep going through all queries on per-server failures and exit with the worst outcome
+	code := ExitOK
+	for _, q := range queries {
+		err := lookupQuery(ctx, q, qtypes)
+		if err == nil {
+			continue
+		}
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Err != nil {
+			return err
+		}
+		code = worseExit(code, exitErr.Code)
+	}
+	return exitErrorFor(code)
+}
+
+// collectServers fills dnsServers from positional targets and --servers-file, or from
+// the config file when one is given, and validates every target.
+func collectServers(targets []string, configPath string) error {
+	dnsServers = targets
+	serverPins = nil
+	if serversFile != "" {
+		servers, err := readListFile(serversFile)
+		if err != nil {
+			return fmt.Errorf("error reading servers file: %w", err)
+		}
+		dnsServers = append(dnsServers, servers...)
+	}
+	if len(dnsServers) == 0 && configPath == "" {
+		servers, err := config.EnvDefaultServersValue()
+		if err != nil {
+			return err
+		}
+		dnsServers = servers
 	}
 
 	if configPath != "" {
 		cfg, err := config.LoadConfig(configPath)
 		if err != nil {
-			return fmt.Errorf("erreur chargement config: %w", err)
+			return fmt.Errorf("error loading config: %w", err)
 		}
 		dnsServers = nil
+		serverPins = make(map[string][]string)
 		for _, t := range cfg.GetDNSTargets() {
 			dnsServers = append(dnsServers, t.Target)
+			if len(t.PinSHA256) > 0 {
+				serverPins[t.Target] = t.PinSHA256
+			}
 		}
 		if len(dnsServers) == 0 {
-			return fmt.Errorf("aucun serveur DNS trouvé dans la config")
+			return fmt.Errorf("no DNS servers found in config %s", configPath)
 		}
 	}
 
@@ -179,13 +476,19 @@ func runDNSTest(_ *cobra.Command, args []string) error {
 			return fmt.Errorf("error: %w", err)
 		}
 	}
+	return nil
+}
 
-	// Auto-detect PTR (reverse) lookup if query is an IP
-	queryType := qtype
+// lookupQuery runs a single domain or IP lookup of every record type in qtypes
+// against the resolved dnsServers.
+func lookupQuery(ctx context.Context, query string, qtypes []string) error {
+	// Auto-detect PTR (reverse) lookup if query is an IP, whatever types were asked for
+	queryType := qtypes[0]
 	domain := query
 	if normalize.IsValidIP(query) {
-		fmt.Printf("Starting Reverse DNS lookup for IP: %s ", query)
+		progressf("Starting Reverse DNS lookup for IP: %s ", query)
 		queryType = QTypePTR
+		qtypes = []string{QTypePTR}
 		// Convert IP to reverse DNS format
 		reverseDomain, err := normalize.IPToReverseDNS(query)
 		if err != nil {
@@ -193,90 +496,263 @@ func runDNSTest(_ *cobra.Command, args []string) error {
 		}
 		domain = reverseDomain
 	} else {
-		fmt.Printf("Starting DNS lookup for domain: %s ", query)
+		progressf("Starting DNS lookup for domain: %s ", query)
 	}
 
 	if debug {
-		fmt.Printf("\n\tUsing DNS servers: %s\n", strings.Join(dnsServers, ", "))
-		fmt.Printf("\tQuery type: %s\n", queryType)
+		progressf("\n\tUsing DNS servers: %s\n", strings.Join(dnsServers, ", "))
+		progressf("\tQuery type: %s\n", strings.Join(qtypes, ", "))
 		if queryType == QTypePTR {
-			fmt.Printf("\tReverse domain: %s\n", domain)
+			progressf("\tReverse domain: %s\n", domain)
 		}
-		fmt.Printf("\tAPI Base URL: %s\n", apiURL)
-		fmt.Printf("\tTLS Skip Verify: %t\n", insecure)
+		progressf("\tAPI Base URL: %s\n", apiURL)
+		progressf("\tTLS Skip Verify: %t\n", insecure)
 		if insecure {
-			fmt.Println("\t⚠️  WARNING: TLS certificate verification is DISABLED - USE ONLY FOR TESTING")
+			progressf("\t⚠️  WARNING: TLS certificate verification is DISABLED - USE ONLY FOR TESTING\n")
+		}
+	}
+
+	// Several types parse --expect per type in runQTypes
+	var expected map[string][]string
+	if len(qtypes) == 1 {
+		var err error
+		if expected, err = parseExpect(expect, queryType); err != nil {
+			return err
 		}
 	}
 
 	// Post lookup request using API client
-	ctx := context.Background()
 	client := api.NewClient(apiURL, 30*time.Second, insecure)
-	dnsServersModel := buildDNSServers(dnsServers)
-	taskID, err := client.EnqueueDNSLookup(ctx, models.DNSLookupRequest{
-		Domain:                domain,
-		DNSServers:            dnsServersModel,
-		QType:                 queryType,
-		TLSInsecureSkipVerify: insecure,
-	})
+	req := models.DNSLookupRequest{
+		Domain:       domain,
+		DNSServers:   buildDNSServers(dnsServers),
+		QType:        queryType,
+		QueryOptions: queryOptionsFromFlags(),
+	}
+	req.QueryOptions.Expected = expected
+
+	if len(qtypes) > 1 {
+		return runQTypes(ctx, client, req, qtypes)
+	}
+	if repeat > 1 {
+		return runRepeat(ctx, client, req, repeat)
+	}
+	if stream {
+		return runStream(ctx, client, req, domain, queryType)
+	}
+
+	taskStatus, err := submitAndWait(ctx, client, req)
 	if err != nil {
-		return fmt.Errorf("error: %w", err)
+		return err
+	}
+	addBaseline(ctx, taskStatus, req)
+	if err := printTaskStatus(taskStatus, domain, queryType); err != nil {
+		return err
+	}
+	return exitErrorFor(outcomeExitCode(taskStatus))
+}
+
+// submitAndWait enqueues the lookup and polls until the task reaches a terminal state
+// or --timeout elapses, so a stuck task cannot hang the terminal.
+func submitAndWait(ctx context.Context, client *api.Client, req models.DNSLookupRequest) (*models.TaskStatusResponse, error) {
+	if lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+	}
+
+	taskID, err := client.EnqueueDNSLookup(ctx, req)
+	if err != nil {
+		return nil, &ExitError{Code: ExitSubmitError, Err: fmt.Errorf("error: %w", err)}
 	}
 
 	if debug {
-		fmt.Printf("\tTask ID: %s\n", taskID)
+		progressf("\tTask ID: %s\n", taskID)
 	}
 
 	// Poll for task completion
 	for {
 		taskStatus, err := client.GetTaskStatus(ctx, taskID)
 		if err != nil {
-			return fmt.Errorf("error: %w", err)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, timeoutError(taskID)
+			}
+			return nil, &ExitError{Code: ExitSubmitError, Err: fmt.Errorf("error: %w", err)}
+		}
+
+		if models.IsFinalTaskStatus(taskStatus.Status) {
+			return taskStatus, nil
 		}
 
-		if taskStatus.Status == "SUCCESS" {
-			printResults(taskStatus, queryType == QTypePTR, queryType)
-			break
-		} else if taskStatus.Status == "FAILURE" {
-			fmt.Println("\n\tTask failed.")
-			break
+		// Concurrent --stream polls would interleave dots with result lines
+		if !stream {
+			progressf(".")
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, timeoutError(taskID)
+			}
+			return nil, &ExitError{Code: ExitSubmitError, Err: fmt.Errorf("interrupted: %w", ctx.Err())}
+		case <-time.After(pollInterval):
 		}
+	}
+}
+
+// querySystem asks the host resolver for --baseline; tests replace it to avoid real DNS.
+var querySystem = resolver.QuerySystem
+
+// addBaseline adds the system resolver's answer to a completed task under resolver.SystemTarget
+// with --baseline. It is queried from this host, so it shows what the user normally gets.
+func addBaseline(ctx context.Context, taskStatus *models.TaskStatusResponse, req models.DNSLookupRequest) {
+	if !baseline || taskStatus.Result == nil {
+		return
+	}
+	if taskStatus.Result.Details == nil {
+		taskStatus.Result.Details = make(map[string]models.DNSLookupResult)
+	}
+	taskStatus.Result.Details[resolver.SystemTarget] = querySystem(ctx, req.Domain, req.QType, req.QueryOptions, 1, resolver.DefaultTimeout)
+}
+
+// timeoutError reports a task that did not complete within --timeout.
+func timeoutError(taskID string) error {
+	progressf("\n")
+	return &ExitError{
+		Code: ExitSubmitError,
+		Err:  fmt.Errorf("timed out after %s waiting for task %s (raise --timeout if the lookup is legitimately slow)", lookupTimeout, taskID),
+	}
+}
+
+// printTaskStatus renders a terminal task status in the selected output format.
+func printTaskStatus(taskStatus *models.TaskStatusResponse, domain, queryType string) error {
+	switch output {
+	case OutputJSON:
+		return printJSON(taskStatus)
+	case OutputCSV:
+		return printCSV(taskStatus, queryType)
+	}
 
-		fmt.Print(".")
-		time.Sleep(DefaultPollInterval)
+	if taskStatus.Status != models.TaskStatusSuccess {
+		fmt.Println("\n\tTask failed.")
+		return nil
 	}
 
+	printResults(taskStatus, queryType == QTypePTR, queryType)
+	if diff && taskStatus.Result != nil {
+		printDiff(taskStatus.Result.Details, domain, queryType)
+	}
 	return nil
 }
 
-// HTTP helper functions removed — CLI now uses internal/api Client.
+// runRepeat submits the same lookup n times and reports the latency distribution per server.
+// Ctrl-C stops sampling early and reports what was collected so far.
+func runRepeat(ctx context.Context, client *api.Client, req models.DNSLookupRequest, n int) error {
+	// Each sample has to reach the servers; cache hits would repeat the first run's latency
+	req.QueryOptions.NoCache = true
 
-func printResults(taskStatus *models.TaskStatusResponse, isReverse bool, queryType string) {
-	if taskStatus.Result == nil {
-		fmt.Println("\nNo results available")
-		return
+	var runs []*models.TaskStatusResponse
+	code := ExitOK
+	for i := 0; i < n; i++ {
+		taskStatus, err := submitAndWait(ctx, client, req)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return err
+		}
+		addBaseline(ctx, taskStatus, req)
+		runs = append(runs, taskStatus)
+		code = worseExit(code, outcomeExitCode(taskStatus))
+
+		if i < n-1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(RepeatGap):
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
 	}
 
-	nbCommandsOK := 0
-	for _, result := range taskStatus.Result.Details {
-		if result.CommandStatus == "ok" {
-			nbCommandsOK++
+	if output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(runs); err != nil {
+			return err
 		}
+		return exitErrorFor(code)
 	}
 
-	nbCommands := len(taskStatus.Result.Details)
-	totalDuration := taskStatus.Result.Duration
+	printRepeatStats(runs, n)
+	return exitErrorFor(code)
+}
 
-	fmt.Printf("\nDNS lookup succeeded for %d out of %d servers (%.4f seconds total)\n",
-		nbCommandsOK, nbCommands, totalDuration)
+// progressf prints banners and progress dots only in text mode so JSON stdout stays parseable.
+func progressf(format string, a ...interface{}) {
+	if output != OutputText {
+		return
+	}
+	fmt.Printf(format, a...)
+}
+
+// printJSON writes the full task status to stdout for piping into jq.
+func printJSON(taskStatus *models.TaskStatusResponse) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(taskStatus)
+}
 
-	// Sort results
-	type sortedResult struct {
-		server string
-		result models.DNSLookupResult
+// printCSV writes one row per server, sorted like the text output.
+// Error rows leave values empty and carry the error text in the last column.
+func printCSV(taskStatus *models.TaskStatusResponse, recordType string) error {
+	w := csv.NewWriter(os.Stdout)
+	if err := w.Write([]string{"server", "protocol", "rcode", "time_ms", "ttl", "values", "error"}); err != nil {
+		return err
 	}
-	var sorted []sortedResult
-	for server, result := range taskStatus.Result.Details {
+
+	if taskStatus.Result != nil {
+		for _, item := range sortResults(taskStatus.Result.Details) {
+			result := item.result
+			row := []string{item.server, result.DNSProtocol, result.RCode, "", "", "", result.Error}
+			if result.CommandStatus == "ok" {
+				row[3] = fmt.Sprintf("%.2f", result.TimeMs)
+				answers := filterAnswers(result.Answers, recordType)
+				if len(answers) > 0 {
+					minTTL := answers[0].TTL
+					values := make([]string, 0, len(answers))
+					for _, ans := range answers {
+						if ans.TTL < minTTL {
+							minTTL = ans.TTL
+						}
+						values = append(values, ans.Value)
+					}
+					row[4] = fmt.Sprintf("%d", minTTL)
+					row[5] = strings.Join(values, ";")
+				}
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+// HTTP helper functions removed — CLI now uses internal/api Client.
+
+// sortedResult pairs a server target with its lookup result for ordered output.
+type sortedResult struct {
+	server string
+	result models.DNSLookupResult
+}
+
+// sortResults orders results by host then protocol, falling back to the full target for ties.
+func sortResults(details map[string]models.DNSLookupResult) []sortedResult {
+	sorted := make([]sortedResult, 0, len(details))
+	for server, result := range details {
 		sorted = append(sorted, sortedResult{server, result})
 	}
 	sort.Slice(sorted, func(i, j int) bool {
@@ -293,92 +769,284 @@ func printResults(taskStatus *models.TaskStatusResponse, isReverse bool, queryTy
 		if protoJ == "" {
 			protoJ = "unknown"
 		}
-		return protoI < protoJ
+		if protoI != protoJ {
+			return protoI < protoJ
+		}
+		return sorted[i].server < sorted[j].server
 	})
+	return sorted
+}
+
+// filterAnswers keeps answers matching the requested record type.
+func filterAnswers(answers []models.DNSAnswer, recordType string) []models.DNSAnswer {
+	var filtered []models.DNSAnswer
+	for _, ans := range answers {
+		if ans.Type == recordType {
+			filtered = append(filtered, ans)
+		}
+	}
+	return filtered
+}
 
-	for _, item := range sorted {
-		server := item.server
-		result := item.result
+func printResults(taskStatus *models.TaskStatusResponse, isReverse bool, queryType string) {
+	if taskStatus.Result == nil {
+		fmt.Println("\nNo results available")
+		return
+	}
 
+	nbCommandsOK := 0
+	for _, result := range taskStatus.Result.Details {
 		if result.CommandStatus == "ok" {
-			dnsProtocol := result.DNSProtocol
-			rcode := result.RCode
-			if rcode == "" {
-				rcode = "Unknown"
-			}
+			nbCommandsOK++
+		}
+	}
 
-			if rcode != "NOERROR" {
-				if rcode == "NXDOMAIN" {
-					logResult("warn", fmt.Sprintf("%s - Domain does not exist (rcode: NXDOMAIN) - %.2f ms",
-						server, result.TimeMs))
-				} else {
-					logResult("warn", fmt.Sprintf("%s - No valid answer (rcode: %s) - %.2f ms",
-						server, rcode, result.TimeMs))
-				}
+	nbCommands := len(taskStatus.Result.Details)
+	totalDuration := taskStatus.Result.Duration
+
+	fmt.Printf("\nDNS lookup succeeded for %d out of %d servers (%.4f seconds total)\n",
+		nbCommandsOK, nbCommands, totalDuration)
+
+	sorted := sortResults(taskStatus.Result.Details)
+
+	if !statsOnly {
+		for _, item := range sorted {
+			printServerResult(item.server, item.result, isReverse, queryType)
+		}
+	}
+
+	printStats(taskStatus.Result.Details)
+}
+
+// parseExpect turns --expect values into the request's expected map.
+// A value without a TYPE= prefix (or whose prefix is not a record type, like v=spf1) uses queryType.
+func parseExpect(values []string, queryType string) (map[string][]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	expected := make(map[string][]string)
+	for _, v := range values {
+		rrtype, value := queryType, v
+		if prefix, rest, ok := strings.Cut(v, "="); ok && normalize.IsValidQType(prefix) {
+			rrtype, value = strings.ToUpper(prefix), rest
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return nil, fmt.Errorf("invalid --expect %q: empty value", v)
+		}
+		expected[rrtype] = append(expected[rrtype], value)
+	}
+	return expected, nil
+}
+
+// otherAnswerLines renders the answers not of recordType, one line per record type in wire order.
+// CNAMEs already shown as the via chain are skipped.
+func otherAnswerLines(answers []models.DNSAnswer, recordType string, chain []string) []string {
+	inChain := make(map[string]bool, len(chain))
+	for _, name := range chain {
+		inChain[name] = true
+	}
+
+	var types []string
+	values := make(map[string][]string)
+	for _, ans := range answers {
+		if ans.Type == recordType || (ans.Type == "CNAME" && inChain[ans.Value]) {
+			continue
+		}
+		value := ans.Value
+		if ans.Type == "CNAME" {
+			value = ans.Name + " → " + ans.Value
+		}
+		if _, seen := values[ans.Type]; !seen {
+			types = append(types, ans.Type)
+		}
+		values[ans.Type] = append(values[ans.Type], fmt.Sprintf("%s (TTL: %d)", value, ans.TTL))
+	}
+
+	lines := make([]string, 0, len(types))
+	for _, rrtype := range types {
+		lines = append(lines, fmt.Sprintf("    %s: %s", rrtype, strings.Join(values[rrtype], ", ")))
+	}
+	return lines
+}
+
+// viaChain renders a CNAME chain as " (via a → b)", or "" when there is none.
+func viaChain(chain []string) string {
+	if len(chain) == 0 {
+		return ""
+	}
+	return " (via " + strings.Join(chain, " → ") + ")"
+}
+
+// printServerResult prints the status line for a single server.
+func printServerResult(server string, result models.DNSLookupResult, isReverse bool, queryType string) {
+	if result.SuspiciousResponse {
+		logResult(levelWarn, fmt.Sprintf("%s - suspicious response: %s", server, result.SuspiciousReason))
+	}
+
+	if result.CommandStatus == "ok" {
+		dnsProtocol := result.DNSProtocol
+		if result.HTTPVersion != "" {
+			dnsProtocol += "/" + result.HTTPVersion
+		}
+		rcode := result.RCode
+		if rcode == "" {
+			rcode = "Unknown"
+		}
+
+		if rcode != "NOERROR" {
+			if rcode == "NXDOMAIN" {
+				logResult("warn", fmt.Sprintf("%s - Domain does not exist (rcode: NXDOMAIN) - %.2f ms",
+					server, result.TimeMs))
 			} else {
-				recordType := queryType
-				if isReverse {
-					recordType = QTypePTR
-				}
+				logResult("warn", fmt.Sprintf("%s - No valid answer (rcode: %s) - %.2f ms",
+					server, rcode, result.TimeMs))
+			}
+		} else {
+			recordType := queryType
+			if isReverse {
+				recordType = QTypePTR
+			}
 
-				// Filter answers by record type
-				var answers []models.DNSAnswer
-				for _, ans := range result.Answers {
-					if ans.Type == recordType {
-						answers = append(answers, ans)
-					}
+			// The requested type goes on the status line, CNAMEs as the chain, anything else below
+			answers := filterAnswers(result.Answers, recordType)
+			via := ""
+			var chain []string
+			if recordType != "CNAME" {
+				chain = result.CNAMEChain
+				via = viaChain(chain)
+			}
+
+			if len(answers) > 0 {
+				var values []string
+				var ttls []uint32
+				for _, ans := range answers {
+					values = append(values, ans.Value)
+					ttls = append(ttls, ans.TTL)
 				}
 
-				if len(answers) > 0 {
-					var values []string
-					var ttls []uint32
-					for _, ans := range answers {
-						values = append(values, ans.Value)
-						ttls = append(ttls, ans.TTL)
-					}
+				timeMs := result.TimeMs
+				timeSec := timeMs / 1000.0
 
-					timeMs := result.TimeMs
-					timeSec := timeMs / 1000.0
-
-					// Determine log level based on threshold
-					level := levelInfo
-					if timeSec > warnThreshold {
-						level = levelWarn
-					} // Check if all TTLs are the same
-					allSameTTL := true
-					if len(ttls) > 1 {
-						for i := 1; i < len(ttls); i++ {
-							if ttls[i] != ttls[0] {
-								allSameTTL = false
-								break
-							}
+				// Determine log level based on threshold
+				level := levelInfo
+				if timeSec > warnThreshold {
+					level = levelWarn
+				} // Check if all TTLs are the same
+				allSameTTL := true
+				if len(ttls) > 1 {
+					for i := 1; i < len(ttls); i++ {
+						if ttls[i] != ttls[0] {
+							allSameTTL = false
+							break
 						}
 					}
+				}
 
-					if allSameTTL {
-						logResult(level, fmt.Sprintf("%s - %s - %.5fms - TTL: %ds - %s",
-							server, dnsProtocol, timeMs, ttls[0], strings.Join(values, ", ")))
-					} else {
-						var valueWithTTL []string
-						for _, ans := range answers {
-							valueWithTTL = append(valueWithTTL, fmt.Sprintf("%s (TTL: %d)", ans.Value, ans.TTL))
-						}
-						logResult(level, fmt.Sprintf("%s - %s - %.5fms - %s",
-							server, dnsProtocol, timeMs, strings.Join(valueWithTTL, ", ")))
-					}
+				if allSameTTL {
+					logResult(level, fmt.Sprintf("%s - %s - %.5fms - TTL: %ds - %s%s",
+						server, dnsProtocol, timeMs, ttls[0], strings.Join(values, ", "), via))
 				} else {
-					logResult(levelWarn, fmt.Sprintf("%s - %s - No %s records found - %.2f ms",
-						server, dnsProtocol, recordType, result.TimeMs))
+					var valueWithTTL []string
+					for _, ans := range answers {
+						valueWithTTL = append(valueWithTTL, fmt.Sprintf("%s (TTL: %d)", ans.Value, ans.TTL))
+					}
+					logResult(level, fmt.Sprintf("%s - %s - %.5fms - %s%s",
+						server, dnsProtocol, timeMs, strings.Join(valueWithTTL, ", "), via))
 				}
-			}
-		} else {
-			if debug {
-				logResult(levelErr, fmt.Sprintf("%s - connection issue or error: %s", server, result.Error))
 			} else {
-				logResult(levelErr, fmt.Sprintf("%s - connection issue or error", server))
+				logResult(levelWarn, fmt.Sprintf("%s - %s - No %s records found - %.2f ms%s",
+					server, dnsProtocol, recordType, result.TimeMs, via))
 			}
+			for _, line := range otherAnswerLines(result.Answers, recordType, chain) {
+				fmt.Println(line)
+			}
+		}
+		if result.AssertionPassed != nil && !*result.AssertionPassed {
+			logResult(levelErr, fmt.Sprintf("%s - %s - answers do not match --expect", server, result.DNSProtocol))
+		}
+	} else {
+		if debug {
+			logResult(levelErr, fmt.Sprintf("%s - connection issue or error: %s", server, result.Error))
+		} else {
+			logResult(levelErr, fmt.Sprintf("%s - connection issue or error", server))
+		}
+	}
+}
+
+// answerGroup lists servers that returned the same set of answer values.
+type answerGroup struct {
+	values  []string
+	servers []string
+}
+
+// groupByAnswers buckets successful servers by their sorted answer values for recordType.
+// Groups are ordered by size (largest first) then by values for stable output.
+func groupByAnswers(details map[string]models.DNSLookupResult, recordType string) []answerGroup {
+	index := make(map[string]*answerGroup)
+	for _, item := range sortResults(details) {
+		if item.result.CommandStatus != "ok" {
+			continue
+		}
+
+		var values []string
+		for _, ans := range filterAnswers(item.result.Answers, recordType) {
+			values = append(values, ans.Value)
 		}
+		sort.Strings(values)
+
+		key := strings.Join(values, "\n")
+		g, ok := index[key]
+		if !ok {
+			g = &answerGroup{values: values}
+			index[key] = g
+		}
+		g.servers = append(g.servers, item.server)
 	}
+
+	groups := make([]answerGroup, 0, len(index))
+	for _, g := range index {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].servers) != len(groups[j].servers) {
+			return len(groups[i].servers) > len(groups[j].servers)
+		}
+		return strings.Join(groups[i].values, ",") < strings.Join(groups[j].values, ",")
+	})
+	return groups
+}
+
+// printDiff shows distinct answer sets and which servers returned each.
+// Disagreement is common with GeoDNS or stale caches, so it is a warning rather than an error.
+func printDiff(details map[string]models.DNSLookupResult, domain, recordType string) {
+	groups := groupByAnswers(details, recordType)
+	if len(groups) == 0 {
+		return
+	}
+
+	fmt.Println("\nAnswer comparison:")
+	if len(groups) == 1 {
+		logResult(levelInfo, fmt.Sprintf("All %d servers agree on %s %s: %s",
+			len(groups[0].servers), domain, recordType, formatAnswerSet(groups[0].values)))
+		return
+	}
+
+	logResult(levelWarn, fmt.Sprintf("Servers returned %d different %s record sets for %s",
+		len(groups), recordType, domain))
+	for i, g := range groups {
+		fmt.Printf("  Set %d (%d servers): %s\n", i+1, len(g.servers), formatAnswerSet(g.values))
+		for _, server := range g.servers {
+			fmt.Printf("    - %s\n", server)
+		}
+	}
+}
+
+func formatAnswerSet(values []string) string {
+	if len(values) == 0 {
+		return "(no answers)"
+	}
+	return strings.Join(values, ", ")
 }
 
 func logResult(level, message string) {
@@ -400,6 +1068,25 @@ func logResult(level, message string) {
 	fmt.Printf("%s%s\n", symbol, message)
 }
 
+// readListFile reads one entry per line, skipping blank lines and # comments.
+func readListFile(path string) ([]string, error) {
+	// #nosec G304 -- path is user-controlled via CLI flag by design
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	return entries, nil
+}
+
 func validateAddress(serverAddress string) error {
 	if _, err := normalize.Target(serverAddress); err != nil {
 		return fmt.Errorf("invalid server address format: %w", err)
@@ -421,7 +1108,7 @@ func extractHost(target string) string {
 func Execute() {
 	if err := NewRootCmd().Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(ExitCode(err))
 	}
 }
 
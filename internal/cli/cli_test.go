@@ -0,0 +1,727 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+)
+
+const mockTaskID = "mock-task-id"
+
+// newMockAPI serves /dns-lookup and /tasks/{id} with a canned task status.
+func newMockAPI(t *testing.T, status models.TaskStatusResponse) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: mockTaskID, Message: "DNS lookup enqueued"})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(status)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// captureStdout redirects os.Stdout while fn runs and returns what was written.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+
+	fn()
+
+	os.Stdout = orig
+	_ = w.Close()
+	return string(<-done)
+}
+
+// resetFlags restores CLI flag globals to their defaults between tests.
+func resetFlags(t *testing.T) {
+	t.Helper()
+
+	apiURL = DefaultAPIURL
+	qtype = DefaultQType
+	qclass = ""
+	fingerprint = false
+	trace = false
+	zoneInfo = false
+	stream = false
+	concurrency = DefaultStreamConcurrency
+	insecure = false
+	debug = false
+	pretty = false
+	warnThreshold = DefaultWarnThreshold
+	output = OutputText
+	watch = 0
+	diff = false
+	statsOnly = false
+	repeat = 1
+	serversFile = ""
+	domainsFile = ""
+	ignoreErrors = false
+	pollInterval = DefaultPollInterval
+	lookupTimeout = DefaultLookupTimeout
+	dohMethod = ""
+	dohHTTP3 = false
+	proxyURL = ""
+	sourceIP = ""
+	ipFamily = ""
+	serverName = ""
+	httpHost = ""
+	noCache = false
+	baseline = false
+	expect = nil
+	sortAnswers = false
+	dnsServers = nil
+	serverPins = nil
+}
+
+func mockSuccessStatus() models.TaskStatusResponse {
+	return models.TaskStatusResponse{
+		TaskID: mockTaskID,
+		Status: "SUCCESS",
+		Result: &models.DNSLookupResults{
+			Details: map[string]models.DNSLookupResult{
+				"udp://9.9.9.9:53": {
+					CommandStatus: "ok",
+					TimeMs:        12.5,
+					RCode:         "NOERROR",
+					QType:         "A",
+					DNSProtocol:   "Do53",
+					Answers: []models.DNSAnswer{
+						{Name: "example.com", Type: "A", TTL: 300, Value: "93.184.216.34"},
+					},
+				},
+			},
+			Duration: 0.02,
+		},
+	}
+}
+
+func TestQueryOptionsFromFlags(t *testing.T) {
+	resetFlags(t)
+	insecure = true
+	dohMethod = "POST"
+	serverName = "dns.example"
+	noCache = true
+	sortAnswers = true
+	qclass = models.QClassCH
+
+	want := models.QueryOptions{
+		TLSInsecureSkipVerify: true,
+		DoHMethod:             "POST",
+		ServerName:            "dns.example",
+		NoCache:               true,
+		NormalizeAnswers:      true,
+		QClass:                models.QClassCH,
+	}
+	if got := queryOptionsFromFlags(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestRunDNSTestJSONOutput(t *testing.T) {
+	resetFlags(t)
+	srv := newMockAPI(t, mockSuccessStatus())
+	apiURL = srv.URL
+	output = OutputJSON
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	var got models.TaskStatusResponse
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("stdout is not valid JSON: %v\n%s", err, stdout)
+	}
+
+	if got.Result == nil {
+		t.Fatal("Expected task_result in JSON output")
+	}
+	detail, ok := got.Result.Details["udp://9.9.9.9:53"]
+	if !ok {
+		t.Fatalf("Expected result for udp://9.9.9.9:53, got %v", got.Result.Details)
+	}
+	if len(detail.Answers) != 1 || detail.Answers[0].Value != "93.184.216.34" {
+		t.Errorf("Unexpected answers: %+v", detail.Answers)
+	}
+}
+
+func TestRunDNSTestBaseline(t *testing.T) {
+	resetFlags(t)
+	srv := newMockAPI(t, mockSuccessStatus())
+	apiURL = srv.URL
+	output = OutputJSON
+	baseline = true
+
+	var gotDomain string
+	querySystem = func(_ context.Context, domain, _ string, _ models.QueryOptions, _ int, _ time.Duration) models.DNSLookupResult {
+		gotDomain = domain
+		return models.DNSLookupResult{CommandStatus: "ok", RCode: "NOERROR", TimeMs: 3.2, Answers: []models.DNSAnswer{{Name: domain, Type: "A", TTL: 60, Value: "93.184.216.34"}}}
+	}
+	t.Cleanup(func() { querySystem = resolver.QuerySystem })
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	var got models.TaskStatusResponse
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("stdout is not valid JSON: %v\n%s", err, stdout)
+	}
+	if gotDomain != "example.com" {
+		t.Errorf("Expected the system resolver to be asked for example.com, got %q", gotDomain)
+	}
+	if len(got.Result.Details) != 2 || got.Result.Details[resolver.SystemTarget].TimeMs != 3.2 {
+		t.Errorf("Expected the baseline next to the tested server, got %v", got.Result.Details)
+	}
+
+	output = OutputText
+	stream = true
+	if err := runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"}); err == nil {
+		t.Error("Expected --baseline with --stream to be rejected")
+	}
+}
+
+func TestRunDNSTestEnvDefaults(t *testing.T) {
+	resetFlags(t)
+	t.Setenv(config.EnvDefaultQType, "mx")
+	t.Setenv(config.EnvDefaultServers, "9.9.9.9,tls://dns.quad9.net")
+
+	var got models.DNSLookupRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: mockTaskID})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(mockSuccessStatus())
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	apiURL = srv.URL
+	output = OutputJSON
+
+	// Flags left unset pick up the environment
+	qtype = ""
+	captureStdout(t, func() {
+		_ = runDNSTest(context.Background(), []string{"example.com"})
+	})
+	if got.QType != "MX" {
+		t.Errorf("Expected qtype MX from %s, got %q", config.EnvDefaultQType, got.QType)
+	}
+	if len(got.DNSServers) != 2 || got.DNSServers[0].Target != "udp://9.9.9.9" || got.DNSServers[1].Target != "tls://dns.quad9.net" {
+		t.Errorf("Expected servers from %s, got %+v", config.EnvDefaultServers, got.DNSServers)
+	}
+
+	// Explicit flags and servers win
+	qtype = "AAAA"
+	captureStdout(t, func() {
+		_ = runDNSTest(context.Background(), []string{"example.com", "udp://1.1.1.1:53"})
+	})
+	if got.QType != "AAAA" || len(got.DNSServers) != 1 || got.DNSServers[0].Target != "udp://1.1.1.1:53" {
+		t.Errorf("Expected the flag and argument to win, got %s %+v", got.QType, got.DNSServers)
+	}
+
+	qtype = ""
+	t.Setenv(config.EnvDefaultQType, "BOGUS")
+	if err := runDNSTest(context.Background(), []string{"example.com"}); err == nil {
+		t.Error("Expected an invalid DNS_TESTER_DEFAULT_QTYPE to be rejected")
+	}
+}
+
+func TestRunDNSTestQTypes(t *testing.T) {
+	resetFlags(t)
+
+	// Each task is named after its type and answers with a record of that type
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req models.DNSLookupRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requested = append(requested, req.QType)
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: req.QType})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, r *http.Request) {
+		status := mockSuccessStatus()
+		value := map[string]string{"A": "93.184.216.34", "MX": "10 mail.example.com."}[r.PathValue("taskID")]
+		status.Result.Details["udp://9.9.9.9:53"] = models.DNSLookupResult{
+			CommandStatus: "ok", RCode: "NOERROR", DNSProtocol: "Do53", TimeMs: 4,
+			Answers: []models.DNSAnswer{{Name: "example.com", Type: r.PathValue("taskID"), TTL: 300, Value: value}},
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	apiURL = srv.URL
+	output = OutputText
+
+	qtype = "a, mx,A"
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+	if len(requested) != 2 || requested[0] != "A" || requested[1] != "MX" {
+		t.Fatalf("Expected one A and one MX task, got %v", requested)
+	}
+	aSection := strings.Index(stdout, "== A records ==")
+	mxSection := strings.Index(stdout, "== MX records ==")
+	if aSection < 0 || mxSection < aSection {
+		t.Fatalf("Expected an A section followed by an MX section, got:\n%s", stdout)
+	}
+	if !strings.Contains(stdout[aSection:mxSection], "93.184.216.34") || !strings.Contains(stdout[mxSection:], "10 mail.example.com.") {
+		t.Errorf("Expected each section to show its records, got:\n%s", stdout)
+	}
+
+	// An IP still means a PTR lookup, whatever the list says
+	requested = nil
+	captureStdout(t, func() {
+		_ = runDNSTest(context.Background(), []string{"192.0.2.1", "udp://9.9.9.9:53"})
+	})
+	if len(requested) != 1 || requested[0] != "PTR" {
+		t.Errorf("Expected a single PTR task for an IP, got %v", requested)
+	}
+
+	for _, bad := range []func(){
+		func() { qtype = "A,BOGUS" },
+		func() { qtype = "A,MX"; output = OutputCSV },
+		func() { qtype = "A,MX"; output = OutputText; expect = []string{"192.0.2.1"} },
+	} {
+		resetFlags(t)
+		apiURL = srv.URL
+		bad()
+		if err := runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"}); err == nil {
+			t.Errorf("Expected qtype %q with output %s and expect %v to be rejected", qtype, output, expect)
+		}
+	}
+}
+
+func TestRunDNSTestInvalidOutput(t *testing.T) {
+	resetFlags(t)
+	output = "yaml"
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr == nil {
+		t.Fatal("Expected error for unsupported output format")
+	}
+	if stdout != "" {
+		t.Errorf("Expected no stdout, got %q", stdout)
+	}
+}
+
+func TestRunDNSTestCSVOutput(t *testing.T) {
+	resetFlags(t)
+	status := mockSuccessStatus()
+	status.Result.Details["udp://1.2.3.4:53"] = models.DNSLookupResult{
+		CommandStatus: "error",
+		DNSProtocol:   "Do53",
+		Error:         "query failed: timeout",
+	}
+	srv := newMockAPI(t, status)
+	apiURL = srv.URL
+	output = OutputCSV
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53", "udp://1.2.3.4:53"})
+	})
+	// The failed server still gets its CSV row, and the exit code reports it
+	var exitErr *ExitError
+	if !errors.As(runErr, &exitErr) || exitErr.Code != ExitServerError {
+		t.Fatalf("Expected exit code %d, got %v", ExitServerError, runErr)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	want := []string{
+		"server,protocol,rcode,time_ms,ttl,values,error",
+		"udp://1.2.3.4:53,Do53,,,,,query failed: timeout",
+		"udp://9.9.9.9:53,Do53,NOERROR,12.50,300,93.184.216.34,",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(want), len(lines), stdout)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], lines[i])
+		}
+	}
+}
+
+func TestRunWatch(t *testing.T) {
+	resetFlags(t)
+	var submissions, cacheable atomic.Int32
+	status := mockSuccessStatus()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		submissions.Add(1)
+		var req models.DNSLookupRequest
+		if json.NewDecoder(r.Body).Decode(&req) == nil && !req.QueryOptions.NoCache {
+			cacheable.Add(1)
+		}
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: mockTaskID})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiURL = srv.URL
+	output = OutputJSON
+
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+
+	var iterations int
+	captureStdout(t, func() {
+		iterations = runWatch(ctx, []string{"example.com", "udp://9.9.9.9:53"}, 20*time.Millisecond)
+	})
+
+	if iterations < 2 {
+		t.Errorf("Expected at least 2 iterations, got %d", iterations)
+	}
+	if submissions.Load() < 2 {
+		t.Errorf("Expected a lookup submission per iteration, got %d", submissions.Load())
+	}
+	// Cache hits would replay the first sample instead of querying the servers
+	if n := cacheable.Load(); n != 0 {
+		t.Errorf("Expected every submission to set no_cache, %d did not", n)
+	}
+}
+
+func TestGroupByAnswers(t *testing.T) {
+	resetFlags(t)
+	details := map[string]models.DNSLookupResult{
+		"udp://9.9.9.9:53": {
+			CommandStatus: "ok",
+			DNSProtocol:   "Do53",
+			Answers: []models.DNSAnswer{
+				{Type: "A", Value: "1.1.1.1"},
+				{Type: "A", Value: "2.2.2.2"},
+			},
+		},
+		"udp://8.8.8.8:53": {
+			CommandStatus: "ok",
+			DNSProtocol:   "Do53",
+			Answers: []models.DNSAnswer{
+				{Type: "A", Value: "2.2.2.2"},
+				{Type: "A", Value: "1.1.1.1"},
+			},
+		},
+		"udp://1.0.0.1:53": {
+			CommandStatus: "ok",
+			DNSProtocol:   "Do53",
+			Answers: []models.DNSAnswer{
+				{Type: "A", Value: "3.3.3.3"},
+			},
+		},
+		"udp://4.4.4.4:53": {CommandStatus: "error", Error: "timeout"},
+	}
+
+	groups := groupByAnswers(details, "A")
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 answer sets, got %d: %+v", len(groups), groups)
+	}
+
+	if got := strings.Join(groups[0].values, ","); got != "1.1.1.1,2.2.2.2" {
+		t.Errorf("Expected majority set 1.1.1.1,2.2.2.2, got %s", got)
+	}
+	if got := strings.Join(groups[0].servers, ","); got != "udp://8.8.8.8:53,udp://9.9.9.9:53" {
+		t.Errorf("Unexpected servers for majority set: %s", got)
+	}
+	if got := strings.Join(groups[1].servers, ","); got != "udp://1.0.0.1:53" {
+		t.Errorf("Unexpected servers for minority set: %s", got)
+	}
+
+	stdout := captureStdout(t, func() {
+		printDiff(details, "example.com", "A")
+	})
+	if !strings.Contains(stdout, "[WARN] Servers returned 2 different A record sets") {
+		t.Errorf("Expected disagreement warning, got:\n%s", stdout)
+	}
+}
+
+func TestPrintServerResultShowsAllAnswerTypes(t *testing.T) {
+	resetFlags(t)
+	result := models.DNSLookupResult{
+		CommandStatus: "ok",
+		TimeMs:        8,
+		RCode:         "NOERROR",
+		DNSProtocol:   "Do53",
+		Answers: []models.DNSAnswer{
+			{Name: "www.example.com", Type: "CNAME", TTL: 300, Value: "edge.example.net"},
+			{Name: "edge.example.net", Type: "A", TTL: 60, Value: "192.0.2.10"},
+		},
+	}
+
+	stdout := captureStdout(t, func() {
+		printServerResult("udp://9.9.9.9:53", result, false, "A")
+	})
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[OK] udp://9.9.9.9:53 - Do53") || !strings.Contains(lines[0], "192.0.2.10") {
+		t.Fatalf("Expected the A record on the status line, got:\n%s", stdout)
+	}
+	if lines[1] != "    CNAME: www.example.com → edge.example.net (TTL: 300)" {
+		t.Errorf("Expected the CNAME below the status line, got %q", lines[1])
+	}
+
+	// A type the server did not return no longer hides what it did return
+	stdout = captureStdout(t, func() {
+		printServerResult("udp://9.9.9.9:53", result, false, "AAAA")
+	})
+	for _, want := range []string{"No AAAA records found", "    CNAME: www.example.com → edge.example.net (TTL: 300)", "    A: 192.0.2.10 (TTL: 60)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output, got:\n%s", want, stdout)
+		}
+	}
+
+	// CNAMEs rendered as the via chain are not repeated
+	result.CNAMEChain = []string{"edge.example.net"}
+	stdout = captureStdout(t, func() {
+		printServerResult("udp://9.9.9.9:53", result, false, "A")
+	})
+	if !strings.Contains(stdout, "(via edge.example.net)") || strings.Contains(stdout, "CNAME:") {
+		t.Errorf("Expected the CNAME only in the via chain, got:\n%s", stdout)
+	}
+}
+
+func TestRunDNSTestRepeat(t *testing.T) {
+	resetFlags(t)
+	var submissions, cacheable atomic.Int32
+	status := mockSuccessStatus()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		submissions.Add(1)
+		var req models.DNSLookupRequest
+		if json.NewDecoder(r.Body).Decode(&req) == nil && !req.QueryOptions.NoCache {
+			cacheable.Add(1)
+		}
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: mockTaskID})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiURL = srv.URL
+	repeat = 3
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	if got := submissions.Load(); got != 3 {
+		t.Errorf("Expected 3 submissions, got %d", got)
+	}
+	if !strings.Contains(stdout, "udp://9.9.9.9:53 - Do53 - 3 samples (0 failed)") {
+		t.Errorf("Expected per-server distribution in output:\n%s", stdout)
+	}
+	// Cache hits would replay the first sample instead of querying the servers
+	if n := cacheable.Load(); n != 0 {
+		t.Errorf("Expected every submission to set no_cache, %d did not", n)
+	}
+}
+
+func TestRunDNSTestRepeatLimit(t *testing.T) {
+	resetFlags(t)
+	repeat = MaxRepeat + 1
+
+	if err := runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"}); err == nil {
+		t.Fatal("Expected error for --repeat above the limit")
+	}
+}
+
+func TestReadListFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolvers.txt")
+	content := "# public resolvers\nudp://9.9.9.9:53\n\n   \n  tls://dns.quad9.net  \n# https://disabled.example\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readListFile(path)
+	if err != nil {
+		t.Fatalf("readListFile failed: %v", err)
+	}
+
+	want := []string{"udp://9.9.9.9:53", "tls://dns.quad9.net"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := readListFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
+
+func TestRunDNSTestServersAndDomainsFiles(t *testing.T) {
+	resetFlags(t)
+	dir := t.TempDir()
+
+	var mu sync.Mutex
+	var requests []models.DNSLookupRequest
+	status := mockSuccessStatus()
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req models.DNSLookupRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: mockTaskID})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	apiURL = srv.URL
+
+	serversFile = filepath.Join(dir, "servers.txt")
+	domainsFile = filepath.Join(dir, "domains.txt")
+	if err := os.WriteFile(serversFile, []byte("# resolvers\nudp://1.1.1.1:53\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(domainsFile, []byte("example.org\n\n# skip me\nexample.net\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var runErr error
+	captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 lookups (1 positional + 2 from file), got %d", len(requests))
+	}
+	var domains []string
+	for _, req := range requests {
+		domains = append(domains, req.Domain)
+	}
+	if want := []string{"example.com", "example.org", "example.net"}; !reflect.DeepEqual(domains, want) {
+		t.Errorf("Expected domains %v, got %v", want, domains)
+	}
+	if got := len(requests[0].DNSServers); got != 2 {
+		t.Errorf("Expected positional and file servers combined (2), got %d", got)
+	}
+
+	// Invalid entries in the servers file are rejected like positional targets
+	if err := os.WriteFile(serversFile, []byte("ftp://bad.example\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com"})
+	})
+	if runErr == nil {
+		t.Error("Expected validation error for invalid server in file")
+	}
+}
+
+func TestRunDNSTestConfigErrors(t *testing.T) {
+	resetFlags(t)
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.yaml")
+	if err := os.WriteFile(empty, []byte("servers: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("servers: [\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	prevArgs := os.Args
+	defer func() { os.Args = prevArgs }()
+
+	tests := []struct {
+		config  string
+		wantErr string
+	}{
+		{invalid, "error loading config: "},
+		{empty, "no DNS servers found in config " + empty},
+	}
+	for _, tt := range tests {
+		os.Args = []string{"dnstestergo", "query", "example.com", "--config=" + tt.config}
+		err := runDNSTest(context.Background(), []string{"example.com"})
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.config, tt.wantErr, err)
+		}
+	}
+}
+
+func TestRunDNSTestTimeout(t *testing.T) {
+	resetFlags(t)
+	pending := models.TaskStatusResponse{TaskID: mockTaskID, Status: "PENDING"}
+	srv := newMockAPI(t, pending)
+	apiURL = srv.URL
+	pollInterval = 10 * time.Millisecond
+	lookupTimeout = 100 * time.Millisecond
+
+	start := time.Now()
+	var runErr error
+	captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+
+	if runErr == nil {
+		t.Fatal("Expected timeout error for a task that never completes")
+	}
+	if !strings.Contains(runErr.Error(), "timed out") {
+		t.Errorf("Expected timeout message, got %v", runErr)
+	}
+	if got := ExitCode(runErr); got != ExitSubmitError {
+		t.Errorf("Expected exit code %d, got %d", ExitSubmitError, got)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected timeout to stop polling promptly, took %s", elapsed)
+	}
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+)
+
+// NewConfigCommand creates the 'config' subcommand grouping config tooling
+func NewConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect and validate configuration files",
+	}
+	cmd.AddCommand(newConfigValidateCommand())
+	return cmd
+}
+
+func newConfigValidateCommand() *cobra.Command {
+	var configPath string
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a config file and report every problem",
+		Long:  `Load a config file, validate every server and its services, and check that the defaults are usable. Exits non-zero on any problem - suitable as a CI pre-flight check.`,
+		Example: `  # Validate the default config
+  dnstestergo config validate
+
+  # Validate a specific file in CI
+  dnstestergo config validate --config conf/config.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cmd.SilenceUsage = true
+			return runConfigValidate(cmd, configPath)
+		},
+	}
+
+	cmd.Flags().StringVarP(&configPath, "config", "c", os.Getenv("CONFIG_PATH"), "Path to config file")
+	return cmd
+}
+
+// runConfigValidate prints a report for configPath and returns an error if any problem was found.
+func runConfigValidate(cmd *cobra.Command, configPath string) error {
+	if configPath == "" {
+		configPath = "conf/config.yaml"
+	}
+	out := cmd.OutOrStdout()
+
+	// LoadConfig tolerates a missing file, but a pre-flight check should not
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file not found: %s", configPath)
+	}
+
+	cfg, err := config.ParseConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	if cfg.DNS.ResolveHostnames {
+		if err := cfg.ResolveHostnames(cmd.Context()); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	problems = append(problems, cfg.Check()...)
+	if len(problems) > 0 {
+		_, _ = fmt.Fprintf(out, "❌ %s: %d problem(s)\n", configPath, len(problems))
+		for _, p := range problems {
+			_, _ = fmt.Fprintf(out, "  - %v\n", p)
+		}
+		return fmt.Errorf("config validation failed: %d problem(s) in %s", len(problems), configPath)
+	}
+
+	if len(cfg.Servers) == 0 && cfg.DNS.UseDefaultPublicResolvers {
+		_, _ = fmt.Fprintf(out, "ℹ️  %s: no servers configured - using built-in public resolvers\n", configPath)
+	} else if len(cfg.Servers) == 0 {
+		_, _ = fmt.Fprintf(out, "⚠️  %s: no servers configured - lookups will require explicit targets\n", configPath)
+	}
+	_, _ = fmt.Fprintf(out, "✅ %s: %d server(s), %d DNS target(s)\n", configPath, len(cfg.Servers), len(cfg.GetDNSTargets()))
+	return nil
+}
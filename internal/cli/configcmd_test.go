@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runValidate(t *testing.T, yamlContent string) (string, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yamlContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	cmd := NewConfigCommand()
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs([]string{"validate", "--config", path})
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestConfigValidateValid(t *testing.T) {
+	out, err := runValidate(t, `
+servers:
+  - ip: "9.9.9.9"
+    hostname: "dns.quad9.net"
+    services: ["do53/udp", "dot", "doh"]
+`)
+	if err != nil {
+		t.Fatalf("Expected valid config to pass, got %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "3 DNS target(s)") {
+		t.Errorf("Expected target count in report, got:\n%s", out)
+	}
+}
+
+func TestConfigValidateInvalid(t *testing.T) {
+	out, err := runValidate(t, `
+servers:
+  - ip: "999.1.1.1"
+    services: ["do53/udp"]
+  - ip: "1.1.1.1"
+    port: 70000
+    services: ["do53/udp"]
+  - ip: "8.8.8.8"
+    services: ["dnscrypt"]
+`)
+	if err == nil {
+		t.Fatalf("Expected validation to fail, got success:\n%s", out)
+	}
+	if ExitCode(err) == 0 {
+		t.Error("Expected non-zero exit code")
+	}
+
+	// Every problem is reported, not just the first
+	for _, want := range []string{"invalid IP address: 999.1.1.1", "invalid port: 70000", "unknown service type 'dnscrypt'"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// Process exit codes for the query command, usable by Nagios-style checks.
+const (
+	// ExitOK means every server answered with NOERROR
+	ExitOK = 0
+	// ExitServerError means at least one server failed to answer
+	ExitServerError = 1
+	// ExitRCodeError means at least one server answered with a non-NOERROR rcode
+	ExitRCodeError = 2
+	// ExitSubmitError means the lookup could not be submitted or polled
+	ExitSubmitError = 3
+	// ExitAssertionError means at least one server's answers did not match --expect
+	ExitAssertionError = 4
+)
+
+// ExitError carries the process exit code for a failed query run.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	switch e.Code {
+	case ExitServerError:
+		return "one or more servers failed to answer"
+	case ExitRCodeError:
+		return "one or more servers returned a non-NOERROR rcode"
+	case ExitAssertionError:
+		return "one or more servers returned unexpected answers"
+	default:
+		return "query failed"
+	}
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// ExitCode maps an Execute error to a process exit code; unknown errors exit 1.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
+}
+
+// exitSeverity ranks codes so submission failures beat server errors, which beat
+// assertion mismatches, which beat rcode warnings.
+func exitSeverity(code int) int {
+	switch code {
+	case ExitSubmitError:
+		return 4
+	case ExitServerError:
+		return 3
+	case ExitAssertionError:
+		return 2
+	case ExitRCodeError:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// worseExit returns the more severe of two exit codes.
+func worseExit(a, b int) int {
+	if exitSeverity(b) > exitSeverity(a) {
+		return b
+	}
+	return a
+}
+
+// outcomeExitCode derives the exit code from a terminal task status.
+func outcomeExitCode(taskStatus *models.TaskStatusResponse) int {
+	if taskStatus == nil || taskStatus.Status != models.TaskStatusSuccess || taskStatus.Result == nil {
+		return ExitServerError
+	}
+
+	code := ExitOK
+	for _, result := range taskStatus.Result.Details {
+		if result.CommandStatus != "ok" {
+			code = worseExit(code, ExitServerError)
+		} else if result.AssertionPassed != nil && !*result.AssertionPassed {
+			code = worseExit(code, ExitAssertionError)
+		} else if result.RCode != "NOERROR" {
+			code = worseExit(code, ExitRCodeError)
+		}
+	}
+	return code
+}
+
+// exitErrorFor wraps a non-zero code into an ExitError, returning nil for ExitOK.
+func exitErrorFor(code int) error {
+	if code == ExitOK {
+		return nil
+	}
+	return &ExitError{Code: code}
+}
@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestQueryCommandExitCodes(t *testing.T) {
+	success := mockSuccessStatus()
+
+	nxdomain := mockSuccessStatus()
+	nxdomain.Result.Details["udp://9.9.9.9:53"] = models.DNSLookupResult{
+		CommandStatus: "ok",
+		RCode:         "NXDOMAIN",
+		DNSProtocol:   "Do53",
+	}
+
+	failed := mockSuccessStatus()
+	failed.Result.Details["udp://8.8.8.8:53"] = models.DNSLookupResult{
+		CommandStatus: "error",
+		Error:         "i/o timeout",
+		DNSProtocol:   "Do53",
+	}
+
+	passed := false
+	mismatch := mockSuccessStatus()
+	mismatch.Result.Details["udp://9.9.9.9:53"] = models.DNSLookupResult{
+		CommandStatus:   "ok",
+		RCode:           "NOERROR",
+		DNSProtocol:     "Do53",
+		AssertionPassed: &passed,
+	}
+
+	tests := []struct {
+		name   string
+		status *models.TaskStatusResponse
+		flags  []string
+		want   int
+	}{
+		{name: "all NOERROR", status: &success, want: ExitOK},
+		{name: "server error", status: &failed, want: ExitServerError},
+		{name: "non-NOERROR rcode", status: &nxdomain, want: ExitRCodeError},
+		{name: "submission failure", status: nil, want: ExitSubmitError},
+		{name: "assertion mismatch", status: &mismatch, flags: []string{"--expect", "192.0.2.1"}, want: ExitAssertionError},
+		{name: "ignore errors", status: &failed, flags: []string{"--ignore-errors"}, want: ExitOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetFlags(t)
+
+			url := "http://127.0.0.1:1"
+			if tt.status != nil {
+				url = newMockAPI(t, *tt.status).URL
+			}
+
+			cmd := NewQueryCommand()
+			cmd.SetArgs(append([]string{"-u", url, "example.com", "udp://9.9.9.9:53"}, tt.flags...))
+			var err error
+			captureStdout(t, func() {
+				err = cmd.Execute()
+			})
+
+			if got := ExitCode(err); got != tt.want {
+				t.Errorf("Expected exit code %d, got %d (err: %v)", tt.want, got, err)
+			}
+		})
+	}
+}
+
+func TestWorseExit(t *testing.T) {
+	if got := worseExit(ExitRCodeError, ExitServerError); got != ExitServerError {
+		t.Errorf("Expected server error to beat rcode error, got %d", got)
+	}
+	if got := worseExit(ExitSubmitError, ExitServerError); got != ExitSubmitError {
+		t.Errorf("Expected submission error to beat server error, got %d", got)
+	}
+	if got := worseExit(ExitAssertionError, ExitRCodeError); got != ExitAssertionError {
+		t.Errorf("Expected assertion error to beat rcode error, got %d", got)
+	}
+	if got := ExitCode(errors.New("boom")); got != 1 {
+		t.Errorf("Expected generic errors to exit 1, got %d", got)
+	}
+}
+
+func TestParseExpect(t *testing.T) {
+	got, err := parseExpect([]string{"192.0.2.1", "aaaa=2001:db8::1", "TXT=v=spf1 -all", "v=spf1 -all"}, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"A":    {"192.0.2.1", "v=spf1 -all"},
+		"AAAA": {"2001:db8::1"},
+		"TXT":  {"v=spf1 -all"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	if _, err := parseExpect([]string{"A="}, "A"); err == nil {
+		t.Error("Expected error for empty value")
+	}
+	if got, _ := parseExpect(nil, "A"); got != nil {
+		t.Errorf("Expected nil map without --expect, got %v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/api"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+const (
+	// fingerprintVersion is the CHAOS TXT name most resolvers answer with their software version
+	fingerprintVersion = "version.bind"
+	// fingerprintID is the CHAOS TXT name identifying the answering instance (RFC 4892)
+	fingerprintID = "id.server"
+	// noFingerprint is reported when a server refuses or ignores a fingerprint query
+	noFingerprint = "no response"
+)
+
+// serverFingerprint holds the identification strings of one server.
+type serverFingerprint struct {
+	Server  string `json:"server"`
+	Version string `json:"version"`
+	ID      string `json:"id"`
+}
+
+// runFingerprint queries version.bind and id.server TXT in the CHAOS class against every
+// server and prints what each one reports. Refusals are expected and do not fail the run.
+func runFingerprint(ctx context.Context) error {
+	if output == OutputCSV {
+		return fmt.Errorf("--fingerprint does not support csv output (use text or json)")
+	}
+	if len(dnsServers) == 0 {
+		return fmt.Errorf("no DNS servers to fingerprint (pass targets, --servers-file or --config)")
+	}
+
+	progressf("Fingerprinting %d server(s) ", len(dnsServers))
+	client := api.NewClient(apiURL, 30*time.Second, insecure)
+
+	details := make(map[string]map[string]models.DNSLookupResult, 2)
+	for _, name := range []string{fingerprintVersion, fingerprintID} {
+		req := models.DNSLookupRequest{
+			Domain:       name,
+			DNSServers:   buildDNSServers(dnsServers),
+			QType:        "TXT",
+			QueryOptions: queryOptionsFromFlags(),
+		}
+		req.QueryOptions.QClass = models.QClassCH
+		taskStatus, err := submitAndWait(ctx, client, req)
+		if err != nil {
+			return err
+		}
+		if taskStatus.Result != nil {
+			details[name] = taskStatus.Result.Details
+		}
+	}
+	progressf("\n")
+
+	// Servers come from the version.bind task so the order matches the other text output
+	var fingerprints []serverFingerprint
+	for _, item := range sortResults(details[fingerprintVersion]) {
+		id, ok := details[fingerprintID][item.server]
+		fingerprints = append(fingerprints, serverFingerprint{
+			Server:  item.server,
+			Version: fingerprintValue(item.result, true),
+			ID:      fingerprintValue(id, ok),
+		})
+	}
+
+	if output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(fingerprints)
+	}
+
+	for _, fp := range fingerprints {
+		level := levelInfo
+		if fp.Version == noFingerprint && fp.ID == noFingerprint {
+			level = levelWarn
+		}
+		logResult(level, fmt.Sprintf("%s - version: %s, id: %s", fp.Server, fp.Version, fp.ID))
+	}
+	return nil
+}
+
+// fingerprintValue joins the TXT strings of a fingerprint answer, or reports why there are none.
+func fingerprintValue(result models.DNSLookupResult, ok bool) string {
+	if !ok || result.CommandStatus != "ok" {
+		return noFingerprint
+	}
+
+	var values []string
+	for _, ans := range filterAnswers(result.Answers, "TXT") {
+		values = append(values, ans.Value)
+	}
+	if len(values) > 0 {
+		return strings.Join(values, ", ")
+	}
+	if result.RCode != "" && result.RCode != "NOERROR" {
+		return fmt.Sprintf("%s (%s)", noFingerprint, result.RCode)
+	}
+	return noFingerprint
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// newFingerprintAPI answers version.bind and id.server tasks: 9.9.9.9 reports both, 8.8.8.8 refuses.
+func newFingerprintAPI(t *testing.T) (*httptest.Server, func() []models.DNSLookupRequest) {
+	t.Helper()
+
+	txt := func(name, value string) models.DNSLookupResult {
+		return models.DNSLookupResult{
+			CommandStatus: "ok",
+			RCode:         "NOERROR",
+			QType:         "TXT",
+			Answers:       []models.DNSAnswer{{Name: name, Type: "TXT", Value: value}},
+		}
+	}
+	refused := models.DNSLookupResult{CommandStatus: "ok", RCode: "REFUSED", QType: "TXT"}
+	results := map[string]map[string]models.DNSLookupResult{
+		fingerprintVersion: {
+			"udp://9.9.9.9:53": txt(fingerprintVersion, "Q9-P-7.4"),
+			"udp://8.8.8.8:53": refused,
+		},
+		fingerprintID: {
+			"udp://9.9.9.9:53": txt(fingerprintID, "res120.fra.rrdns.pch.net"),
+			"udp://8.8.8.8:53": {CommandStatus: "error", Error: "query failed: i/o timeout"},
+		},
+	}
+
+	var mu sync.Mutex
+	var requests []models.DNSLookupRequest
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req models.DNSLookupRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		requests = append(requests, req)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: req.Domain})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("taskID")
+		_ = json.NewEncoder(w).Encode(models.TaskStatusResponse{
+			TaskID: id,
+			Status: "SUCCESS",
+			Result: &models.DNSLookupResults{Details: results[id]},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, func() []models.DNSLookupRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]models.DNSLookupRequest(nil), requests...)
+	}
+}
+
+func TestRunDNSTestFingerprint(t *testing.T) {
+	resetFlags(t)
+	srv, requests := newFingerprintAPI(t)
+	apiURL = srv.URL
+	fingerprint = true
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"udp://9.9.9.9:53", "udp://8.8.8.8:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	sent := requests()
+	if len(sent) != 2 {
+		t.Fatalf("Expected 2 lookups, got %d", len(sent))
+	}
+	for _, req := range sent {
+		if req.QType != "TXT" || req.QueryOptions.QClass != models.QClassCH || len(req.DNSServers) != 2 {
+			t.Errorf("Expected CH TXT lookup against both servers, got %+v", req)
+		}
+	}
+
+	for _, want := range []string{
+		"udp://9.9.9.9:53 - version: Q9-P-7.4, id: res120.fra.rrdns.pch.net",
+		"udp://8.8.8.8:53 - version: no response (REFUSED), id: no response",
+	} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestFingerprintValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		result models.DNSLookupResult
+		ok     bool
+		want   string
+	}{
+		{"missing", models.DNSLookupResult{}, false, noFingerprint},
+		{"failed", models.DNSLookupResult{CommandStatus: "error"}, true, noFingerprint},
+		{"empty answer", models.DNSLookupResult{CommandStatus: "ok", RCode: "NOERROR"}, true, noFingerprint},
+		{"multiple strings", models.DNSLookupResult{CommandStatus: "ok", RCode: "NOERROR", Answers: []models.DNSAnswer{
+			{Type: "TXT", Value: "unbound 1.19"}, {Type: "TXT", Value: "edge"},
+		}}, true, "unbound 1.19, edge"},
+	}
+	for _, tt := range tests {
+		if got := fingerprintValue(tt.result, tt.ok); got != tt.want {
+			t.Errorf("%s: fingerprintValue() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/api"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
+)
+
+// parseQTypes splits a comma-separated --qtype into upper-cased record types, dropping duplicates.
+func parseQTypes(value string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !normalize.IsValidQType(t) {
+			return nil, fmt.Errorf("invalid query type '%s'", t)
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		return nil, fmt.Errorf("--qtype must name at least one record type")
+	}
+	return types, nil
+}
+
+// typeStatus is the outcome of the lookup of one record type.
+type typeStatus struct {
+	QType  string                     `json:"qtype"`
+	Status *models.TaskStatusResponse `json:"status"`
+}
+
+// runQTypes looks up every record type of --qtype, one task per type since a request carries
+// a single qtype, and prints the results grouped by type. req is the lookup of the first type.
+func runQTypes(ctx context.Context, client *api.Client, req models.DNSLookupRequest, qtypes []string) error {
+	expected, err := parseExpect(expect, "")
+	if err != nil {
+		return err
+	}
+	if _, ok := expected[""]; ok {
+		return fmt.Errorf("--expect values need a TYPE= prefix (e.g. A=192.0.2.1) with several query types")
+	}
+
+	code := ExitOK
+	statuses := make([]typeStatus, 0, len(qtypes))
+	for _, recordType := range qtypes {
+		req.QType = recordType
+		req.Expected = nil
+		if values, ok := expected[recordType]; ok {
+			req.Expected = map[string][]string{recordType: values}
+		}
+
+		taskStatus, err := submitAndWait(ctx, client, req)
+		if err != nil {
+			return err
+		}
+		addBaseline(ctx, taskStatus, req)
+		code = worseExit(code, outcomeExitCode(taskStatus))
+		statuses = append(statuses, typeStatus{QType: recordType, Status: taskStatus})
+	}
+
+	if output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(statuses); err != nil {
+			return err
+		}
+		return exitErrorFor(code)
+	}
+
+	for _, s := range statuses {
+		fmt.Printf("\n== %s records ==", s.QType)
+		if err := printTaskStatus(s.Status, req.Domain, s.QType); err != nil {
+			return err
+		}
+	}
+	return exitErrorFor(code)
+}
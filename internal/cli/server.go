@@ -2,15 +2,21 @@ package cli
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sudo-tiz/dns-tester-go/internal/app"
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/logging"
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
 )
 
 const (
@@ -20,29 +26,41 @@ const (
 	DefaultCleanupInterval = 10 * time.Minute
 )
 
-// NewServerCommand creates server subcommand with Cobra.
-// Starts in-memory workers if Redis not configured.
-func NewServerCommand() *cobra.Command {
-	var configPath string
-	var redisURL string
-	var host string
-	var port string
-	var maxWorkers int
+// serverFlags holds the server command's flags; zero values mean "use the config".
+type serverFlags struct {
+	configPath       string
+	redisURL         string
+	host             string
+	port             string
+	unixSocket       string
+	noTCP            bool
+	tlsCert          string
+	tlsKey           string
+	httpRedirectPort string
+	tlsClientCA      string
+	maxWorkers       int
+	logFormat        string
 
 	// DNS config flags
-	var dnsTimeout int
-	var maxServersPerReq int
-	var maxConcurrentQueries int
-	var maxRetries int
+	dnsTimeout           int
+	maxServersPerReq     int
+	maxConcurrentQueries int
+	maxRetries           int
 
 	// Rate limiting flags
-	var rateLimitRPS int
-	var rateLimitBurst int
+	rateLimitRPS   int
+	rateLimitBurst int
 
 	// Server timeout flags
-	var readTimeout int
-	var writeTimeout int
-	var idleTimeout int
+	readTimeout  int
+	writeTimeout int
+	idleTimeout  int
+}
+
+// NewServerCommand creates server subcommand with Cobra.
+// Starts in-memory workers if Redis not configured.
+func NewServerCommand() *cobra.Command {
+	var f serverFlags
 
 	cmd := &cobra.Command{
 		Use:   "server",
@@ -60,104 +78,119 @@ func NewServerCommand() *cobra.Command {
   # Start on custom host/port
   dnstestergo server --host 0.0.0.0 --port 8080
 
+  # Serve only on a Unix socket, e.g. behind a local reverse proxy
+  dnstestergo server --unix-socket /run/dnstester/api.sock --no-tcp
+
+  # Serve HTTPS, redirecting plain HTTP on port 80 (send SIGHUP after renewing the cert)
+  dnstestergo server --port 443 --tls-cert cert.pem --tls-key key.pem --http-redirect-port 80
+
   # Override DNS settings
   dnstestergo server --dns-timeout 10 --max-retries 5`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return runServer(cmd, configPath, redisURL, host, port, maxWorkers,
-				dnsTimeout, maxServersPerReq, maxConcurrentQueries, maxRetries,
-				rateLimitRPS, rateLimitBurst, readTimeout, writeTimeout, idleTimeout)
+			if f.noTCP && f.unixSocket == "" {
+				return fmt.Errorf("--no-tcp requires --unix-socket")
+			}
+			return runServer(cmd, &f)
 		},
 	}
 
-	cmd.Flags().StringVarP(&configPath, "config", "c", os.Getenv("CONFIG_PATH"), "Path to config file")
-	cmd.Flags().StringVarP(&redisURL, "redis", "r", os.Getenv("REDIS_URL"), "Redis URL (optional, enables distributed workers)")
-	cmd.Flags().StringVarP(&host, "host", "H", os.Getenv("DNS_TESTER_HOST"), "Server host (default: from config or 0.0.0.0)")
-	cmd.Flags().StringVarP(&port, "port", "P", os.Getenv("DNS_TESTER_PORT"), "Server port (default: from config or 5000)")
-	cmd.Flags().IntVarP(&maxWorkers, "workers", "w", 0, "Maximum number of workers (default: from config or 4)")
+	cmd.Flags().StringVarP(&f.configPath, "config", "c", os.Getenv("CONFIG_PATH"), "Path to config file")
+	cmd.Flags().StringVarP(&f.redisURL, "redis", "r", os.Getenv("REDIS_URL"), "Redis URL (optional, enables distributed workers)")
+	cmd.Flags().StringVarP(&f.host, "host", "H", os.Getenv("DNS_TESTER_HOST"), "Server host (default: from config or 0.0.0.0)")
+	cmd.Flags().StringVarP(&f.port, "port", "P", os.Getenv("DNS_TESTER_PORT"), "Server port (default: from config or 5000)")
+	cmd.Flags().StringVar(&f.unixSocket, "unix-socket", os.Getenv("DNS_TESTER_UNIX_SOCKET"), "Also serve the API on this Unix domain socket (removed on shutdown)")
+	cmd.Flags().BoolVar(&f.noTCP, "no-tcp", false, "Serve only on --unix-socket, without the TCP listener")
+	cmd.Flags().StringVar(&f.tlsCert, "tls-cert", os.Getenv("DNS_TESTER_TLS_CERT"), "TLS certificate file; with --tls-key serves HTTPS (re-read on SIGHUP)")
+	cmd.Flags().StringVar(&f.tlsKey, "tls-key", os.Getenv("DNS_TESTER_TLS_KEY"), "TLS private key file for --tls-cert")
+	cmd.Flags().StringVar(&f.httpRedirectPort, "http-redirect-port", "", "Also listen for plain HTTP on this port and redirect it to HTTPS")
+	cmd.Flags().StringVar(&f.tlsClientCA, "tls-client-ca", os.Getenv("DNS_TESTER_TLS_CLIENT_CA"), "CA bundle file; require HTTPS clients to present a certificate it signed (mTLS)")
+	cmd.Flags().IntVarP(&f.maxWorkers, "workers", "w", 0, "Maximum number of workers (default: from config or 4)")
+	cmd.Flags().StringVar(&f.logFormat, "log-format", os.Getenv("LOG_FORMAT"), "Log format: text or json (default: from config or text)")
 
 	// DNS configuration
-	cmd.Flags().IntVarP(&dnsTimeout, "dns-timeout", "T", 0, "DNS query timeout in seconds (default: from config or 5)")
-	cmd.Flags().IntVarP(&maxServersPerReq, "max-servers", "S", 0, "Maximum DNS servers per request (default: from config or 50)")
-	cmd.Flags().IntVarP(&maxConcurrentQueries, "max-concurrent", "C", 0, "Maximum concurrent DNS queries (default: from config or 500)")
-	cmd.Flags().IntVarP(&maxRetries, "max-retries", "R", 0, "Number of retries per DNS query (default: from config or 3)")
+	cmd.Flags().IntVarP(&f.dnsTimeout, "dns-timeout", "T", 0, "DNS query timeout in seconds (default: from config or 5)")
+	cmd.Flags().IntVarP(&f.maxServersPerReq, "max-servers", "S", 0, "Maximum DNS servers per request (default: from config or 50)")
+	cmd.Flags().IntVarP(&f.maxConcurrentQueries, "max-concurrent", "C", 0, "Maximum concurrent DNS queries (default: from config or 500)")
+	cmd.Flags().IntVarP(&f.maxRetries, "max-retries", "R", 0, "Number of retries per DNS query (default: from config or 3)")
 
 	// Rate limiting
-	cmd.Flags().IntVar(&rateLimitRPS, "rate-limit-rps", 0, "Rate limit requests per second (0 = disable, default: from config or 10)")
-	cmd.Flags().IntVar(&rateLimitBurst, "rate-limit-burst", 0, "Rate limit burst size (default: from config or 20)")
+	cmd.Flags().IntVar(&f.rateLimitRPS, "rate-limit-rps", 0, "Rate limit requests per second (0 = disable, default: from config or 10)")
+	cmd.Flags().IntVar(&f.rateLimitBurst, "rate-limit-burst", 0, "Rate limit burst size (default: from config or 20)")
 
 	// HTTP server timeouts
-	cmd.Flags().IntVar(&readTimeout, "read-timeout", 0, "HTTP read timeout in seconds (default: from config or 15)")
-	cmd.Flags().IntVar(&writeTimeout, "write-timeout", 0, "HTTP write timeout in seconds (default: from config or 15)")
-	cmd.Flags().IntVar(&idleTimeout, "idle-timeout", 0, "HTTP idle timeout in seconds (default: from config or 60)")
+	cmd.Flags().IntVar(&f.readTimeout, "read-timeout", 0, "HTTP read timeout in seconds (default: from config or 15)")
+	cmd.Flags().IntVar(&f.writeTimeout, "write-timeout", 0, "HTTP write timeout in seconds (default: from config or 15)")
+	cmd.Flags().IntVar(&f.idleTimeout, "idle-timeout", 0, "HTTP idle timeout in seconds (default: from config or 60)")
 
 	return cmd
 }
 
-func runServer(cmd *cobra.Command, configPath, redisURL, host, port string, maxWorkers,
-	dnsTimeout, maxServersPerReq, maxConcurrentQueries, maxRetries,
-	rateLimitRPS, rateLimitBurst, readTimeout, writeTimeout, idleTimeout int) error {
-
+func runServer(cmd *cobra.Command, f *serverFlags) error {
 	// Load config
+	configPath := f.configPath
 	if configPath == "" {
 		configPath = "conf/config.yaml"
 	}
-	cfg, err := config.LoadConfig(configPath)
+	// Reloads (SIGHUP) re-apply the same CLI overrides on top of the file
+	loadConfig := func() (*config.APIConfig, error) {
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			return nil, err
+		}
+		applyServerOverrides(cmd, cfg, f)
+		return cfg, nil
+	}
+
+	cfg, err := loadConfig()
 	if err != nil {
 		slog.Error("Failed to load config", "error", err)
 		os.Exit(1)
 	}
-	if host != "" {
-		cfg.Server.Host = host
-	}
-	if port != "" {
-		cfg.Server.Port = port
-	}
-	if cmd.Flags().Changed("workers") {
-		cfg.Worker.MaxWorkers = maxWorkers
-	}
-	if cmd.Flags().Changed("dns-timeout") {
-		cfg.DNS.Timeout = dnsTimeout
-	}
-	if cmd.Flags().Changed("max-servers") {
-		cfg.DNS.MaxServersPerReq = maxServersPerReq
-	}
-	if cmd.Flags().Changed("max-concurrent") {
-		cfg.DNS.MaxConcurrentQueries = maxConcurrentQueries
-	}
-	if cmd.Flags().Changed("max-retries") {
-		cfg.DNS.MaxRetries = maxRetries
-	}
-	if cmd.Flags().Changed("rate-limit-rps") {
-		cfg.RateLimiting.RequestsPerSecond = rateLimitRPS
-	}
-	if cmd.Flags().Changed("rate-limit-burst") {
-		cfg.RateLimiting.BurstSize = rateLimitBurst
+
+	if err := logging.Setup(cfg.GetLogFormat(), os.Stderr); err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
 	}
-	if cmd.Flags().Changed("read-timeout") {
-		cfg.Server.ReadTimeout = readTimeout
+
+	if err := metrics.SetDurationBuckets(cfg.GetMetricsBuckets()); err != nil {
+		slog.Error("Failed to apply metrics buckets", "error", err)
+		os.Exit(1)
 	}
-	if cmd.Flags().Changed("write-timeout") {
-		cfg.Server.WriteTimeout = writeTimeout
+
+	if err := resolver.SetBootstrap(cfg.GetBootstrapResolvers(), time.Duration(cfg.GetDNSTimeout())*time.Second); err != nil {
+		slog.Error("Failed to set up bootstrap resolvers", "error", err)
+		os.Exit(1)
 	}
-	if cmd.Flags().Changed("idle-timeout") {
-		cfg.Server.IdleTimeout = idleTimeout
+	resolver.SetCache(cfg.GetCacheMaxTTL())
+
+	shutdownTracing, err := tracing.Setup(context.Background(), "dnstester-api")
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
 	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Tracing shutdown error", "error", err)
+		}
+	}()
 
 	// Log configuration status
-	if len(cfg.Servers) == 0 {
+	if len(cfg.Servers) == 0 && cfg.DNS.UseDefaultPublicResolvers {
+		slog.Info("No DNS servers configured - using built-in public resolvers", "path", configPath, "servers_count", len(config.DefaultPublicResolvers))
+	} else if len(cfg.Servers) == 0 {
 		slog.Warn("No DNS servers configured - API will work but DNS lookups will require explicit targets", "path", configPath)
 	} else {
 		slog.Info("Configuration loaded", "path", configPath, "servers_count", len(cfg.Servers))
 	}
 
-	if redisURL == "" {
+	if f.redisURL == "" {
 		slog.Info("Redis not configured - starting in memory mode (no task persistence)")
 	} else {
-		slog.Info("Redis configured", "url", redisURL)
+		slog.Info("Redis configured", "url", f.redisURL)
 	}
 
 	// Create and start API app
-	apiApp, err := app.NewAPIApp(cfg, redisURL)
+	apiApp, err := app.NewAPIApp(cfg, f.redisURL)
 	if err != nil {
 		slog.Error("Failed to create API app", "error", err)
 		os.Exit(1)
@@ -169,6 +202,7 @@ func runServer(cmd *cobra.Command, configPath, redisURL, host, port string, maxW
 	}()
 
 	// Resolve address and start server
+	host, port := f.host, f.port
 	if host == "" {
 		host = cfg.GetServerHost()
 	}
@@ -176,21 +210,121 @@ func runServer(cmd *cobra.Command, configPath, redisURL, host, port string, maxW
 		port = cfg.GetServerPort()
 	}
 	addr := host + ":" + port
+	if f.noTCP {
+		addr = ""
+	}
 
 	go func() {
-		slog.Info("Starting DNS Tester API server", "address", addr)
-		if err := apiApp.Run(addr); err != nil {
+		slog.Info("Starting DNS Tester API server", "address", addr, "unix_socket", f.unixSocket, "tls", cfg.Server.TLSEnabled())
+		if err := apiApp.Run(addr, f.unixSocket); err != nil {
 			slog.Error("API app run failed", "error", err)
 			os.Exit(1)
 		}
 	}()
 
-	// Wait for shutdown signal
-	stop := make(chan os.Signal, 1)
-	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+	// Wait for shutdown signal; SIGHUP reloads the config without downtime
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigs {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		newCfg, err := loadConfig()
+		if err != nil {
+			slog.Error("Config reload failed - keeping previous config", "path", configPath, "error", err)
+			continue
+		}
+		apiApp.ReloadConfig(newCfg)
+		slog.Info("Configuration reloaded", "path", configPath, "servers_count", len(newCfg.Servers))
+		if changed := restartOnlyChanges(cfg, newCfg); len(changed) > 0 {
+			slog.Warn("Changed settings only apply after a restart", "settings", changed)
+		}
+		cfg = newCfg
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	return apiApp.Shutdown(ctx)
 }
+
+// restartOnlyChanges lists the settings that differ between prev and next but are only applied
+// at startup: the result cache, bootstrap resolvers, metrics buckets, log format and worker pool.
+func restartOnlyChanges(prev, next *config.APIConfig) []string {
+	var changed []string
+	if prev.GetCacheMaxTTL() != next.GetCacheMaxTTL() {
+		changed = append(changed, "dns.cache_max_ttl")
+	}
+	if !slices.Equal(prev.GetBootstrapResolvers(), next.GetBootstrapResolvers()) {
+		changed = append(changed, "dns.bootstrap_resolvers")
+	}
+	if !slices.Equal(prev.GetMetricsBuckets(), next.GetMetricsBuckets()) {
+		changed = append(changed, "metrics.buckets")
+	}
+	if prev.GetLogFormat() != next.GetLogFormat() {
+		changed = append(changed, "log.format")
+	}
+	if prev.GetMaxWorkers() != next.GetMaxWorkers() {
+		changed = append(changed, "worker.max_workers")
+	}
+	return changed
+}
+
+// applyServerOverrides applies CLI flags on top of the loaded config (flags win).
+func applyServerOverrides(cmd *cobra.Command, cfg *config.APIConfig, f *serverFlags) {
+	if f.host != "" {
+		cfg.Server.Host = f.host
+	}
+	if f.port != "" {
+		cfg.Server.Port = f.port
+	}
+	if f.tlsCert != "" {
+		cfg.Server.TLSCert = f.tlsCert
+	}
+	if f.tlsKey != "" {
+		cfg.Server.TLSKey = f.tlsKey
+	}
+	if f.httpRedirectPort != "" {
+		cfg.Server.HTTPRedirectPort = f.httpRedirectPort
+	}
+	if f.tlsClientCA != "" {
+		cfg.Server.TLSClientCA = f.tlsClientCA
+	}
+	if f.logFormat != "" {
+		cfg.Log.Format = f.logFormat
+	}
+	if cmd.Flags().Changed("workers") {
+		cfg.Worker.MaxWorkers = f.maxWorkers
+	}
+	if cmd.Flags().Changed("dns-timeout") {
+		cfg.DNS.Timeout = f.dnsTimeout
+	}
+	if cmd.Flags().Changed("max-servers") {
+		cfg.DNS.MaxServersPerReq = f.maxServersPerReq
+	}
+	if cmd.Flags().Changed("max-concurrent") {
+		cfg.DNS.MaxConcurrentQueries = f.maxConcurrentQueries
+	}
+	if cmd.Flags().Changed("max-retries") {
+		cfg.DNS.MaxRetries = f.maxRetries
+	}
+	if cmd.Flags().Changed("rate-limit-rps") {
+		// Replaces the default_rps alias too; 0 disables every tier
+		cfg.RateLimiting.RequestsPerSecond = f.rateLimitRPS
+		cfg.RateLimiting.DefaultRPS = f.rateLimitRPS
+		if f.rateLimitRPS == 0 {
+			cfg.RateLimiting.KeyedRPS = 0
+		}
+	}
+	if cmd.Flags().Changed("rate-limit-burst") {
+		cfg.RateLimiting.BurstSize = f.rateLimitBurst
+	}
+	if cmd.Flags().Changed("read-timeout") {
+		cfg.Server.ReadTimeout = f.readTimeout
+	}
+	if cmd.Flags().Changed("write-timeout") {
+		cfg.Server.WriteTimeout = f.writeTimeout
+	}
+	if cmd.Flags().Changed("idle-timeout") {
+		cfg.Server.IdleTimeout = f.idleTimeout
+	}
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+)
+
+func TestRestartOnlyChanges(t *testing.T) {
+	prev := &config.APIConfig{DNS: config.DNSConfig{Timeout: 5, CacheMaxTTL: 30}}
+
+	next := &config.APIConfig{DNS: config.DNSConfig{Timeout: 10, CacheMaxTTL: 30}}
+	if changed := restartOnlyChanges(prev, next); len(changed) != 0 {
+		t.Errorf("Expected reloadable changes only, got %v", changed)
+	}
+
+	next = &config.APIConfig{
+		DNS:    config.DNSConfig{CacheMaxTTL: 60, BootstrapResolvers: []string{"9.9.9.9"}},
+		Worker: config.WorkerConfig{MaxWorkers: 8},
+	}
+	want := []string{"dns.cache_max_ttl", "dns.bootstrap_resolvers", "worker.max_workers"}
+	if changed := restartOnlyChanges(prev, next); !slices.Equal(changed, want) {
+		t.Errorf("Expected %v, got %v", want, changed)
+	}
+}
+
+func TestApplyServerOverrides(t *testing.T) {
+	// Numeric flags only override the config when given, so mark them as changed
+	cmd := NewServerCommand()
+	for name, value := range map[string]string{"max-retries": "0", "read-timeout": "30"} {
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatalf("Set(%s) failed: %v", name, err)
+		}
+	}
+	f := &serverFlags{port: "8443", tlsCert: "cert.pem", tlsKey: "key.pem", maxRetries: 0, readTimeout: 30}
+
+	cfg := &config.APIConfig{DNS: config.DNSConfig{MaxRetries: 5, Timeout: 7}}
+	applyServerOverrides(cmd, cfg, f)
+	if cfg.Server.Port != "8443" || cfg.Server.TLSCert != "cert.pem" || cfg.Server.TLSKey != "key.pem" {
+		t.Errorf("Expected the server flags applied, got %+v", cfg.Server)
+	}
+	if cfg.DNS.MaxRetries != 0 || cfg.Server.ReadTimeout != 30 {
+		t.Errorf("Expected explicitly set flags to win, got max_retries %d and read_timeout %d", cfg.DNS.MaxRetries, cfg.Server.ReadTimeout)
+	}
+	if cfg.DNS.Timeout != 7 {
+		t.Errorf("Expected unset flags to keep the config, got timeout %d", cfg.DNS.Timeout)
+	}
+}
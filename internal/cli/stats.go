@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// latencyStats summarizes a set of response times in milliseconds.
+type latencyStats struct {
+	Count  int
+	Min    float64
+	Max    float64
+	Mean   float64
+	Median float64
+	P95    float64
+	P99    float64
+}
+
+// computeStats returns aggregate latency figures; zero value when samples is empty.
+func computeStats(samples []float64) latencyStats {
+	if len(samples) == 0 {
+		return latencyStats{}
+	}
+
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return latencyStats{
+		Count:  len(sorted),
+		Min:    sorted[0],
+		Max:    sorted[len(sorted)-1],
+		Mean:   sum / float64(len(sorted)),
+		Median: percentile(sorted, 50),
+		P95:    percentile(sorted, 95),
+		P99:    percentile(sorted, 99),
+	}
+}
+
+// percentile uses the nearest-rank method on an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// successfulTimings collects TimeMs of successful queries, overall and per protocol.
+func successfulTimings(details map[string]models.DNSLookupResult) ([]float64, map[string][]float64) {
+	var all []float64
+	byProto := make(map[string][]float64)
+	for _, result := range details {
+		if result.CommandStatus != "ok" {
+			continue
+		}
+		all = append(all, result.TimeMs)
+		proto := result.DNSProtocol
+		if proto == "" {
+			proto = "unknown"
+		}
+		byProto[proto] = append(byProto[proto], result.TimeMs)
+	}
+	return all, byProto
+}
+
+// printStats prints the aggregate latency block across successful servers.
+func printStats(details map[string]models.DNSLookupResult) {
+	all, byProto := successfulTimings(details)
+	if len(all) == 0 {
+		return
+	}
+
+	st := computeStats(all)
+	fmt.Printf("\nLatency (%d servers): min %.2fms / max %.2fms / mean %.2fms / median %.2fms / p95 %.2fms\n",
+		st.Count, st.Min, st.Max, st.Mean, st.Median, st.P95)
+
+	protocols := make([]string, 0, len(byProto))
+	for proto := range byProto {
+		protocols = append(protocols, proto)
+	}
+	sort.Strings(protocols)
+
+	for _, proto := range protocols {
+		ps := computeStats(byProto[proto])
+		fmt.Printf("  %s: mean %.2fms (%d servers)\n", proto, ps.Mean, ps.Count)
+	}
+}
+
+// serverSamples collects timings, TTLs and failure counts for one server across repeated runs.
+type serverSamples struct {
+	protocol string
+	timings  []float64
+	ttls     []uint32
+	failures int
+}
+
+// cachedResponse infers whether the server answers from a cache: the lowest answer TTL
+// went down between two consecutive runs, as it does while a cached record ages.
+// known is false with fewer than two answered runs.
+func (s *serverSamples) cachedResponse() (cached, known bool) {
+	if len(s.ttls) < 2 {
+		return false, false
+	}
+	for i := 1; i < len(s.ttls); i++ {
+		if s.ttls[i] < s.ttls[i-1] {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// ttlNote describes the TTL trend for the repeat summary line, or "" when unknown.
+func (s *serverSamples) ttlNote() string {
+	cached, known := s.cachedResponse()
+	switch {
+	case !known:
+		return ""
+	case cached:
+		return fmt.Sprintf(" - TTL %ds → %ds (cached)", s.ttls[0], s.ttls[len(s.ttls)-1])
+	default:
+		return fmt.Sprintf(" - TTL constant %ds (fresh)", s.ttls[0])
+	}
+}
+
+// minTTL returns the lowest TTL among answers, the one that expires first in a cache.
+func minTTL(answers []models.DNSAnswer) uint32 {
+	lowest := answers[0].TTL
+	for _, ans := range answers[1:] {
+		lowest = min(lowest, ans.TTL)
+	}
+	return lowest
+}
+
+// collectSamples groups per-server timings from repeated task results.
+func collectSamples(runs []*models.TaskStatusResponse) map[string]*serverSamples {
+	samples := make(map[string]*serverSamples)
+	for _, run := range runs {
+		if run == nil || run.Result == nil {
+			continue
+		}
+		for server, result := range run.Result.Details {
+			s, ok := samples[server]
+			if !ok {
+				s = &serverSamples{protocol: result.DNSProtocol}
+				samples[server] = s
+			}
+			if result.CommandStatus == "ok" {
+				s.timings = append(s.timings, result.TimeMs)
+				if len(result.Answers) > 0 {
+					s.ttls = append(s.ttls, minTTL(result.Answers))
+				}
+			} else {
+				s.failures++
+			}
+		}
+	}
+	return samples
+}
+
+// printRepeatStats prints the latency distribution per server over repeated runs.
+func printRepeatStats(runs []*models.TaskStatusResponse, requested int) {
+	fmt.Printf("\nCompleted %d of %d runs\n", len(runs), requested)
+
+	samples := collectSamples(runs)
+	servers := make([]string, 0, len(samples))
+	for server := range samples {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+
+	var all []float64
+	for _, server := range servers {
+		s := samples[server]
+		if len(s.timings) == 0 {
+			logResult(levelErr, fmt.Sprintf("%s - %s - all %d samples failed", server, s.protocol, s.failures))
+			continue
+		}
+		all = append(all, s.timings...)
+
+		st := computeStats(s.timings)
+		level := levelInfo
+		if s.failures > 0 || st.P95/1000.0 > warnThreshold {
+			level = levelWarn
+		}
+		logResult(level, fmt.Sprintf("%s - %s - %d samples (%d failed) - min %.2fms / p50 %.2fms / p95 %.2fms / p99 %.2fms / max %.2fms%s",
+			server, s.protocol, st.Count, s.failures, st.Min, st.Median, st.P95, st.P99, st.Max, s.ttlNote()))
+	}
+
+	if len(all) > 0 {
+		st := computeStats(all)
+		fmt.Printf("\nOverall (%d samples): mean %.2fms / p50 %.2fms / p95 %.2fms / p99 %.2fms\n",
+			st.Count, st.Mean, st.Median, st.P95, st.P99)
+	}
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestComputeStats(t *testing.T) {
+	st := computeStats([]float64{40, 10, 20, 30, 100})
+
+	if st.Count != 5 {
+		t.Errorf("Expected count 5, got %d", st.Count)
+	}
+	if math.Abs(st.Mean-40) > 1e-9 {
+		t.Errorf("Expected mean 40, got %f", st.Mean)
+	}
+	if st.Min != 10 || st.Max != 100 {
+		t.Errorf("Expected min 10 / max 100, got %f / %f", st.Min, st.Max)
+	}
+	if st.Median != 30 {
+		t.Errorf("Expected median 30, got %f", st.Median)
+	}
+	if st.P95 != 100 {
+		t.Errorf("Expected p95 100, got %f", st.P95)
+	}
+
+	if empty := computeStats(nil); empty.Count != 0 {
+		t.Errorf("Expected zero stats for no samples, got %+v", empty)
+	}
+}
+
+func TestPrintStatsPerProtocol(t *testing.T) {
+	resetFlags(t)
+	details := map[string]models.DNSLookupResult{
+		"udp://9.9.9.9:53":      {CommandStatus: "ok", DNSProtocol: "Do53", TimeMs: 10},
+		"udp://8.8.8.8:53":      {CommandStatus: "ok", DNSProtocol: "Do53", TimeMs: 20},
+		"tls://dns.google:853":  {CommandStatus: "ok", DNSProtocol: "DoT", TimeMs: 60},
+		"tls://dns.broken:853":  {CommandStatus: "error", DNSProtocol: "DoT", TimeMs: 0},
+		"https://dns.quad9.net": {CommandStatus: "ok", DNSProtocol: "DoH", TimeMs: 30},
+	}
+
+	stdout := captureStdout(t, func() {
+		printStats(details)
+	})
+
+	for _, want := range []string{"Latency (4 servers)", "mean 30.00ms", "Do53: mean 15.00ms (2 servers)", "DoT: mean 60.00ms (1 servers)"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected %q in output:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestCachedResponseInference(t *testing.T) {
+	run := func(ttls ...uint32) *models.TaskStatusResponse {
+		answers := make([]models.DNSAnswer, len(ttls))
+		for i, ttl := range ttls {
+			answers[i] = models.DNSAnswer{Type: "A", TTL: ttl}
+		}
+		return &models.TaskStatusResponse{Result: &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{
+			"udp://9.9.9.9:53": {CommandStatus: "ok", DNSProtocol: "Do53", Answers: answers},
+		}}}
+	}
+
+	tests := []struct {
+		name       string
+		runs       []*models.TaskStatusResponse
+		wantCached bool
+		wantKnown  bool
+	}{
+		{"decreasing TTL", []*models.TaskStatusResponse{run(300), run(299), run(297)}, true, true},
+		{"refreshed after expiry", []*models.TaskStatusResponse{run(2), run(1), run(300)}, true, true},
+		{"lowest answer TTL decides", []*models.TaskStatusResponse{run(300, 60), run(300, 59)}, true, true},
+		{"constant TTL", []*models.TaskStatusResponse{run(300), run(300), run(300)}, false, true},
+		{"single sample", []*models.TaskStatusResponse{run(300)}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := collectSamples(tt.runs)["udp://9.9.9.9:53"]
+			cached, known := s.cachedResponse()
+			if cached != tt.wantCached || known != tt.wantKnown {
+				t.Errorf("Expected cached=%v known=%v, got cached=%v known=%v (ttls %v)", tt.wantCached, tt.wantKnown, cached, known, s.ttls)
+			}
+		})
+	}
+}
+
+func TestPrintRepeatStatsTTLNote(t *testing.T) {
+	resetFlags(t)
+	runs := make([]*models.TaskStatusResponse, 0, 3)
+	for _, ttl := range []uint32{120, 119, 118} {
+		runs = append(runs, &models.TaskStatusResponse{Result: &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{
+			"udp://9.9.9.9:53": {CommandStatus: "ok", DNSProtocol: "Do53", TimeMs: 10, Answers: []models.DNSAnswer{{Type: "A", TTL: ttl}}},
+		}}})
+	}
+
+	stdout := captureStdout(t, func() {
+		printRepeatStats(runs, 3)
+	})
+
+	if !strings.Contains(stdout, "TTL 120s → 118s (cached)") {
+		t.Errorf("Expected cached TTL note in output:\n%s", stdout)
+	}
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/api"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// DefaultStreamConcurrency bounds the per-server tasks in flight with --stream.
+const DefaultStreamConcurrency = 10
+
+// streamResult is one server's outcome from its own single-server task.
+type streamResult struct {
+	details map[string]models.DNSLookupResult
+	err     error
+}
+
+// runStream submits one task per server, at most --concurrency at a time, and prints
+// each server's line as soon as its task completes. The usual sorted summary follows.
+// Without servers a single task runs against the API's configured ones.
+func runStream(ctx context.Context, client *api.Client, req models.DNSLookupRequest, domain, queryType string) error {
+	start := time.Now()
+	limit := concurrency
+	if limit < 1 {
+		limit = 1
+	}
+
+	batches := make([][]models.DNSServer, 0, len(req.DNSServers))
+	for _, srv := range req.DNSServers {
+		batches = append(batches, []models.DNSServer{srv})
+	}
+	if len(batches) == 0 {
+		batches = append(batches, nil)
+	}
+
+	results := make(chan streamResult)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, servers := range batches {
+		wg.Add(1)
+		go func(servers []models.DNSServer) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			single := req
+			single.DNSServers = servers
+			taskStatus, err := submitAndWait(ctx, client, single)
+			if err != nil {
+				results <- streamResult{err: err}
+				return
+			}
+			if taskStatus.Status != models.TaskStatusSuccess || taskStatus.Result == nil {
+				// The task has no server to pin the failure on when the API picked them
+				if len(servers) == 0 {
+					results <- streamResult{err: &ExitError{Code: ExitServerError, Err: errors.New("task failed")}}
+					return
+				}
+				failed := models.DNSLookupResult{CommandStatus: "error", Error: "task failed"}
+				results <- streamResult{details: map[string]models.DNSLookupResult{servers[0].Target: failed}}
+				return
+			}
+			results <- streamResult{details: taskStatus.Result.Details}
+		}(servers)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	merged := &models.TaskStatusResponse{
+		Status: models.TaskStatusSuccess,
+		Result: &models.DNSLookupResults{Details: make(map[string]models.DNSLookupResult, len(req.DNSServers))},
+	}
+	var submitErr error
+	progressf("\n")
+	for res := range results {
+		if res.err != nil {
+			// Keep draining so the other servers still report; the first failure decides the exit
+			if submitErr == nil {
+				submitErr = res.err
+			}
+			continue
+		}
+		for _, item := range sortResults(res.details) {
+			if !statsOnly {
+				printServerResult(item.server, item.result, queryType == QTypePTR, queryType)
+			}
+			merged.Result.Details[item.server] = item.result
+		}
+	}
+	merged.Result.Duration = time.Since(start).Seconds()
+
+	if err := printTaskStatus(merged, domain, queryType); err != nil {
+		return err
+	}
+	if submitErr != nil {
+		return submitErr
+	}
+	return exitErrorFor(outcomeExitCode(merged))
+}
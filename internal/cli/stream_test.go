@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// newStreamAPI answers one single-server task per submission.
+// The slow server's task stays PENDING for its first few polls.
+func newStreamAPI(t *testing.T, slow string) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	targets := make(map[string]string)
+	polls := make(map[string]int)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req models.DNSLookupRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.DNSServers) != 1 {
+			t.Errorf("Expected one server per streamed task, got %d", len(req.DNSServers))
+		}
+		mu.Lock()
+		id := fmt.Sprintf("task-%d", len(targets))
+		targets[id] = req.DNSServers[0].Target
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: id})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("taskID")
+		mu.Lock()
+		target := targets[id]
+		polls[id]++
+		n := polls[id]
+		mu.Unlock()
+		if target == slow && n < 5 {
+			_ = json.NewEncoder(w).Encode(models.TaskStatusResponse{TaskID: id, Status: "PENDING"})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(models.TaskStatusResponse{
+			TaskID: id,
+			Status: "SUCCESS",
+			Result: &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{
+				target: {CommandStatus: "ok", RCode: "NOERROR", QType: "A", Answers: []models.DNSAnswer{
+					{Name: "example.com.", Type: "A", Value: "93.184.216.34"},
+				}},
+			}},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunDNSTestStream(t *testing.T) {
+	resetFlags(t)
+	const fast, slow = "udp://9.9.9.9:53", "udp://8.8.8.8:53"
+	srv := newStreamAPI(t, slow)
+	apiURL = srv.URL
+	pollInterval = 10 * time.Millisecond
+	stream = true
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", slow, fast})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	fastAt := strings.Index(stdout, fast)
+	slowAt := strings.Index(stdout, slow)
+	summaryAt := strings.Index(stdout, "DNS lookup succeeded for 2 out of 2")
+	if fastAt < 0 || slowAt < 0 || summaryAt < 0 {
+		t.Fatalf("Expected both servers and the summary in output:\n%s", stdout)
+	}
+	if fastAt > slowAt {
+		t.Errorf("Expected the fast server to be printed first:\n%s", stdout)
+	}
+	if summaryAt < slowAt {
+		t.Errorf("Expected the summary after the streamed lines:\n%s", stdout)
+	}
+}
+
+func TestRunDNSTestStreamRequiresText(t *testing.T) {
+	resetFlags(t)
+	stream = true
+	output = OutputJSON
+
+	err := runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	if err == nil || !strings.Contains(err.Error(), "--stream") {
+		t.Errorf("Expected --stream output error, got %v", err)
+	}
+}
+
+func TestRunDNSTestStreamConfiguredServers(t *testing.T) {
+	resetFlags(t)
+	srv := newMockAPI(t, mockSuccessStatus())
+	apiURL = srv.URL
+	pollInterval = 10 * time.Millisecond
+	stream = true
+
+	// No targets: one task runs against the API's configured servers
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+	if !strings.Contains(stdout, "udp://9.9.9.9:53") || !strings.Contains(stdout, "DNS lookup succeeded for 1 out of 1") {
+		t.Errorf("Expected the configured server's result in output:\n%s", stdout)
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+)
+
+// traceRoots are the servers --trace starts from; tests replace them with local servers.
+var traceRoots = resolver.RootServers
+
+// runTrace walks the delegation of domain from the root servers down and prints every step.
+// It runs from the CLI host, not through the API, because it needs no configured server.
+func runTrace(ctx context.Context, domain string) error {
+	if output == OutputCSV {
+		return fmt.Errorf("--trace does not support csv output (use text or json)")
+	}
+	if lookupTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lookupTimeout)
+		defer cancel()
+	}
+
+	steps, err := resolver.Trace(ctx, domain, qtype, traceRoots, resolver.DefaultTimeout)
+
+	if output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(steps); encErr != nil {
+			return encErr
+		}
+		return err
+	}
+
+	for _, step := range steps {
+		server := fmt.Sprintf("%s - %s (%s)", step.Zone, step.Server, step.Address)
+		switch {
+		case step.Error != "":
+			logResult(levelWarn, fmt.Sprintf("%s - %s", server, step.Error))
+		case step.Referral != "":
+			logResult(levelInfo, fmt.Sprintf("%s - %.2f ms - referral to %s: %s",
+				server, step.TimeMs, step.Referral, strings.Join(step.Nameservers, ", ")))
+		default:
+			level := levelInfo
+			if step.RCode != "NOERROR" {
+				level = levelWarn
+			}
+			var values []string
+			for _, ans := range step.Answers {
+				values = append(values, fmt.Sprintf("%s %s %s (TTL: %d)", ans.Name, ans.Type, ans.Value, ans.TTL))
+			}
+			if len(values) == 0 {
+				values = append(values, "no "+qtype+" records")
+			}
+			logResult(level, fmt.Sprintf("%s - %.2f ms - %s - %s", server, step.TimeMs, step.RCode, strings.Join(values, ", ")))
+		}
+	}
+	return err
+}
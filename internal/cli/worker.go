@@ -17,9 +17,12 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/spf13/cobra"
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/logging"
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
 	"github.com/sudo-tiz/dns-tester-go/internal/tasks"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
 )
 
 // NewWorkerCommand creates the 'worker' subcommand for running standalone Redis workers
@@ -29,6 +32,8 @@ func NewWorkerCommand() *cobra.Command {
 	var concurrency int
 	var metricsPort int
 	var enableMetrics bool
+	var logFormat string
+	var exportDir string
 
 	// DNS config flags
 	var dnsTimeout int
@@ -49,9 +54,12 @@ func NewWorkerCommand() *cobra.Command {
   dnstestergo worker --config /path/to/config.yaml --redis redis://localhost:6379/0 --enable-metrics
 
   # Override DNS settings
-  dnstestergo worker --redis redis://localhost:6379/0 --dns-timeout 10 --max-retries 5`,
+  dnstestergo worker --redis redis://localhost:6379/0 --dns-timeout 10 --max-retries 5
+
+  # Also append every task result to a daily NDJSON file
+  dnstestergo worker --redis redis://localhost:6379/0 --export-dir /var/lib/dnstester/results`,
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			return runWorker(cmd, configPath, redisURL, concurrency, metricsPort, enableMetrics,
+			return runWorker(cmd, configPath, redisURL, logFormat, exportDir, concurrency, metricsPort, enableMetrics,
 				dnsTimeout, maxConcurrentQueries, maxRetries)
 		},
 	}
@@ -61,6 +69,8 @@ func NewWorkerCommand() *cobra.Command {
 	cmd.Flags().IntVarP(&concurrency, "concurrency", "n", 4, "Number of parallel task processors (how many DNS lookups to process simultaneously)")
 	cmd.Flags().IntVarP(&metricsPort, "metrics-port", "m", 9091, "Port for Prometheus metrics endpoint (if enabled)")
 	cmd.Flags().BoolVarP(&enableMetrics, "enable-metrics", "M", false, "Enable metrics HTTP endpoint (useful for single worker, avoid port conflicts with multiple workers)")
+	cmd.Flags().StringVar(&logFormat, "log-format", os.Getenv("LOG_FORMAT"), "Log format: text or json (default: from config or text)")
+	cmd.Flags().StringVar(&exportDir, "export-dir", "", "Also append each completed task result as NDJSON to a daily results-YYYY-MM-DD.ndjson file in this directory")
 
 	// DNS configuration
 	cmd.Flags().IntVarP(&dnsTimeout, "dns-timeout", "T", 0, "DNS query timeout in seconds (default: from config or 5)")
@@ -72,7 +82,7 @@ func NewWorkerCommand() *cobra.Command {
 	return cmd
 }
 
-func runWorker(cmd *cobra.Command, configPath, redisURL string, concurrency, metricsPort int, enableMetrics bool,
+func runWorker(cmd *cobra.Command, configPath, redisURL, logFormat, exportDir string, concurrency, metricsPort int, enableMetrics bool,
 	dnsTimeout, maxConcurrentQueries, maxRetries int) error {
 
 	// Load configuration
@@ -95,6 +105,32 @@ func runWorker(cmd *cobra.Command, configPath, redisURL string, concurrency, met
 	if cmd.Flags().Changed("max-retries") {
 		cfg.DNS.MaxRetries = maxRetries
 	}
+	if logFormat != "" {
+		cfg.Log.Format = logFormat
+	}
+	if err := logging.Setup(cfg.GetLogFormat(), os.Stderr); err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+	if err := metrics.SetDurationBuckets(cfg.GetMetricsBuckets()); err != nil {
+		slog.Error("Failed to apply metrics buckets", "error", err)
+		os.Exit(1)
+	}
+	if err := resolver.SetBootstrap(cfg.GetBootstrapResolvers(), time.Duration(cfg.GetDNSTimeout())*time.Second); err != nil {
+		slog.Error("Failed to set up bootstrap resolvers", "error", err)
+		os.Exit(1)
+	}
+	resolver.SetCache(cfg.GetCacheMaxTTL())
+	shutdownTracing, err := tracing.Setup(context.Background(), "dnstester-worker")
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Tracing shutdown error", "error", err)
+		}
+	}()
 	if len(cfg.Servers) == 0 {
 		slog.Warn("No DNS servers configured - worker will process tasks with explicit targets only", "path", configPath)
 	} else {
@@ -146,16 +182,28 @@ func runWorker(cmd *cobra.Command, configPath, redisURL string, concurrency, met
 		}
 	}()
 
+	// Result export (optional)
+	var exporter *tasks.ResultExporter
+	if exportDir != "" {
+		if exporter, err = tasks.NewResultExporter(exportDir); err != nil {
+			slog.Error("Failed to set up result export", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("Result export enabled", "dir", exportDir)
+	}
+
 	// Register handler with config closure
+	callbacks := tasks.NewCallbackSender(cfg)
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(tasks.TaskTypeDNSLookup, func(ctx context.Context, t *asynq.Task) error {
-		return handleTask(ctx, t, rdb, dnsTimeoutDuration, cfg)
+		return handleTask(ctx, t, rdb, resolver.Default, dnsTimeoutDuration, cfg, callbacks, exporter)
 	})
 
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: redisAddr},
 		asynq.Config{
 			Concurrency: concurrency,
+			Queues:      cfg.GetQueues(),
 		},
 	)
 
@@ -175,16 +223,70 @@ func runWorker(cmd *cobra.Command, configPath, redisURL string, concurrency, met
 	return nil
 }
 
-// handleTask processes DNS lookup and stores result in Redis cache
-func handleTask(ctx context.Context, t *asynq.Task, rdb *redis.Client, dnsTimeout time.Duration, cfg *config.APIConfig) error {
-	var p map[string]interface{}
-	if err := json.Unmarshal(t.Payload(), &p); err != nil {
+// handleTask processes DNS lookup, stores result in Redis cache, exports it when enabled and notifies the callback URL
+func handleTask(ctx context.Context, t *asynq.Task, rdb *redis.Client, res resolver.Resolver, dnsTimeout time.Duration, cfg *config.APIConfig, callbacks *tasks.CallbackSender, exporter *tasks.ResultExporter) error {
+	task, err := runLookupTask(ctx, t.Payload(), res, dnsTimeout, cfg)
+	if err != nil {
 		return err
 	}
 
+	// Write to Redis cache (single key, fast reads)
+	resultKey := tasks.ResultKeyPrefix + task.ID
+	if err := rdb.Set(ctx, resultKey, task.Meta, 24*time.Hour).Err(); err != nil {
+		slog.Error("Failed to cache result", "task_id", task.ID, "error", err)
+		return fmt.Errorf("failed to cache result: %w", err)
+	}
+
+	exportResult(exporter, task)
+	notifyCallback(ctx, callbacks, task)
+	return nil
+}
+
+// lookupTask is a processed task: its cached metadata plus what the export and callback need.
+type lookupTask struct {
+	ID          string
+	Domain      string
+	QType       string
+	Meta        []byte
+	Status      *models.TaskStatusResponse
+	CallbackURL string
+}
+
+// exportResult appends the task result to the export file when export is enabled.
+// Failures are only logged: the result is cached, so the task itself succeeded.
+func exportResult(exporter *tasks.ResultExporter, task *lookupTask) {
+	if exporter == nil {
+		return
+	}
+	if err := exporter.Export(task.Domain, task.QType, task.Status); err != nil {
+		slog.Warn("Result export failed", "task_id", task.ID, "error", err)
+	}
+}
+
+// notifyCallback delivers the task status to its callback URL, if any.
+// Failures are only logged: the result is cached, so retrying the lookup would not help.
+func notifyCallback(ctx context.Context, callbacks *tasks.CallbackSender, task *lookupTask) {
+	if task.CallbackURL == "" {
+		return
+	}
+	if err := callbacks.Send(ctx, task.CallbackURL, task.Status); err != nil {
+		slog.Warn("Task callback failed", "task_id", task.ID, "error", err)
+	}
+}
+
+// runLookupTask decodes the payload, runs the queries through res, records lookup metrics
+// and builds the task metadata to cache. The queries stop at dns.task_timeout or when ctx
+// ends, whichever comes first; servers without an answer by then are reported as timeouts.
+func runLookupTask(ctx context.Context, payload []byte, res resolver.Resolver, dnsTimeout time.Duration, cfg *config.APIConfig) (*lookupTask, error) {
+	var p map[string]interface{}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, err
+	}
+
 	taskID, _ := p["task_id"].(string)
 	domain, _ := p["domain"].(string)
 	qtype, _ := p["qtype"].(string)
+	callbackURL, _ := p["callback_url"].(string)
 
 	var servers []models.DNSServer
 	if s, ok := p["servers"]; ok {
@@ -192,36 +294,66 @@ func handleTask(ctx context.Context, t *asynq.Task, rdb *redis.Client, dnsTimeou
 		_ = json.Unmarshal(b, &servers)
 	}
 
-	tlsInsecure, _ := p["tls_insecure"].(bool)
+	ctx, cancel := context.WithTimeout(ctx, cfg.GetTaskTimeout())
+	defer cancel()
+
+	// Join the trace started by the API request, if any
+	if tc, ok := p["trace"].(map[string]interface{}); ok {
+		carrier := make(map[string]string, len(tc))
+		for k, v := range tc {
+			carrier[k], _ = v.(string)
+		}
+		ctx = tracing.Extract(ctx, carrier)
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "dns.task")
+	defer span.End()
+
+	// Tasks enqueued before QueryOptions only carry tls_insecure
+	var opts models.QueryOptions
+	if o, ok := p["options"]; ok {
+		b, _ := json.Marshal(o)
+		_ = json.Unmarshal(b, &opts)
+	} else {
+		opts.TLSInsecureSkipVerify, _ = p["tls_insecure"].(bool)
+	}
 
 	start := time.Now()
-	results := resolver.RunQueries(context.Background(), domain, qtype, servers, tlsInsecure, dnsTimeout, cfg.GetMaxConcurrentQueries(), cfg.GetMaxRetries())
+	results := res.RunQueries(ctx, domain, qtype, servers, opts, dnsTimeout, cfg.GetMaxConcurrentQueries(), cfg.GetMaxRetries())
 	duration := time.Since(start).Seconds()
+	metrics.RecordLookupResults(results)
+
+	createdAt := tasks.PayloadCreatedAt(payload)
+	completedAt := time.Now().UTC()
+	lookupResults := &models.DNSLookupResults{Details: results, Duration: duration}
 
 	// Build task metadata (Celery-style structure)
-	taskMeta := map[string]interface{}{
-		"status":  "SUCCESS",
-		"task_id": taskID,
-		"result": map[string]interface{}{
-			"details":  results,
-			"duration": duration,
-		},
-		"completed_at": time.Now().UTC(),
+	taskMeta := tasks.ResultMeta{
+		Status:      models.TaskStatusSuccess,
+		TaskID:      taskID,
+		Result:      lookupResults,
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
 	}
 
 	metaData, err := json.Marshal(taskMeta)
 	if err != nil {
 		slog.Error("Failed to marshal task metadata", "task_id", taskID, "error", err)
-		return err
-	}
-
-	// Write to Redis cache (single key, fast reads)
-	resultKey := fmt.Sprintf("dnstester:task-meta:%s", taskID)
-	if err := rdb.Set(ctx, resultKey, metaData, 24*time.Hour).Err(); err != nil {
-		slog.Error("Failed to cache result", "task_id", taskID, "error", err)
-		return fmt.Errorf("failed to cache result: %w", err)
+		return nil, err
 	}
 
 	slog.Info("Task completed", "task_id", taskID, "duration_seconds", fmt.Sprintf("%.3f", duration))
-	return nil
+	return &lookupTask{
+		ID:     taskID,
+		Domain: domain,
+		QType:  qtype,
+		Meta:   metaData,
+		Status: &models.TaskStatusResponse{
+			TaskID:      taskID,
+			Status:      models.TaskStatusSuccess,
+			Result:      lookupResults,
+			CreatedAt:   createdAt,
+			CompletedAt: completedAt,
+		},
+		CallbackURL: callbackURL,
+	}, nil
 }
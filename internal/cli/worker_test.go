@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+	"github.com/sudo-tiz/dns-tester-go/internal/tasks"
+)
+
+// startTestDNSServer answers every A query with 192.0.2.1 on a local UDP port.
+func startTestDNSServer(t *testing.T) string {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.1"),
+		})
+		_ = w.WriteMsg(m)
+	})
+
+	srv := &dns.Server{PacketConn: pc, Handler: handler}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	return "udp://" + pc.LocalAddr().String()
+}
+
+func TestRunLookupTaskRecordsMetrics(t *testing.T) {
+	target := startTestDNSServer(t)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"task_id": "worker-test",
+		"domain":  "example.com",
+		"qtype":   "A",
+		"servers": []map[string]string{{"target": target}},
+	})
+
+	before := testutil.ToFloat64(metrics.DNSLookupTotal.WithLabelValues(target, "A", "success"))
+
+	task, err := runLookupTask(context.Background(), payload, resolver.Default, 2*time.Second, &config.APIConfig{})
+	if err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+	if task.ID != "worker-test" {
+		t.Errorf("Expected task ID worker-test, got %s", task.ID)
+	}
+	if len(task.Meta) == 0 {
+		t.Error("Expected task metadata")
+	}
+
+	if got := testutil.ToFloat64(metrics.DNSLookupTotal.WithLabelValues(target, "A", "success")); got != before+1 {
+		t.Errorf("Expected success counter %v after handling task, got %v", before+1, got)
+	}
+}
+
+func TestRunLookupTaskDeadline(t *testing.T) {
+	payload, _ := json.Marshal(map[string]interface{}{
+		"task_id": "deadline-test",
+		"domain":  "example.com",
+		"qtype":   "A",
+		"servers": []map[string]string{{"target": "udp://192.0.2.1:53"}},
+	})
+
+	var left time.Duration
+	var ctxErr error
+	res := resolver.ResolverFunc(func(ctx context.Context, _, _ string, _ []models.DNSServer, _ models.QueryOptions, _ time.Duration, _, _ int) map[string]models.DNSLookupResult {
+		if deadline, ok := ctx.Deadline(); ok {
+			left = time.Until(deadline)
+		}
+		<-ctx.Done()
+		ctxErr = ctx.Err()
+		return map[string]models.DNSLookupResult{}
+	})
+
+	// dns.task_timeout bounds the queries even under a context without a deadline
+	cfg := &config.APIConfig{DNS: config.DNSConfig{TaskTimeout: 1}}
+	if _, err := runLookupTask(context.Background(), payload, res, 5*time.Second, cfg); err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+	if left <= 0 || left > time.Second {
+		t.Errorf("Expected a deadline within dns.task_timeout, got %s", left)
+	}
+
+	// The handler's context ending stops the queries too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := runLookupTask(ctx, payload, res, 5*time.Second, &config.APIConfig{}); err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+	if !errors.Is(ctxErr, context.Canceled) {
+		t.Errorf("Expected the handler's cancellation to reach the resolver, got %v", ctxErr)
+	}
+}
+
+func TestRunLookupTaskTimestamps(t *testing.T) {
+	target := startTestDNSServer(t)
+	createdAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"task_id":    "timestamps-test",
+		"domain":     "example.com",
+		"qtype":      "A",
+		"servers":    []map[string]string{{"target": target}},
+		"created_at": createdAt.Format(time.RFC3339Nano),
+	})
+
+	task, err := runLookupTask(context.Background(), payload, resolver.Default, 2*time.Second, &config.APIConfig{})
+	if err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+
+	var meta tasks.ResultMeta
+	if err := json.Unmarshal(task.Meta, &meta); err != nil {
+		t.Fatalf("Task metadata is not a ResultMeta: %v", err)
+	}
+	if !meta.CreatedAt.Equal(createdAt) || !task.Status.CreatedAt.Equal(createdAt) {
+		t.Errorf("Expected created_at %s, got %s (meta) / %s (status)", createdAt, meta.CreatedAt, task.Status.CreatedAt)
+	}
+	if meta.CompletedAt.IsZero() || meta.CompletedAt.Before(createdAt) {
+		t.Errorf("Expected completed_at after created_at, got %s", meta.CompletedAt)
+	}
+	if meta.Status != models.TaskStatusSuccess || meta.Result == nil || len(meta.Result.Details) != 1 {
+		t.Errorf("Unexpected task metadata: %+v", meta)
+	}
+}
+
+func TestTaskCallback(t *testing.T) {
+	target := startTestDNSServer(t)
+
+	var attempts atomic.Int32
+	received := make(chan *http.Request, 1)
+	var body []byte
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// First delivery fails to exercise the retry
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer hook.Close()
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"task_id":      "callback-test",
+		"domain":       "example.com",
+		"qtype":        "A",
+		"servers":      []map[string]string{{"target": target}},
+		"callback_url": hook.URL + "/done",
+	})
+
+	cfg := &config.APIConfig{Callbacks: config.CallbackConfig{Secret: "s3cret"}}
+	task, err := runLookupTask(context.Background(), payload, resolver.Default, 2*time.Second, cfg)
+	if err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+
+	callbacks := tasks.NewCallbackSender(cfg)
+	callbacks.Backoff = 10 * time.Millisecond
+	notifyCallback(context.Background(), callbacks, task)
+
+	select {
+	case r := <-received:
+		if r.Method != http.MethodPost || r.URL.Path != "/done" {
+			t.Errorf("Expected POST /done, got %s %s", r.Method, r.URL.Path)
+		}
+		if want := "sha256=" + tasks.Sign("s3cret", body); r.Header.Get(tasks.SignatureHeader) != want {
+			t.Errorf("Expected signature %s, got %s", want, r.Header.Get(tasks.SignatureHeader))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Callback was not delivered")
+	}
+
+	var status models.TaskStatusResponse
+	if err := json.Unmarshal(body, &status); err != nil {
+		t.Fatalf("Callback body is not a task status: %v", err)
+	}
+	if status.TaskID != "callback-test" || status.Status != "SUCCESS" || status.Result == nil {
+		t.Errorf("Unexpected callback status: %+v", status)
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Expected 2 delivery attempts, got %d", got)
+	}
+}
+
+func TestTaskExport(t *testing.T) {
+	target := startTestDNSServer(t)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"task_id": "export-test",
+		"domain":  "example.com",
+		"qtype":   "A",
+		"servers": []map[string]string{{"target": target}},
+	})
+	task, err := runLookupTask(context.Background(), payload, resolver.Default, 2*time.Second, &config.APIConfig{})
+	if err != nil {
+		t.Fatalf("runLookupTask failed: %v", err)
+	}
+
+	exporter, err := tasks.NewResultExporter(filepath.Join(t.TempDir(), "results"))
+	if err != nil {
+		t.Fatalf("NewResultExporter failed: %v", err)
+	}
+	// Two tasks append two lines to the same daily file
+	exportResult(exporter, task)
+	exportResult(exporter, task)
+
+	data, err := os.ReadFile(exporter.Path(task.Status.CompletedAt))
+	if err != nil {
+		t.Fatalf("Expected an export file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 NDJSON lines, got %d: %s", len(lines), data)
+	}
+
+	var record tasks.ExportRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Export line is not valid JSON: %v", err)
+	}
+	if record.TaskID != "export-test" || record.Domain != "example.com" || record.QType != "A" || record.CompletedAt.IsZero() {
+		t.Errorf("Unexpected export record: %+v", record)
+	}
+	if result, ok := record.Details[target]; !ok || result.CommandStatus != "ok" {
+		t.Errorf("Expected the server's result in the export, got %+v", record.Details)
+	}
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/api"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
+)
+
+// zoneInfoTypes are the record types --zone-info looks up at the zone apex, in display order.
+var zoneInfoTypes = []string{"NS", "SOA", "A", "AAAA", "MX"}
+
+// zoneSOA is a parsed SOA record.
+type zoneSOA struct {
+	PrimaryNS string `json:"primary_ns"`
+	Contact   string `json:"contact"`
+	Serial    uint32 `json:"serial"`
+	Refresh   uint32 `json:"refresh"`
+	Retry     uint32 `json:"retry"`
+	Expire    uint32 `json:"expire"`
+	Minimum   uint32 `json:"minimum"`
+}
+
+// zoneSummary is the zone summary reported by one server.
+type zoneSummary struct {
+	Server      string            `json:"server"`
+	Nameservers []string          `json:"nameservers"`
+	SOA         *zoneSOA          `json:"soa,omitempty"`
+	A           []string          `json:"a"`
+	AAAA        []string          `json:"aaaa"`
+	MX          []string          `json:"mx"`
+	Errors      map[string]string `json:"errors,omitempty"` // Why a record type has no answer, by type
+}
+
+// runZoneInfo looks up NS, SOA, A, AAAA and MX for domain on every server, one task per type,
+// and prints a compact summary of the zone as each server sees it.
+func runZoneInfo(ctx context.Context, domain string) error {
+	if output == OutputCSV {
+		return fmt.Errorf("--zone-info does not support csv output (use text or json)")
+	}
+	if normalize.IsValidIP(domain) {
+		return fmt.Errorf("--zone-info takes a domain, not an IP address")
+	}
+
+	progressf("Collecting zone info for %s ", domain)
+	client := api.NewClient(apiURL, 30*time.Second, insecure)
+
+	code := ExitOK
+	details := make(map[string]map[string]models.DNSLookupResult, len(zoneInfoTypes))
+	for _, recordType := range zoneInfoTypes {
+		req := models.DNSLookupRequest{
+			Domain:       domain,
+			DNSServers:   buildDNSServers(dnsServers),
+			QType:        recordType,
+			QueryOptions: queryOptionsFromFlags(),
+		}
+		taskStatus, err := submitAndWait(ctx, client, req)
+		if err != nil {
+			return err
+		}
+		code = worseExit(code, outcomeExitCode(taskStatus))
+		if taskStatus.Result != nil {
+			details[recordType] = taskStatus.Result.Details
+		}
+	}
+	progressf("\n")
+
+	// Servers come from the NS task so the order matches the other text output
+	var zones []zoneSummary
+	for _, item := range sortResults(details["NS"]) {
+		zones = append(zones, buildZoneInfo(item.server, details))
+	}
+
+	if output == OutputJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(zones); err != nil {
+			return err
+		}
+		return exitErrorFor(code)
+	}
+
+	for _, zone := range zones {
+		printZoneInfo(zone, domain)
+	}
+	return exitErrorFor(code)
+}
+
+// buildZoneInfo collects one server's answers for every zone info type.
+func buildZoneInfo(server string, details map[string]map[string]models.DNSLookupResult) zoneSummary {
+	zone := zoneSummary{Server: server, Nameservers: []string{}, A: []string{}, AAAA: []string{}, MX: []string{}}
+	for _, recordType := range zoneInfoTypes {
+		result, ok := details[recordType][server]
+		switch {
+		case !ok:
+			zone.addError(recordType, "no result")
+			continue
+		case result.CommandStatus != "ok":
+			zone.addError(recordType, result.Error)
+			continue
+		case result.RCode != "NOERROR":
+			zone.addError(recordType, result.RCode)
+			continue
+		}
+
+		for _, ans := range filterAnswers(result.Answers, recordType) {
+			switch recordType {
+			case "NS":
+				zone.Nameservers = append(zone.Nameservers, ans.Value)
+			case "SOA":
+				if soa, err := parseSOA(ans.Value); err == nil {
+					zone.SOA = soa
+				} else {
+					zone.addError(recordType, err.Error())
+				}
+			case "A":
+				zone.A = append(zone.A, ans.Value)
+			case "AAAA":
+				zone.AAAA = append(zone.AAAA, ans.Value)
+			case "MX":
+				zone.MX = append(zone.MX, ans.Value)
+			}
+		}
+	}
+	return zone
+}
+
+// addError records why recordType has no answers.
+func (z *zoneSummary) addError(recordType, msg string) {
+	if z.Errors == nil {
+		z.Errors = make(map[string]string)
+	}
+	z.Errors[recordType] = msg
+}
+
+// parseSOA reads the "mname rname serial refresh retry expire minimum" value of an SOA answer.
+func parseSOA(value string) (*zoneSOA, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("malformed SOA '%s'", value)
+	}
+	var numbers [5]uint32
+	for i := range numbers {
+		n, err := strconv.ParseUint(fields[2+i], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("malformed SOA '%s'", value)
+		}
+		numbers[i] = uint32(n)
+	}
+	return &zoneSOA{
+		PrimaryNS: fields[0],
+		Contact:   soaContact(fields[1]),
+		Serial:    numbers[0],
+		Refresh:   numbers[1],
+		Retry:     numbers[2],
+		Expire:    numbers[3],
+		Minimum:   numbers[4],
+	}, nil
+}
+
+// soaContact turns an SOA RNAME such as hostmaster.example.com into hostmaster@example.com.
+// The first unescaped dot separates the mailbox, so john\.doe.example.com is john.doe@example.com.
+func soaContact(rname string) string {
+	for i := 0; i < len(rname); i++ {
+		switch rname[i] {
+		case '\\':
+			i++
+		case '.':
+			return strings.ReplaceAll(rname[:i], `\.`, ".") + "@" + rname[i+1:]
+		}
+	}
+	return rname
+}
+
+// formatSeconds renders an SOA timer as its value and a readable duration, e.g. "7200 (2h)".
+func formatSeconds(secs uint32) string {
+	if secs == 0 {
+		return "0"
+	}
+	var b strings.Builder
+	rest := secs
+	for _, unit := range []struct {
+		suffix string
+		size   uint32
+	}{{"w", 7 * 86400}, {"d", 86400}, {"h", 3600}, {"m", 60}, {"s", 1}} {
+		if n := rest / unit.size; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.suffix)
+			rest %= unit.size
+		}
+	}
+	return fmt.Sprintf("%d (%s)", secs, b.String())
+}
+
+// printZoneInfo prints one server's zone summary: a status line, then one line per record type.
+func printZoneInfo(zone zoneSummary, domain string) {
+	level := levelInfo
+	if len(zone.Nameservers) == 0 || zone.SOA == nil {
+		level = levelWarn
+	}
+	logResult(level, fmt.Sprintf("%s - zone %s", zone.Server, domain))
+
+	list := func(recordType string, values []string) string {
+		if len(values) > 0 {
+			return strings.Join(values, ", ")
+		}
+		if msg, ok := zone.Errors[recordType]; ok {
+			return "(" + msg + ")"
+		}
+		return "(none)"
+	}
+	fmt.Printf("    NS: %s\n", list("NS", zone.Nameservers))
+	if soa := zone.SOA; soa != nil {
+		fmt.Printf("    SOA: primary %s, contact %s, serial %d\n", soa.PrimaryNS, soa.Contact, soa.Serial)
+		fmt.Printf("         refresh %s, retry %s, expire %s, minimum %s\n",
+			formatSeconds(soa.Refresh), formatSeconds(soa.Retry), formatSeconds(soa.Expire), formatSeconds(soa.Minimum))
+	} else {
+		fmt.Printf("    SOA: %s\n", list("SOA", nil))
+	}
+	fmt.Printf("    A: %s\n", list("A", zone.A))
+	fmt.Printf("    AAAA: %s\n", list("AAAA", zone.AAAA))
+	fmt.Printf("    MX: %s\n", list("MX", zone.MX))
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// newZoneInfoAPI answers one task per record type, using the qtype as task ID.
+func newZoneInfoAPI(t *testing.T, answers map[string][]models.DNSAnswer) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /dns-lookup", func(w http.ResponseWriter, r *http.Request) {
+		var req models.DNSLookupRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		_ = json.NewEncoder(w).Encode(models.TaskResponse{TaskID: req.QType})
+	})
+	mux.HandleFunc("GET /tasks/{taskID}", func(w http.ResponseWriter, r *http.Request) {
+		qtype := r.PathValue("taskID")
+		_ = json.NewEncoder(w).Encode(models.TaskStatusResponse{
+			TaskID: qtype,
+			Status: "SUCCESS",
+			Result: &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{
+				"udp://9.9.9.9:53": {CommandStatus: "ok", RCode: "NOERROR", QType: qtype, Answers: answers[qtype]},
+			}},
+		})
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRunDNSTestZoneInfo(t *testing.T) {
+	resetFlags(t)
+	srv := newZoneInfoAPI(t, map[string][]models.DNSAnswer{
+		"NS": {
+			{Name: "example.com", Type: "NS", TTL: 86400, Value: "a.iana-servers.net"},
+			{Name: "example.com", Type: "NS", TTL: 86400, Value: "b.iana-servers.net"},
+		},
+		"SOA": {{Name: "example.com", Type: "SOA", TTL: 3600, Value: `ns.icann.org noc\.team.dns.icann.org 2024081434 7200 3600 1209600 3600`}},
+		"A":   {{Name: "example.com", Type: "A", TTL: 300, Value: "93.184.215.14"}},
+		"MX":  {{Name: "example.com", Type: "MX", TTL: 300, Value: "0 ."}},
+	})
+	apiURL = srv.URL
+	zoneInfo = true
+	output = OutputJSON
+
+	var runErr error
+	stdout := captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	if runErr != nil {
+		t.Fatalf("runDNSTest failed: %v", runErr)
+	}
+
+	var zones []zoneSummary
+	if err := json.Unmarshal([]byte(stdout), &zones); err != nil {
+		t.Fatalf("stdout is not valid JSON: %v\n%s", err, stdout)
+	}
+	if len(zones) != 1 {
+		t.Fatalf("Expected one server summary, got %+v", zones)
+	}
+	zone := zones[0]
+	want := zoneSOA{PrimaryNS: "ns.icann.org", Contact: "noc.team@dns.icann.org", Serial: 2024081434, Refresh: 7200, Retry: 3600, Expire: 1209600, Minimum: 3600}
+	if zone.SOA == nil || *zone.SOA != want {
+		t.Errorf("Expected SOA %+v, got %+v", want, zone.SOA)
+	}
+	if strings.Join(zone.Nameservers, ",") != "a.iana-servers.net,b.iana-servers.net" {
+		t.Errorf("Unexpected nameservers: %v", zone.Nameservers)
+	}
+	if len(zone.A) != 1 || len(zone.AAAA) != 0 || len(zone.MX) != 1 || zone.MX[0] != "0 ." {
+		t.Errorf("Unexpected apex records: %+v", zone)
+	}
+
+	output = OutputText
+	stdout = captureStdout(t, func() {
+		runErr = runDNSTest(context.Background(), []string{"example.com", "udp://9.9.9.9:53"})
+	})
+	for _, line := range []string{
+		"    NS: a.iana-servers.net, b.iana-servers.net",
+		"    SOA: primary ns.icann.org, contact noc.team@dns.icann.org, serial 2024081434",
+		"refresh 7200 (2h), retry 3600 (1h), expire 1209600 (2w), minimum 3600 (1h)",
+		"    AAAA: (none)",
+	} {
+		if !strings.Contains(stdout, line) {
+			t.Errorf("Expected %q in output:\n%s", line, stdout)
+		}
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	for secs, want := range map[uint32]string{0: "0", 300: "300 (5m)", 5400: "5400 (1h30m)", 90061: "90061 (1d1h1m1s)"} {
+		if got := formatSeconds(secs); got != want {
+			t.Errorf("formatSeconds(%d) = %q, want %q", secs, got, want)
+		}
+	}
+}
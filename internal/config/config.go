@@ -3,13 +3,40 @@
 package config
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
 	"gopkg.in/yaml.v3"
 )
 
+// DefaultQueue is the Asynq queue used when a request does not select one.
+const DefaultQueue = "default"
+
+// DefaultTaskMaxRetry is the Asynq retry count of a lookup task when worker.task_max_retry is unset.
+const DefaultTaskMaxRetry = 3
+
+// Environment variables that set lookup defaults; they win over dns.default_qtype and dns.default_servers.
+const (
+	// EnvDefaultQType is the query type of lookups that do not give one
+	EnvDefaultQType = "DNS_TESTER_DEFAULT_QTYPE"
+	// EnvDefaultServers is a comma-separated target list for lookups without servers
+	EnvDefaultServers = "DNS_TESTER_DEFAULT_SERVERS"
+)
+
+// Redacted replaces secret values in the effective config reported by Effective.
+const Redacted = "REDACTED"
+
 // ServiceType maps config values to DNS protocol schemes.
 type ServiceType string
 
@@ -28,49 +55,158 @@ const (
 
 // DNSServer represents server configuration with flexible IP/hostname support.
 type DNSServer struct {
-	IP       string        `yaml:"ip,omitempty"`
-	Port     int           `yaml:"port,omitempty"`
-	Hostname string        `yaml:"hostname,omitempty"`
-	Services []ServiceType `yaml:"services"`
-	Tags     []string      `yaml:"tags,omitempty"`
+	IP        string        `yaml:"ip,omitempty" json:"ip,omitempty"`
+	Port      int           `yaml:"port,omitempty" json:"port,omitempty"`
+	Hostname  string        `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+	Path      string        `yaml:"path,omitempty" json:"path,omitempty"`
+	Services  []ServiceType `yaml:"services" json:"services"`
+	Tags      []string      `yaml:"tags,omitempty" json:"tags,omitempty"`
+	PinSHA256 []string      `yaml:"pin_sha256,omitempty" json:"pin_sha256,omitempty"`
+}
+
+// DefaultPublicResolvers is the built-in server list used when servers is empty and
+// dns.use_default_public_resolvers is set. Plain UDP only, so it works without TLS setup.
+var DefaultPublicResolvers = []DNSServer{
+	{IP: "9.9.9.9", Hostname: "dns.quad9.net", Services: []ServiceType{ServiceDo53UDP}, Tags: []string{"QUAD9"}},
+	{IP: "1.1.1.1", Hostname: "one.one.one.one", Services: []ServiceType{ServiceDo53UDP}, Tags: []string{"CLOUDFLARE"}},
+	{IP: "8.8.8.8", Hostname: "dns.google", Services: []ServiceType{ServiceDo53UDP}, Tags: []string{"GOOGLE"}},
 }
 
 // APIConfig is the root configuration structure.
 type APIConfig struct {
-	Servers      []DNSServer     `yaml:"servers"`
-	RateLimiting RateLimitConfig `yaml:"rate_limiting,omitempty"`
-	Server       ServerConfig    `yaml:"server,omitempty"`
-	Worker       WorkerConfig    `yaml:"worker,omitempty"`
-	DNS          DNSConfig       `yaml:"dns,omitempty"`
+	Servers      []DNSServer     `yaml:"servers" json:"servers"`
+	RateLimiting RateLimitConfig `yaml:"rate_limiting,omitempty" json:"rate_limiting,omitempty"`
+	Server       ServerConfig    `yaml:"server,omitempty" json:"server,omitempty"`
+	Worker       WorkerConfig    `yaml:"worker,omitempty" json:"worker,omitempty"`
+	DNS          DNSConfig       `yaml:"dns,omitempty" json:"dns,omitempty"`
+	Metrics      MetricsConfig   `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+	Auth         AuthConfig      `yaml:"auth,omitempty" json:"auth,omitempty"`
+	CORS         CORSConfig      `yaml:"cors,omitempty" json:"cors,omitempty"`
+	Callbacks    CallbackConfig  `yaml:"callbacks,omitempty" json:"callbacks,omitempty"`
+	Log          LogConfig       `yaml:"log,omitempty" json:"log,omitempty"`
 }
 
 // RateLimitConfig controls tollbooth rate limiting.
+// Anonymous requests are limited per IP; requests with a valid API key get their own tier.
 type RateLimitConfig struct {
-	RequestsPerSecond int `yaml:"requests_per_second"`
-	BurstSize         int `yaml:"burst_size"`
+	RequestsPerSecond int `yaml:"requests_per_second" json:"requests_per_second"`
+	DefaultRPS        int `yaml:"default_rps,omitempty" json:"default_rps,omitempty"` // Alias of requests_per_second, wins when set
+	KeyedRPS          int `yaml:"keyed_rps,omitempty" json:"keyed_rps,omitempty"`     // Per API key limit (0 = same as default)
+	BurstSize         int `yaml:"burst_size" json:"burst_size"`
+}
+
+// Enabled reports whether any rate is set; all zero disables rate limiting.
+func (c *RateLimitConfig) Enabled() bool {
+	return c.RequestsPerSecond > 0 || c.DefaultRPS > 0 || c.KeyedRPS > 0
 }
 
-// ServerConfig controls HTTP server timeouts and binding.
+// ServerConfig controls HTTP server timeouts, binding and TLS.
+// Setting TLSCert and TLSKey serves HTTPS on Port; HTTPRedirectPort then answers plain
+// HTTP there with a redirect to HTTPS, and TLSClientCA requires client certificates.
 type ServerConfig struct {
-	Host         string `yaml:"host,omitempty"`
-	Port         string `yaml:"port,omitempty"`
-	ReadTimeout  int    `yaml:"read_timeout,omitempty"`
-	WriteTimeout int    `yaml:"write_timeout,omitempty"`
-	IdleTimeout  int    `yaml:"idle_timeout,omitempty"`
+	Host             string `yaml:"host,omitempty" json:"host,omitempty"`
+	Port             string `yaml:"port,omitempty" json:"port,omitempty"`
+	ReadTimeout      int    `yaml:"read_timeout,omitempty" json:"read_timeout,omitempty"`
+	WriteTimeout     int    `yaml:"write_timeout,omitempty" json:"write_timeout,omitempty"`
+	IdleTimeout      int    `yaml:"idle_timeout,omitempty" json:"idle_timeout,omitempty"`
+	CompressionLevel int    `yaml:"compression_level,omitempty" json:"compression_level,omitempty"`
+	MaxBodyBytes     int64  `yaml:"max_body_bytes,omitempty" json:"max_body_bytes,omitempty"`
+	TLSCert          string `yaml:"tls_cert,omitempty" json:"tls_cert,omitempty"`
+	TLSKey           string `yaml:"tls_key,omitempty" json:"tls_key,omitempty"`
+	HTTPRedirectPort string `yaml:"http_redirect_port,omitempty" json:"http_redirect_port,omitempty"`
+	TLSClientCA      string `yaml:"tls_client_ca,omitempty" json:"tls_client_ca,omitempty"`
+}
+
+// TLSEnabled reports whether the API serves HTTPS.
+func (c *ServerConfig) TLSEnabled() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
 }
 
-// WorkerConfig controls Asynq worker concurrency.
+// WorkerConfig controls Asynq worker concurrency, queue priorities and task lifecycle.
+// TaskMaxRetry is a pointer so an explicit 0 (no Asynq retries) differs from unset.
 type WorkerConfig struct {
-	MaxWorkers      int `yaml:"max_workers,omitempty"`
-	CleanupInterval int `yaml:"cleanup_interval,omitempty"`
+	MaxWorkers      int            `yaml:"max_workers,omitempty" json:"max_workers,omitempty"`
+	CleanupInterval int            `yaml:"cleanup_interval,omitempty" json:"cleanup_interval,omitempty"`
+	Queues          map[string]int `yaml:"queues,omitempty" json:"queues,omitempty"`
+	TaskMaxRetry    *int           `yaml:"task_max_retry,omitempty" json:"task_max_retry,omitempty"`
+	TaskRetention   int            `yaml:"task_retention,omitempty" json:"task_retention,omitempty"`
 }
 
 // DNSConfig controls DNS query behavior.
 type DNSConfig struct {
-	Timeout              int `yaml:"timeout,omitempty"`
-	MaxServersPerReq     int `yaml:"max_servers_per_req,omitempty"`
-	MaxConcurrentQueries int `yaml:"max_concurrent_queries,omitempty"`
-	MaxRetries           int `yaml:"max_retries,omitempty"`
+	Timeout                   int      `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	MaxServersPerReq          int      `yaml:"max_servers_per_req,omitempty" json:"max_servers_per_req,omitempty"`
+	MaxConcurrentQueries      int      `yaml:"max_concurrent_queries,omitempty" json:"max_concurrent_queries,omitempty"`
+	MaxRetries                int      `yaml:"max_retries,omitempty" json:"max_retries,omitempty"`
+	TaskTimeout               int      `yaml:"task_timeout,omitempty" json:"task_timeout,omitempty"`
+	AllowedQTypes             []string `yaml:"allowed_qtypes,omitempty" json:"allowed_qtypes,omitempty"`
+	ResolveHostnames          bool     `yaml:"resolve_hostnames,omitempty" json:"resolve_hostnames,omitempty"`
+	UseDefaultPublicResolvers bool     `yaml:"use_default_public_resolvers,omitempty" json:"use_default_public_resolvers,omitempty"`
+	BootstrapResolvers        []string `yaml:"bootstrap_resolvers,omitempty" json:"bootstrap_resolvers,omitempty"`
+	CacheMaxTTL               int      `yaml:"cache_max_ttl,omitempty" json:"cache_max_ttl,omitempty"`
+	MaxReverseRange           int      `yaml:"max_reverse_range,omitempty" json:"max_reverse_range,omitempty"`
+	DefaultQType              string   `yaml:"default_qtype,omitempty" json:"default_qtype,omitempty"`
+	DefaultServers            []string `yaml:"default_servers,omitempty" json:"default_servers,omitempty"`
+}
+
+// AuthConfig controls API key authentication; no keys means auth is disabled.
+type AuthConfig struct {
+	APIKeys []string `yaml:"api_keys,omitempty" json:"api_keys,omitempty"`
+}
+
+// CORSConfig controls cross-origin access for browser clients; no origins means no CORS headers.
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowed_origins,omitempty" json:"allowed_origins,omitempty"`
+	AllowedMethods []string `yaml:"allowed_methods,omitempty" json:"allowed_methods,omitempty"`
+	AllowedHeaders []string `yaml:"allowed_headers,omitempty" json:"allowed_headers,omitempty"`
+}
+
+// Enabled reports whether any origin is allowed.
+func (c *CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// GetAllowedMethods provides default fallback covering the API's routes.
+func (c *CORSConfig) GetAllowedMethods() []string {
+	if len(c.AllowedMethods) > 0 {
+		return c.AllowedMethods
+	}
+	return []string{"GET", "POST", "HEAD", "OPTIONS"}
+}
+
+// GetAllowedHeaders provides default fallback covering the headers the API reads.
+func (c *CORSConfig) GetAllowedHeaders() []string {
+	if len(c.AllowedHeaders) > 0 {
+		return c.AllowedHeaders
+	}
+	return []string{"Content-Type", "Authorization", "X-API-Key", "X-Queue", "If-None-Match"}
+}
+
+// CallbackConfig controls webhook delivery of completed tasks.
+type CallbackConfig struct {
+	Secret    string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	AllowHTTP bool   `yaml:"allow_http,omitempty" json:"allow_http,omitempty"`
+	Timeout   int    `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// LogConfig controls the slog output format of the server and worker.
+type LogConfig struct {
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+}
+
+// MetricsConfig controls Prometheus metric layout.
+type MetricsConfig struct {
+	Buckets []float64 `yaml:"buckets,omitempty" json:"buckets,omitempty"`
+}
+
+// Validate checks histogram buckets are strictly ascending, as Prometheus requires.
+func (m *MetricsConfig) Validate() error {
+	for i := 1; i < len(m.Buckets); i++ {
+		if m.Buckets[i] <= m.Buckets[i-1] {
+			return fmt.Errorf("metrics.buckets must be strictly ascending (%g follows %g)", m.Buckets[i], m.Buckets[i-1])
+		}
+	}
+	return nil
 }
 
 // Validate delegates IP validation to normalize.IsValidIP.
@@ -90,19 +226,163 @@ func (s *DNSServer) Validate() error {
 		return fmt.Errorf("invalid port: %d (must be between 1 and 65535)", s.Port)
 	}
 
-	// Do53 needs IP - avoid DNS lookup for DNS server address
-	for _, svc := range s.Services {
-		if (svc == ServiceDo53UDP || svc == ServiceDo53TCP) && s.IP == "" {
-			return fmt.Errorf("do53/udp and do53/tcp require an IP address (not just a hostname)")
-		}
+	// Do53 needs IP - avoid DNS lookup for DNS server address unless dns.resolve_hostnames is set
+	if s.hasDo53() && s.IP == "" {
+		return fmt.Errorf("do53/udp and do53/tcp require an IP address (not just a hostname, or set dns.resolve_hostnames)")
 	}
 
 	return nil
 }
 
-// LoadConfig reads YAML and validates servers.
+// hasDo53 reports whether the server offers plain DNS over UDP or TCP.
+func (s *DNSServer) hasDo53() bool {
+	for _, svc := range s.Services {
+		if svc == ServiceDo53UDP || svc == ServiceDo53TCP {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadConfig reads YAML, expands ${VAR} references and validates servers, metrics buckets,
+// rate limit tiers, bootstrap resolvers and worker queues.
 // Returns empty config if file missing - optional config approach.
 func LoadConfig(filePath string) (*APIConfig, error) {
+	config, err := ParseConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.DNS.ResolveHostnames {
+		if err := config.ResolveHostnames(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, server := range config.Servers {
+		if err := server.Validate(); err != nil {
+			return nil, fmt.Errorf("server %d validation failed: %w", i, err)
+		}
+	}
+
+	if err := config.Metrics.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateRateLimitTiers(); err != nil {
+		return nil, err
+	}
+
+	if err := errors.Join(config.DNS.bootstrapErrors()...); err != nil {
+		return nil, err
+	}
+
+	if err := config.validateQueues(); err != nil {
+		return nil, err
+	}
+
+	if err := config.applyDefaults(); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// applyDefaults overrides dns.default_qtype and dns.default_servers from the environment
+// and normalizes both, so an invalid default fails at load time instead of on every lookup.
+func (c *APIConfig) applyDefaults() error {
+	qtype, err := EnvDefaultQTypeValue()
+	if err != nil {
+		return err
+	}
+	if qtype != "" {
+		c.DNS.DefaultQType = qtype
+	} else if c.DNS.DefaultQType != "" {
+		if c.DNS.DefaultQType, err = normalize.QType(c.DNS.DefaultQType); err != nil {
+			return fmt.Errorf("dns.default_qtype: %w", err)
+		}
+	}
+
+	servers, err := EnvDefaultServersValue()
+	if err != nil {
+		return err
+	}
+	if len(servers) > 0 {
+		c.DNS.DefaultServers = servers
+		return nil
+	}
+	for i, target := range c.DNS.DefaultServers {
+		if c.DNS.DefaultServers[i], err = normalize.Target(target); err != nil {
+			return fmt.Errorf("dns.default_servers[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// EnvDefaultQTypeValue returns DNS_TESTER_DEFAULT_QTYPE validated by normalize.QType, or "" when unset.
+func EnvDefaultQTypeValue() (string, error) {
+	raw := strings.TrimSpace(os.Getenv(EnvDefaultQType))
+	if raw == "" {
+		return "", nil
+	}
+	qtype, err := normalize.QType(raw)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", EnvDefaultQType, err)
+	}
+	return qtype, nil
+}
+
+// EnvDefaultServersValue returns the DNS_TESTER_DEFAULT_SERVERS targets normalized by
+// normalize.Target, or nil when unset. Empty items between commas are skipped.
+func EnvDefaultServersValue() ([]string, error) {
+	var targets []string
+	for _, item := range strings.Split(os.Getenv(EnvDefaultServers), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		target, err := normalize.Target(item)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvDefaultServers, err)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// lookupHost resolves do53 hostnames; tests replace it to avoid real DNS.
+var lookupHost = net.DefaultResolver.LookupHost
+
+// ResolveHostnames fills the IP of do53 servers that only have a hostname, using the system resolver.
+// Runs once at load time; the first address returned is kept.
+func (c *APIConfig) ResolveHostnames(ctx context.Context) error {
+	var errs []error
+	for i := range c.Servers {
+		server := &c.Servers[i]
+		if server.IP != "" || server.Hostname == "" || !server.hasDo53() {
+			continue
+		}
+
+		addrs, err := lookupHost(ctx, server.Hostname)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("server %d: resolve %s: %w", i, server.Hostname, err))
+			continue
+		}
+		if len(addrs) == 0 {
+			errs = append(errs, fmt.Errorf("server %d: resolve %s: no addresses", i, server.Hostname))
+			continue
+		}
+
+		server.IP = addrs[0]
+		slog.Info("Resolved do53 hostname", "hostname", server.Hostname, "ip", server.IP)
+	}
+	return errors.Join(errs...)
+}
+
+// ParseConfig reads and decodes the config file without validating servers.
+// Files ending in .json are decoded as JSON, anything else as YAML.
+// Returns empty config if file missing, like LoadConfig.
+func ParseConfig(filePath string) (*APIConfig, error) {
 	// #nosec G304 -- filePath is user-controlled via CLI flag by design
 	data, err := os.ReadFile(filePath)
 	if err != nil {
@@ -113,91 +393,331 @@ func LoadConfig(filePath string) (*APIConfig, error) {
 	}
 
 	var config APIConfig
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if strings.EqualFold(filepath.Ext(filePath), ".json") {
+		if err := json.Unmarshal(expandEnv(data), &config); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return &config, nil
+	}
+
+	if err := yaml.Unmarshal(expandEnv(data), &config); err != nil {
 		return nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
-	for i, server := range config.Servers {
+	return &config, nil
+}
+
+// Check reports every problem in the config instead of stopping at the first one.
+// Covers server validation, unknown service types and defaults that can never be used.
+func (c *APIConfig) Check() []error {
+	var errs []error
+
+	for i, server := range c.Servers {
+		name := server.IP
+		if name == "" {
+			name = server.Hostname
+		}
+
 		if err := server.Validate(); err != nil {
-			return nil, fmt.Errorf("server %d validation failed: %w", i, err)
+			errs = append(errs, fmt.Errorf("server %d (%s): %w", i, name, err))
+			continue
+		}
+		if len(server.Services) == 0 {
+			errs = append(errs, fmt.Errorf("server %d (%s): services must not be empty", i, name))
+		}
+		pinnable := false
+		for _, svc := range server.Services {
+			if _, ok := serviceToScheme[svc]; !ok {
+				errs = append(errs, fmt.Errorf("server %d (%s): unknown service type '%s'", i, name, svc))
+			} else if norm, _, ok := server.target(svc); !ok {
+				errs = append(errs, fmt.Errorf("server %d (%s): cannot build a valid %s target", i, name, svc))
+			} else if normalize.SupportsPins(norm) {
+				pinnable = true
+			}
+		}
+		for _, pin := range server.PinSHA256 {
+			if _, err := normalize.ParsePin(pin); err != nil {
+				errs = append(errs, fmt.Errorf("server %d (%s): %w", i, name, err))
+			}
+		}
+		if len(server.PinSHA256) > 0 && !pinnable {
+			errs = append(errs, fmt.Errorf("server %d (%s): pin_sha256 is set but no dot, doh or doq service is configured", i, name))
 		}
 	}
 
-	return &config, nil
+	// Lookups without explicit servers use every config target and would always be rejected
+	if n, limit := len(c.GetDNSTargets()), c.GetMaxServersPerRequest(); n > limit {
+		errs = append(errs, fmt.Errorf("config provides %d DNS targets but dns.max_servers_per_req is %d - default lookups will always be rejected", n, limit))
+	}
+
+	queues := make([]string, 0, len(c.Worker.Queues))
+	for name := range c.Worker.Queues {
+		queues = append(queues, name)
+	}
+	sort.Strings(queues)
+	for _, name := range queues {
+		if priority := c.Worker.Queues[name]; priority < 1 {
+			errs = append(errs, fmt.Errorf("worker.queues.%s has priority %d (must be >= 1)", name, priority))
+		}
+	}
+	if err := c.validateQueues(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if n := c.GetTaskMaxRetry(); n < 0 {
+		errs = append(errs, fmt.Errorf("worker.task_max_retry is %d (must be >= 0)", n))
+	}
+	if n := c.Worker.TaskRetention; n < 0 {
+		errs = append(errs, fmt.Errorf("worker.task_retention is %d (must be >= 0 seconds)", n))
+	}
+
+	for i, qtype := range c.DNS.AllowedQTypes {
+		if !normalize.IsValidQType(qtype) {
+			errs = append(errs, fmt.Errorf("dns.allowed_qtypes[%d] '%s' is not a DNS record type", i, qtype))
+		} else if normalize.IsZoneTransfer(qtype) {
+			errs = append(errs, fmt.Errorf("dns.allowed_qtypes[%d] '%s' is a zone transfer, which is not supported", i, qtype))
+		}
+	}
+
+	if qtype := c.DNS.DefaultQType; qtype != "" && !normalize.IsValidQType(qtype) {
+		errs = append(errs, fmt.Errorf("dns.default_qtype '%s' is not a DNS record type", qtype))
+	}
+	for i, target := range c.DNS.DefaultServers {
+		if _, err := normalize.Target(target); err != nil {
+			errs = append(errs, fmt.Errorf("dns.default_servers[%d]: %w", i, err))
+		}
+	}
+
+	if n := c.DNS.TaskTimeout; n < 0 {
+		errs = append(errs, fmt.Errorf("dns.task_timeout is %d (must be >= 0 seconds)", n))
+	}
+	if n := c.DNS.CacheMaxTTL; n < 0 {
+		errs = append(errs, fmt.Errorf("dns.cache_max_ttl is %d (must be >= 0 seconds)", n))
+	}
+
+	errs = append(errs, c.DNS.bootstrapErrors()...)
+
+	if err := c.Metrics.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if level := c.Server.CompressionLevel; level > 9 {
+		errs = append(errs, fmt.Errorf("server.compression_level is %d (must be 1-9, or negative to disable)", level))
+	}
+	if (c.Server.TLSCert == "") != (c.Server.TLSKey == "") {
+		errs = append(errs, errors.New("server.tls_cert and server.tls_key must be set together"))
+	}
+	if port := c.Server.HTTPRedirectPort; port != "" {
+		if !c.Server.TLSEnabled() {
+			errs = append(errs, errors.New("server.http_redirect_port is set but TLS is not enabled"))
+		} else if port == c.GetServerPort() {
+			errs = append(errs, fmt.Errorf("server.http_redirect_port %s is the same as server.port", port))
+		}
+	}
+	if c.Server.TLSClientCA != "" && !c.Server.TLSEnabled() {
+		errs = append(errs, errors.New("server.tls_client_ca is set but TLS is not enabled"))
+	}
+
+	switch c.GetLogFormat() {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log.format is '%s' (must be text or json)", c.Log.Format))
+	}
+
+	for i, key := range c.Auth.APIKeys {
+		if strings.TrimSpace(key) == "" {
+			errs = append(errs, fmt.Errorf("auth.api_keys[%d] is empty (unset environment variable?)", i))
+		}
+	}
+	if err := c.validateRateLimitTiers(); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
 }
 
-// DNSTarget combines normalized target URL with tags.
+// validateRateLimitTiers rejects a keyed rate limit tier that no request can reach.
+func (c *APIConfig) validateRateLimitTiers() error {
+	if c.RateLimiting.KeyedRPS > 0 && len(c.Auth.APIKeys) == 0 {
+		return errors.New("rate_limiting.keyed_rps is set but auth.api_keys is empty - no request can use it")
+	}
+	return nil
+}
+
+// validateQueues rejects worker queues that leave out the default queue, which lookups
+// without an X-Queue header are enqueued to and no worker would then poll.
+func (c *APIConfig) validateQueues() error {
+	if _, ok := c.GetQueues()[DefaultQueue]; !ok {
+		return fmt.Errorf("worker.queues must include '%s' - lookups without an X-Queue header are enqueued there", DefaultQueue)
+	}
+	return nil
+}
+
+// expandEnv substitutes $VAR, ${VAR} and ${VAR:-default} from the environment.
+// Unset variables without a default expand to "", and $$ yields a literal $.
+func expandEnv(data []byte) []byte {
+	return []byte(os.Expand(string(data), func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if key, def, ok := strings.Cut(name, ":-"); ok {
+			if v, set := os.LookupEnv(key); set && v != "" {
+				return v
+			}
+			return def
+		}
+		return os.Getenv(name)
+	}))
+}
+
+// DNSTarget combines normalized target URL with tags and, for encrypted targets, certificate pins.
 type DNSTarget struct {
-	Target string   `json:"target"`
-	Tags   []string `json:"tags,omitempty"`
+	Target    string   `json:"target"`
+	Tags      []string `json:"tags,omitempty"`
+	PinSHA256 []string `json:"pin_sha256,omitempty"`
+}
+
+// serviceToScheme maps config service types to normalize schemes.
+var serviceToScheme = map[ServiceType]string{
+	ServiceDo53UDP: normalize.SchemeUDP,
+	ServiceDo53TCP: normalize.SchemeTCP,
+	ServiceDoT:     normalize.SchemeTLS,
+	ServiceDoH:     normalize.SchemeHTTPS,
+	ServiceDoQ:     normalize.SchemeQUIC,
+}
+
+// target builds the normalized target for one service, returning false for unknown services.
+func (s *DNSServer) target(svc ServiceType) (string, normalize.ProtocolConfig, bool) {
+	scheme, ok := serviceToScheme[svc]
+	if !ok {
+		return "", normalize.ProtocolConfig{}, false
+	}
+
+	protoCfg, ok := normalize.ProtocolConfigs[scheme]
+	if !ok {
+		return "", normalize.ProtocolConfig{}, false
+	}
+
+	// Use hostname for protocols that support it (DoT, DoH, DoQ)
+	host := s.IP
+	if protoCfg.UsesHostname && s.Hostname != "" {
+		host = s.Hostname
+	}
+
+	port := s.Port
+	if port == 0 {
+		port = protoCfg.DefaultPort
+	}
+
+	raw := protoCfg.Scheme + "://" + normalize.JoinHostPort(host, port)
+	if svc == ServiceDoH && s.Path != "" {
+		raw += "/" + strings.TrimPrefix(s.Path, "/")
+	}
+	norm, err := normalize.Target(raw)
+	if err != nil {
+		return "", normalize.ProtocolConfig{}, false
+	}
+
+	return norm, protoCfg, true
+}
+
+// dnsServers returns the configured servers, or DefaultPublicResolvers when none are
+// configured and dns.use_default_public_resolvers is set.
+func (c *APIConfig) dnsServers() []DNSServer {
+	if len(c.Servers) == 0 && c.DNS.UseDefaultPublicResolvers {
+		return DefaultPublicResolvers
+	}
+	return c.Servers
 }
 
 // GetDNSTargets transforms YAML config to normalized targets.
 // normalize.ProtocolConfigs is single source of truth for scheme/port mapping.
+// Without any server, dns.default_servers (or DNS_TESTER_DEFAULT_SERVERS) are the targets.
 func (c *APIConfig) GetDNSTargets() []DNSTarget {
 	var targets []DNSTarget
-
-	serviceToScheme := map[ServiceType]string{
-		ServiceDo53UDP: normalize.SchemeUDP,
-		ServiceDo53TCP: normalize.SchemeTCP,
-		ServiceDoT:     normalize.SchemeTLS,
-		ServiceDoH:     normalize.SchemeHTTPS,
-		ServiceDoQ:     normalize.SchemeQUIC,
+	if len(c.dnsServers()) == 0 {
+		for _, target := range c.DNS.DefaultServers {
+			targets = append(targets, DNSTarget{Target: target, Tags: []string{}})
+		}
+		return targets
 	}
 
-	for _, server := range c.Servers {
+	for _, server := range c.dnsServers() {
 		for _, svc := range server.Services {
-			scheme, ok := serviceToScheme[svc]
+			norm, _, ok := server.target(svc)
 			if !ok {
 				continue
 			}
 
-			protoCfg, ok := normalize.ProtocolConfigs[scheme]
-			if !ok {
-				continue
-			}
-
-			// Use hostname for protocols that support it (DoT, DoH, DoQ)
-			host := server.IP
-			if protoCfg.UsesHostname && server.Hostname != "" {
-				host = server.Hostname
-			}
-
-			port := server.Port
-			if port == 0 {
-				port = protoCfg.DefaultPort
-			}
-
-			raw := fmt.Sprintf("%s://%s:%d", protoCfg.Scheme, host, port)
-			norm, err := normalize.Target(raw)
-			if err != nil {
-				continue
-			}
-
 			tags := server.Tags
 			if tags == nil {
 				tags = []string{}
 			}
 
-			targets = append(targets, DNSTarget{
+			target := DNSTarget{
 				Target: norm,
 				Tags:   tags,
-			})
+			}
+			if normalize.SupportsPins(norm) {
+				target.PinSHA256 = server.PinSHA256
+			}
+			targets = append(targets, target)
 		}
 	}
 
 	return targets
 }
 
+// GetServerCapabilities reports configured services and their normalized targets per server.
+// Unknown services are still listed so a UI can surface them, but produce no target.
+func (c *APIConfig) GetServerCapabilities() []models.ServerCapabilities {
+	servers := c.dnsServers()
+	caps := make([]models.ServerCapabilities, 0, len(servers))
+
+	for _, server := range servers {
+		entry := models.ServerCapabilities{
+			IP:       server.IP,
+			Hostname: server.Hostname,
+			Services: make([]string, 0, len(server.Services)),
+			Targets:  make(map[string][]string),
+			Tags:     server.Tags,
+		}
+
+		for _, svc := range server.Services {
+			entry.Services = append(entry.Services, string(svc))
+			norm, protoCfg, ok := server.target(svc)
+			if !ok {
+				continue
+			}
+			entry.Targets[protoCfg.DisplayName] = append(entry.Targets[protoCfg.DisplayName], norm)
+		}
+
+		caps = append(caps, entry)
+	}
+
+	return caps
+}
+
 // GetRateLimitRequestsPerSecond provides default fallback.
 // Returns 0 if explicitly set to 0 (disables rate limiting).
 func (c *APIConfig) GetRateLimitRequestsPerSecond() int {
+	if c.RateLimiting.DefaultRPS > 0 {
+		return c.RateLimiting.DefaultRPS
+	}
 	if c.RateLimiting.RequestsPerSecond > 0 {
 		return c.RateLimiting.RequestsPerSecond
 	}
 	return 10
 }
 
+// GetRateLimitKeyedRequestsPerSecond falls back to the per-IP rate (no separate tier).
+func (c *APIConfig) GetRateLimitKeyedRequestsPerSecond() int {
+	if c.RateLimiting.KeyedRPS > 0 {
+		return c.RateLimiting.KeyedRPS
+	}
+	return c.GetRateLimitRequestsPerSecond()
+}
+
 // GetRateLimitBurstSize provides default fallback.
 func (c *APIConfig) GetRateLimitBurstSize() int {
 	if c.RateLimiting.BurstSize > 0 {
@@ -246,6 +766,38 @@ func (c *APIConfig) GetServerIdleTimeout() int {
 	return 60
 }
 
+// GetServerCompressionLevel provides default fallback; negative means compression is disabled.
+func (c *APIConfig) GetServerCompressionLevel() int {
+	if c.Server.CompressionLevel != 0 {
+		return c.Server.CompressionLevel
+	}
+	return 5
+}
+
+// GetServerMaxBodyBytes provides default fallback (1 MiB, enough for large dns_servers lists).
+func (c *APIConfig) GetServerMaxBodyBytes() int64 {
+	if c.Server.MaxBodyBytes > 0 {
+		return c.Server.MaxBodyBytes
+	}
+	return 1 << 20
+}
+
+// GetCallbackTimeout provides default fallback (seconds per delivery attempt).
+func (c *APIConfig) GetCallbackTimeout() int {
+	if c.Callbacks.Timeout > 0 {
+		return c.Callbacks.Timeout
+	}
+	return 10
+}
+
+// GetLogFormat returns the log format (default: text).
+func (c *APIConfig) GetLogFormat() string {
+	if c.Log.Format != "" {
+		return strings.ToLower(c.Log.Format)
+	}
+	return "text"
+}
+
 // GetMaxWorkers provides default fallback.
 func (c *APIConfig) GetMaxWorkers() int {
 	if c.Worker.MaxWorkers > 0 {
@@ -262,6 +814,29 @@ func (c *APIConfig) GetWorkerCleanupInterval() int {
 	return 10
 }
 
+// GetQueues returns queue name to priority mapping for Asynq workers.
+// Falls back to a single default queue, matching Asynq's own default.
+func (c *APIConfig) GetQueues() map[string]int {
+	if len(c.Worker.Queues) > 0 {
+		return c.Worker.Queues
+	}
+	return map[string]int{DefaultQueue: 1}
+}
+
+// GetTaskMaxRetry returns how often Asynq retries a failed task (default 3, 0 disables retries).
+// Independent of dns.max_retries, which retries each query inside a task.
+func (c *APIConfig) GetTaskMaxRetry() int {
+	if c.Worker.TaskMaxRetry != nil {
+		return *c.Worker.TaskMaxRetry
+	}
+	return DefaultTaskMaxRetry
+}
+
+// GetTaskRetention returns how long Asynq keeps completed tasks (default 0: deleted once cached).
+func (c *APIConfig) GetTaskRetention() time.Duration {
+	return time.Duration(c.Worker.TaskRetention) * time.Second
+}
+
 // GetDNSTimeout provides default fallback (seconds).
 func (c *APIConfig) GetDNSTimeout() int {
 	if c.DNS.Timeout > 0 {
@@ -278,6 +853,22 @@ func (c *APIConfig) GetMaxServersPerRequest() int {
 	return 50
 }
 
+// GetDefaultQType returns the query type of lookups that do not give one (default A).
+func (c *APIConfig) GetDefaultQType() string {
+	if c.DNS.DefaultQType != "" {
+		return c.DNS.DefaultQType
+	}
+	return "A"
+}
+
+// GetMaxReverseRange caps the addresses in one /reverse-lookup/range request (default 256, a /24).
+func (c *APIConfig) GetMaxReverseRange() int {
+	if c.DNS.MaxReverseRange > 0 {
+		return c.DNS.MaxReverseRange
+	}
+	return 256
+}
+
 // GetMaxConcurrentQueries provides default fallback.
 func (c *APIConfig) GetMaxConcurrentQueries() int {
 	if c.DNS.MaxConcurrentQueries > 0 {
@@ -293,3 +884,134 @@ func (c *APIConfig) GetMaxRetries() int {
 	}
 	return 3
 }
+
+// GetTaskTimeout bounds a whole lookup task (default 15s), so servers still retrying
+// past it are reported as timeouts instead of holding back the other results.
+func (c *APIConfig) GetTaskTimeout() time.Duration {
+	if c.DNS.TaskTimeout > 0 {
+		return time.Duration(c.DNS.TaskTimeout) * time.Second
+	}
+	return 15 * time.Second
+}
+
+// GetAllowedQTypes returns the query types lookups may use (default: normalize.DefaultAllowedQTypes).
+func (c *APIConfig) GetAllowedQTypes() []string {
+	if len(c.DNS.AllowedQTypes) > 0 {
+		return c.DNS.AllowedQTypes
+	}
+	return normalize.DefaultAllowedQTypes
+}
+
+// GetBootstrapResolvers returns the resolvers for encrypted target hostnames, or nil for system DNS.
+func (c *APIConfig) GetBootstrapResolvers() []string {
+	if len(c.DNS.BootstrapResolvers) > 0 {
+		return c.DNS.BootstrapResolvers
+	}
+	return nil
+}
+
+// GetCacheMaxTTL caps how long a query result is reused; 0 (the default) disables the cache.
+func (c *APIConfig) GetCacheMaxTTL() time.Duration {
+	return time.Duration(max(c.DNS.CacheMaxTTL, 0)) * time.Second
+}
+
+// bootstrapErrors reports every bootstrap resolver not given by IP.
+func (d *DNSConfig) bootstrapErrors() []error {
+	var errs []error
+	for i, addr := range d.BootstrapResolvers {
+		if !isBootstrapAddress(addr) {
+			errs = append(errs, fmt.Errorf("dns.bootstrap_resolvers[%d] '%s' must be an IP address, optionally with scheme and port", i, addr))
+		}
+	}
+	return errs
+}
+
+// isBootstrapAddress reports whether addr names its server by IP, like 9.9.9.9,
+// udp://[2620:fe::fe]:53 or tls://1.1.1.1. A hostname would need bootstrapping itself.
+func isBootstrapAddress(addr string) bool {
+	if _, rest, ok := strings.Cut(addr, "://"); ok {
+		addr = rest
+	}
+	addr, _, _ = strings.Cut(addr, "/")
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(addr) != nil
+}
+
+// GetMetricsBuckets returns custom duration histogram buckets, or nil for Prometheus defaults.
+func (c *APIConfig) GetMetricsBuckets() []float64 {
+	if len(c.Metrics.Buckets) > 0 {
+		return c.Metrics.Buckets
+	}
+	return nil
+}
+
+// Effective returns a copy of the config with every Get* default applied and secrets
+// (API keys, callback secret) replaced by Redacted, so it is safe to show to operators.
+func (c *APIConfig) Effective() *APIConfig {
+	eff := *c
+	eff.Servers = append([]DNSServer(nil), c.Servers...)
+
+	if c.RateLimiting.Enabled() {
+		eff.RateLimiting = RateLimitConfig{
+			RequestsPerSecond: c.GetRateLimitRequestsPerSecond(),
+			KeyedRPS:          c.GetRateLimitKeyedRequestsPerSecond(),
+			BurstSize:         c.GetRateLimitBurstSize(),
+		}
+	}
+	eff.Server = ServerConfig{
+		Host:             c.GetServerHost(),
+		Port:             c.GetServerPort(),
+		ReadTimeout:      c.GetServerReadTimeout(),
+		WriteTimeout:     c.GetServerWriteTimeout(),
+		IdleTimeout:      c.GetServerIdleTimeout(),
+		CompressionLevel: c.GetServerCompressionLevel(),
+		MaxBodyBytes:     c.GetServerMaxBodyBytes(),
+		TLSCert:          c.Server.TLSCert,
+		TLSKey:           c.Server.TLSKey,
+		HTTPRedirectPort: c.Server.HTTPRedirectPort,
+		TLSClientCA:      c.Server.TLSClientCA,
+	}
+	taskMaxRetry := c.GetTaskMaxRetry()
+	eff.Worker = WorkerConfig{
+		MaxWorkers:      c.GetMaxWorkers(),
+		CleanupInterval: c.GetWorkerCleanupInterval(),
+		Queues:          c.GetQueues(),
+		TaskMaxRetry:    &taskMaxRetry,
+		TaskRetention:   c.Worker.TaskRetention,
+	}
+	eff.DNS = DNSConfig{
+		Timeout:                   c.GetDNSTimeout(),
+		MaxServersPerReq:          c.GetMaxServersPerRequest(),
+		MaxConcurrentQueries:      c.GetMaxConcurrentQueries(),
+		MaxRetries:                c.GetMaxRetries(),
+		TaskTimeout:               int(c.GetTaskTimeout() / time.Second),
+		AllowedQTypes:             c.GetAllowedQTypes(),
+		ResolveHostnames:          c.DNS.ResolveHostnames,
+		UseDefaultPublicResolvers: c.DNS.UseDefaultPublicResolvers,
+		BootstrapResolvers:        c.GetBootstrapResolvers(),
+		CacheMaxTTL:               c.DNS.CacheMaxTTL,
+		MaxReverseRange:           c.GetMaxReverseRange(),
+		DefaultQType:              c.GetDefaultQType(),
+		DefaultServers:            c.DNS.DefaultServers,
+	}
+	eff.Metrics = MetricsConfig{Buckets: c.GetMetricsBuckets()}
+	if c.CORS.Enabled() {
+		eff.CORS.AllowedMethods = c.CORS.GetAllowedMethods()
+		eff.CORS.AllowedHeaders = c.CORS.GetAllowedHeaders()
+	}
+	eff.Callbacks.Timeout = c.GetCallbackTimeout()
+	eff.Log = LogConfig{Format: c.GetLogFormat()}
+
+	if len(c.Auth.APIKeys) > 0 {
+		eff.Auth.APIKeys = make([]string, len(c.Auth.APIKeys))
+		for i := range eff.Auth.APIKeys {
+			eff.Auth.APIKeys[i] = Redacted
+		}
+	}
+	if c.Callbacks.Secret != "" {
+		eff.Callbacks.Secret = Redacted
+	}
+	return &eff
+}
@@ -1,8 +1,15 @@
 package config
 
 import (
+	"context"
+	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -53,3 +60,510 @@ func TestGetDNSTargets(t *testing.T) {
 		t.Error("Expected at least one target")
 	}
 }
+
+func TestGetServerCapabilities(t *testing.T) {
+	cfg := &APIConfig{
+		Servers: []DNSServer{
+			{
+				IP:       "9.9.9.9",
+				Hostname: "dns.quad9.net",
+				Services: []ServiceType{ServiceDo53UDP, ServiceDo53TCP, ServiceDoT, ServiceDoH},
+				Tags:     []string{"QUAD9"},
+			},
+		},
+	}
+
+	caps := cfg.GetServerCapabilities()
+	if len(caps) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(caps))
+	}
+
+	if want := []string{"do53/udp", "do53/tcp", "dot", "doh"}; !reflect.DeepEqual(caps[0].Services, want) {
+		t.Errorf("Expected services %v, got %v", want, caps[0].Services)
+	}
+
+	want := map[string][]string{
+		"Do53": {"udp://9.9.9.9:53", "tcp://9.9.9.9:53"},
+		"DoT":  {"tls://dns.quad9.net:853"},
+		"DoH":  {"https://dns.quad9.net:443/dns-query"},
+	}
+	if !reflect.DeepEqual(caps[0].Targets, want) {
+		t.Errorf("Expected targets %v, got %v", want, caps[0].Targets)
+	}
+}
+
+func TestLoadConfigEnvExpansion(t *testing.T) {
+	t.Setenv("DNSTESTER_TEST_IP", "1.1.1.1")
+
+	yamlContent := `
+servers:
+  - ip: "${DNSTESTER_TEST_IP}"
+    hostname: "${DNSTESTER_TEST_HOST:-dns.example.net}"
+    tags: ["${DNSTESTER_TEST_MISSING}", "cost-$$5"]
+    services:
+      - do53/udp
+server:
+  port: "${DNSTESTER_TEST_PORT:-8080}"
+`
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yamlContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if len(cfg.Servers) != 1 {
+		t.Fatalf("Expected 1 server, got %d", len(cfg.Servers))
+	}
+	server := cfg.Servers[0]
+	if server.IP != "1.1.1.1" {
+		t.Errorf("Expected set var to expand, got IP %q", server.IP)
+	}
+	if server.Hostname != "dns.example.net" {
+		t.Errorf("Expected default for unset var, got hostname %q", server.Hostname)
+	}
+	if want := []string{"", "cost-$5"}; !reflect.DeepEqual(server.Tags, want) {
+		t.Errorf("Expected unset var without default to be empty and $$ to escape, got %q", server.Tags)
+	}
+	if cfg.Server.Port != "8080" {
+		t.Errorf("Expected default port, got %q", cfg.Server.Port)
+	}
+}
+
+func TestLoadConfigEnvDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("dns:\n  default_qtype: mx\n  default_servers: [\"1.1.1.1\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.GetDefaultQType() != "MX" || !reflect.DeepEqual(cfg.DNS.DefaultServers, []string{"udp://1.1.1.1"}) {
+		t.Errorf("Expected normalized file defaults, got %q %q", cfg.GetDefaultQType(), cfg.DNS.DefaultServers)
+	}
+
+	// The environment wins over the file
+	t.Setenv(EnvDefaultQType, "aaaa")
+	t.Setenv(EnvDefaultServers, "9.9.9.9, tls://dns.quad9.net,")
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.GetDefaultQType() != "AAAA" {
+		t.Errorf("Expected AAAA from %s, got %q", EnvDefaultQType, cfg.GetDefaultQType())
+	}
+	var targets []string
+	for _, target := range cfg.GetDNSTargets() {
+		targets = append(targets, target.Target)
+	}
+	if want := []string{"udp://9.9.9.9", "tls://dns.quad9.net"}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("Expected %v as targets without configured servers, got %v", want, targets)
+	}
+
+	for env, value := range map[string]string{EnvDefaultQType: "BOGUS", EnvDefaultServers: "9.9.9.9,ftp://x"} {
+		t.Setenv(EnvDefaultQType, "")
+		t.Setenv(EnvDefaultServers, "")
+		t.Setenv(env, value)
+		if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), env) {
+			t.Errorf("Expected an error naming %s=%s, got %v", env, value, err)
+		}
+	}
+}
+
+func TestLoadConfigJSONMatchesYAML(t *testing.T) {
+	yamlContent := `
+servers:
+  - ip: "9.9.9.9"
+    hostname: "dns.quad9.net"
+    tags: ["public"]
+    services: ["do53/udp", "dot", "doh"]
+dns:
+  max_servers_per_req: 10
+`
+	jsonContent := `{
+  "servers": [
+    {"ip": "9.9.9.9", "hostname": "dns.quad9.net", "tags": ["public"], "services": ["do53/udp", "dot", "doh"]}
+  ],
+  "dns": {"max_servers_per_req": 10}
+}`
+
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(yamlPath, []byte(yamlContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, []byte(jsonContent), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	yamlCfg, err := LoadConfig(yamlPath)
+	if err != nil {
+		t.Fatalf("Failed to load YAML config: %v", err)
+	}
+	jsonCfg, err := LoadConfig(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to load JSON config: %v", err)
+	}
+
+	if len(jsonCfg.GetDNSTargets()) != 3 {
+		t.Fatalf("Expected 3 targets from JSON config, got %v", jsonCfg.GetDNSTargets())
+	}
+	if !reflect.DeepEqual(yamlCfg.GetDNSTargets(), jsonCfg.GetDNSTargets()) {
+		t.Errorf("Expected identical targets, YAML %v vs JSON %v", yamlCfg.GetDNSTargets(), jsonCfg.GetDNSTargets())
+	}
+	if jsonCfg.GetMaxServersPerRequest() != 10 {
+		t.Errorf("Expected max_servers_per_req 10 from JSON, got %d", jsonCfg.GetMaxServersPerRequest())
+	}
+
+	// Invalid JSON reports the JSON parser error, not YAML
+	if err := os.WriteFile(jsonPath, []byte(`{"servers": [`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(jsonPath); err == nil || !strings.Contains(err.Error(), "JSON") {
+		t.Errorf("Expected JSON parse error, got %v", err)
+	}
+}
+
+func TestGetDNSTargetsDoHPath(t *testing.T) {
+	cfg := &APIConfig{
+		Servers: []DNSServer{
+			{Hostname: "dns.nextdns.io", Path: "abc123", Services: []ServiceType{ServiceDoH, ServiceDoT}},
+		},
+	}
+
+	want := []DNSTarget{
+		{Target: "https://dns.nextdns.io:443/abc123", Tags: []string{}},
+		{Target: "tls://dns.nextdns.io:853", Tags: []string{}},
+	}
+	if got := cfg.GetDNSTargets(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestGetDNSTargetsIPv6(t *testing.T) {
+	cfg := &APIConfig{
+		Servers: []DNSServer{
+			{IP: "2620:fe::fe", Services: []ServiceType{ServiceDo53UDP, ServiceDoT}},
+			{IP: "[2001:4860:4860::8888]", Port: 5353, Services: []ServiceType{ServiceDo53TCP}},
+		},
+	}
+
+	want := []string{"udp://[2620:fe::fe]:53", "tls://[2620:fe::fe]:853", "tcp://[2001:4860:4860::8888]:5353"}
+	targets := cfg.GetDNSTargets()
+	if len(targets) != len(want) {
+		t.Fatalf("Expected %d targets, got %v", len(want), targets)
+	}
+	for i, target := range targets {
+		if target.Target != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], target.Target)
+		}
+		u, err := url.Parse(target.Target)
+		if err != nil {
+			t.Fatalf("url.Parse(%s): %v", target.Target, err)
+		}
+		if u.Port() == "" {
+			t.Errorf("Expected %s to keep its port", target.Target)
+		}
+	}
+}
+
+func TestLoadConfigResolveHostnames(t *testing.T) {
+	orig := lookupHost
+	t.Cleanup(func() { lookupHost = orig })
+	lookupHost = func(_ context.Context, host string) ([]string, error) {
+		if host == "dns.google" {
+			return []string{"8.8.8.8", "8.8.4.4"}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+dns:
+  resolve_hostnames: true
+servers:
+  - hostname: "dns.google"
+    services: ["do53/udp", "dot"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := []string{"udp://8.8.8.8:53", "tls://dns.google:853"}
+	targets := cfg.GetDNSTargets()
+	if len(targets) != len(want) {
+		t.Fatalf("Expected %d targets, got %v", len(want), targets)
+	}
+	for i, target := range targets {
+		if target.Target != want[i] {
+			t.Errorf("Expected %s, got %s", want[i], target.Target)
+		}
+	}
+
+	// Strict by default: the same hostname-only do53 server is rejected
+	strict := strings.Replace(content, "resolve_hostnames: true", "resolve_hostnames: false", 1)
+	if err := os.WriteFile(path, []byte(strict), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("Expected hostname-only do53 server to be rejected without resolve_hostnames")
+	}
+
+	unresolvable := strings.Replace(content, "dns.google", "nope.invalid", 1)
+	if err := os.WriteFile(path, []byte(unresolvable), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "nope.invalid") {
+		t.Errorf("Expected resolution error, got %v", err)
+	}
+}
+
+func TestLoadConfigMetricsBuckets(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	if err := os.WriteFile(valid, []byte("metrics:\n  buckets: [0.001, 0.01, 0.1]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(valid)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if want := []float64{0.001, 0.01, 0.1}; !reflect.DeepEqual(cfg.GetMetricsBuckets(), want) {
+		t.Errorf("Expected buckets %v, got %v", want, cfg.GetMetricsBuckets())
+	}
+
+	unordered := filepath.Join(dir, "unordered.yaml")
+	if err := os.WriteFile(unordered, []byte("metrics:\n  buckets: [0.1, 0.01]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(unordered); err == nil {
+		t.Error("Expected error for non-ascending buckets")
+	}
+}
+
+func TestLoadConfigRateLimitTiers(t *testing.T) {
+	dir := t.TempDir()
+
+	tiered := filepath.Join(dir, "tiered.yaml")
+	data := "rate_limiting:\n  default_rps: 5\n  keyed_rps: 50\nauth:\n  api_keys: [\"k1\"]\n"
+	if err := os.WriteFile(tiered, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(tiered)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !cfg.RateLimiting.Enabled() {
+		t.Error("Expected rate limiting to be enabled")
+	}
+	if got := cfg.GetRateLimitRequestsPerSecond(); got != 5 {
+		t.Errorf("Expected default_rps 5, got %d", got)
+	}
+	if got := cfg.GetRateLimitKeyedRequestsPerSecond(); got != 50 {
+		t.Errorf("Expected keyed_rps 50, got %d", got)
+	}
+
+	untiered := &APIConfig{RateLimiting: RateLimitConfig{RequestsPerSecond: 7}}
+	if got := untiered.GetRateLimitKeyedRequestsPerSecond(); got != 7 {
+		t.Errorf("Expected keyed rate to fall back to requests_per_second, got %d", got)
+	}
+
+	noKeys := filepath.Join(dir, "nokeys.yaml")
+	if err := os.WriteFile(noKeys, []byte("rate_limiting:\n  keyed_rps: 50\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(noKeys); err == nil {
+		t.Error("Expected error for keyed_rps without auth.api_keys")
+	}
+}
+
+func TestLoadConfigBootstrapResolvers(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "valid.yaml")
+	data := "dns:\n  bootstrap_resolvers: [\"9.9.9.9\", \"udp://[2620:fe::fe]:53\", \"tls://1.1.1.1\"]\n"
+	if err := os.WriteFile(valid, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(valid)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if got := cfg.GetBootstrapResolvers(); len(got) != 3 || got[0] != "9.9.9.9" {
+		t.Errorf("Expected 3 bootstrap resolvers, got %v", got)
+	}
+
+	hostname := filepath.Join(dir, "hostname.yaml")
+	if err := os.WriteFile(hostname, []byte("dns:\n  bootstrap_resolvers: [\"tls://dns.quad9.net\"]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(hostname); err == nil {
+		t.Error("Expected error for a bootstrap resolver given by hostname")
+	}
+}
+
+func TestGetDNSTargetsPins(t *testing.T) {
+	pin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+	cfg := &APIConfig{Servers: []DNSServer{{
+		IP:        "9.9.9.9",
+		Hostname:  "dns.quad9.net",
+		Services:  []ServiceType{ServiceDo53UDP, ServiceDoT},
+		PinSHA256: []string{pin},
+	}}}
+	if errs := cfg.Check(); len(errs) > 0 {
+		t.Fatalf("Unexpected config errors: %v", errs)
+	}
+
+	for _, target := range cfg.GetDNSTargets() {
+		pinned := len(target.PinSHA256) == 1
+		if wantPinned := strings.HasPrefix(target.Target, "tls://"); pinned != wantPinned {
+			t.Errorf("Target %s: pinned = %v, want %v", target.Target, pinned, wantPinned)
+		}
+	}
+
+	cfg.Servers[0].Services = []ServiceType{ServiceDo53UDP}
+	if errs := cfg.Check(); len(errs) == 0 {
+		t.Error("Expected error for pin_sha256 without an encrypted service")
+	}
+	cfg.Servers[0].Services = []ServiceType{ServiceDoT}
+	cfg.Servers[0].PinSHA256 = []string{"not-a-pin"}
+	if errs := cfg.Check(); len(errs) == 0 {
+		t.Error("Expected error for a malformed pin")
+	}
+}
+
+func TestEffective(t *testing.T) {
+	cfg := &APIConfig{
+		DNS:          DNSConfig{MaxRetries: 1},
+		RateLimiting: RateLimitConfig{DefaultRPS: 7},
+		Auth:         AuthConfig{APIKeys: []string{"k1", "k2"}},
+		Callbacks:    CallbackConfig{Secret: "s"},
+	}
+	eff := cfg.Effective()
+
+	if eff.DNS.Timeout != 5 || eff.DNS.MaxRetries != 1 || eff.DNS.MaxServersPerReq != 50 {
+		t.Errorf("Expected defaults and overrides applied, got %+v", eff.DNS)
+	}
+	if eff.RateLimiting.RequestsPerSecond != 7 || eff.RateLimiting.KeyedRPS != 7 || eff.RateLimiting.BurstSize != 20 {
+		t.Errorf("Expected resolved rate limits, got %+v", eff.RateLimiting)
+	}
+	if eff.Worker.Queues[DefaultQueue] != 1 || eff.Log.Format != "text" {
+		t.Errorf("Expected worker and log defaults, got %+v %+v", eff.Worker, eff.Log)
+	}
+	if len(eff.Auth.APIKeys) != 2 || eff.Auth.APIKeys[0] != Redacted || eff.Callbacks.Secret != Redacted {
+		t.Errorf("Expected secrets redacted, got %v / %q", eff.Auth.APIKeys, eff.Callbacks.Secret)
+	}
+	if cfg.Auth.APIKeys[0] != "k1" || cfg.Callbacks.Secret != "s" || cfg.DNS.Timeout != 0 {
+		t.Errorf("Effective must not modify the original config, got %+v", cfg)
+	}
+
+	if disabled := (&APIConfig{}).Effective(); disabled.RateLimiting.Enabled() {
+		t.Errorf("Expected rate limiting to stay disabled, got %+v", disabled.RateLimiting)
+	}
+}
+
+func TestCheckTaskRetryAndRetention(t *testing.T) {
+	negative := -1
+	cfg := &APIConfig{Worker: WorkerConfig{TaskMaxRetry: &negative, TaskRetention: -5}}
+	if errs := cfg.Check(); len(errs) != 2 {
+		t.Errorf("Expected 2 errors for negative task_max_retry and task_retention, got %v", errs)
+	}
+
+	zero := 0
+	cfg = &APIConfig{Worker: WorkerConfig{TaskMaxRetry: &zero, TaskRetention: 60}}
+	if errs := cfg.Check(); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	if cfg.GetTaskMaxRetry() != 0 || cfg.GetTaskRetention() != time.Minute {
+		t.Errorf("Expected explicit 0 retries and 1m retention, got %d / %s", cfg.GetTaskMaxRetry(), cfg.GetTaskRetention())
+	}
+	if (&APIConfig{}).GetTaskMaxRetry() != DefaultTaskMaxRetry {
+		t.Errorf("Expected default task_max_retry %d", DefaultTaskMaxRetry)
+	}
+}
+
+func TestCheckQueuesNeedDefault(t *testing.T) {
+	cfg := &APIConfig{Worker: WorkerConfig{Queues: map[string]int{"critical": 6, "low": 1}}}
+	if errs := cfg.Check(); len(errs) != 1 {
+		t.Errorf("Expected 1 error for queues without default, got %v", errs)
+	}
+
+	cfg = &APIConfig{Worker: WorkerConfig{Queues: map[string]int{DefaultQueue: 6, "low": 1}}}
+	if errs := cfg.Check(); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	path := filepath.Join(t.TempDir(), "queues.yaml")
+	if err := os.WriteFile(path, []byte("worker:\n  queues:\n    critical: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "worker.queues") {
+		t.Errorf("Expected LoadConfig to reject queues without default, got %v", err)
+	}
+}
+
+func TestCheckServerTLS(t *testing.T) {
+	tests := []struct {
+		name   string
+		server ServerConfig
+		want   int
+	}{
+		{"cert and key", ServerConfig{TLSCert: "cert.pem", TLSKey: "key.pem", HTTPRedirectPort: "80"}, 0},
+		{"cert without key", ServerConfig{TLSCert: "cert.pem"}, 1},
+		{"redirect without TLS", ServerConfig{HTTPRedirectPort: "80"}, 1},
+		{"redirect on the TLS port", ServerConfig{Port: "443", TLSCert: "cert.pem", TLSKey: "key.pem", HTTPRedirectPort: "443"}, 1},
+		{"client CA", ServerConfig{TLSCert: "cert.pem", TLSKey: "key.pem", TLSClientCA: "ca.pem"}, 0},
+		{"client CA without TLS", ServerConfig{TLSClientCA: "ca.pem"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &APIConfig{Server: tt.server}
+			if errs := cfg.Check(); len(errs) != tt.want {
+				t.Errorf("Expected %d errors, got %v", tt.want, errs)
+			}
+		})
+	}
+}
+
+func TestGetTaskTimeout(t *testing.T) {
+	if got := (&APIConfig{}).GetTaskTimeout(); got != 15*time.Second {
+		t.Errorf("Expected 15s default task_timeout, got %s", got)
+	}
+	cfg := &APIConfig{DNS: DNSConfig{TaskTimeout: 8}}
+	if got := cfg.GetTaskTimeout(); got != 8*time.Second {
+		t.Errorf("Expected 8s task_timeout, got %s", got)
+	}
+	cfg = &APIConfig{DNS: DNSConfig{TaskTimeout: -1}}
+	if errs := cfg.Check(); len(errs) != 1 {
+		t.Errorf("Expected 1 error for negative task_timeout, got %v", errs)
+	}
+}
+
+func TestCheckCacheMaxTTL(t *testing.T) {
+	cfg := &APIConfig{DNS: DNSConfig{CacheMaxTTL: -1}}
+	if errs := cfg.Check(); len(errs) != 1 {
+		t.Errorf("Expected 1 error for negative cache_max_ttl, got %v", errs)
+	}
+
+	cfg = &APIConfig{DNS: DNSConfig{CacheMaxTTL: 30}}
+	if errs := cfg.Check(); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+	if cfg.GetCacheMaxTTL() != 30*time.Second {
+		t.Errorf("Expected 30s cache_max_ttl, got %s", cfg.GetCacheMaxTTL())
+	}
+	if (&APIConfig{}).GetCacheMaxTTL() != 0 {
+		t.Error("Expected the cache to be disabled by default")
+	}
+}
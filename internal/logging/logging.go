@@ -0,0 +1,39 @@
+// Package logging selects the global slog handler for the server and worker.
+// Text stays the default for terminals; JSON is meant for log aggregation.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	// FormatText is the human-readable key=value format (default)
+	FormatText = "text"
+	// FormatJSON emits one JSON object per line
+	FormatJSON = "json"
+)
+
+// NewHandler returns a slog handler writing format to w; empty format means text.
+func NewHandler(format string, w io.Writer) (slog.Handler, error) {
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.NewTextHandler(w, nil), nil
+	case FormatJSON:
+		return slog.NewJSONHandler(w, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown log format '%s' (must be text or json)", format)
+	}
+}
+
+// Setup installs the handler for format as the default slog logger.
+func Setup(format string, w io.Writer) error {
+	h, err := NewHandler(format, w)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestSetupJSON(t *testing.T) {
+	prev := slog.Default()
+	defer slog.SetDefault(prev)
+
+	var buf bytes.Buffer
+	if err := Setup(FormatJSON, &buf); err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("hello", "key", "value")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("Expected a JSON log line, got %q: %v", buf.String(), err)
+	}
+	if line["msg"] != "hello" || line["key"] != "value" {
+		t.Errorf("Unexpected log line: %v", line)
+	}
+}
+
+func TestNewHandlerUnknownFormat(t *testing.T) {
+	if _, err := NewHandler("xml", &bytes.Buffer{}); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+	if _, err := NewHandler("", &bytes.Buffer{}); err != nil {
+		t.Errorf("Expected empty format to default to text, got %v", err)
+	}
+}
@@ -3,8 +3,52 @@
 package metrics
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// Duration histogram definitions, kept apart so SetDurationBuckets can rebuild them.
+var (
+	lookupDurationOpts = prometheus.HistogramOpts{
+		Name:    "dns_lookup_duration_seconds",
+		Help:    "DNS lookup duration in seconds",
+		Buckets: prometheus.DefBuckets,
+	}
+	lookupDurationLabels = []string{"server", "query_type"}
+
+	protocolDurationOpts = prometheus.HistogramOpts{
+		Name:    "dns_protocol_duration_seconds",
+		Help:    "DNS lookup duration in seconds by protocol",
+		Buckets: prometheus.DefBuckets,
+	}
+	protocolDurationLabels = []string{"protocol"}
+
+	responseTimeOpts = prometheus.HistogramOpts{
+		Name:    "dns_response_time_seconds",
+		Help:    "Time taken for DNS resolution",
+		Buckets: prometheus.DefBuckets,
+	}
+	responseTimeLabels = []string{"server"}
+
+	dohPhaseOpts = prometheus.HistogramOpts{
+		Name:    "dns_doh_phase_duration_seconds",
+		Help:    "DoH request duration in seconds by HTTP phase",
+		Buckets: prometheus.DefBuckets,
+	}
+	dohPhaseLabels = []string{"target", "phase"}
+)
+
+// DoH request phases observed by DNSDoHPhaseDuration.
+const (
+	DoHPhaseDNS       = "dns"
+	DoHPhaseConnect   = "connect"
+	DoHPhaseTLS       = "tls"
+	DoHPhaseFirstByte = "first_byte"
+	DoHPhaseTotal     = "total"
 )
 
 var (
@@ -18,14 +62,10 @@ var (
 	)
 
 	// DNSLookupDuration tracks DNS lookup duration in seconds
-	DNSLookupDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "dns_lookup_duration_seconds",
-			Help:    "DNS lookup duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"server", "query_type"},
-	)
+	DNSLookupDuration = promauto.NewHistogramVec(lookupDurationOpts, lookupDurationLabels)
+
+	// DNSProtocolDuration tracks DNS lookup duration per protocol (Do53, DoT, DoH, DoQ)
+	DNSProtocolDuration = promauto.NewHistogramVec(protocolDurationOpts, protocolDurationLabels)
 
 	// DNSLookupErrors tracks DNS lookup errors by server and error type
 	DNSLookupErrors = promauto.NewCounterVec(
@@ -36,6 +76,16 @@ var (
 		[]string{"server", "error_type"},
 	)
 
+	// DNSAnswerCount tracks how many answer records each successful query returned
+	DNSAnswerCount = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "dns_answer_count",
+			Help:    "Number of answer records per successful DNS query",
+			Buckets: []float64{0, 1, 2, 3, 5, 10, 20, 50},
+		},
+		[]string{"target", "qtype"},
+	)
+
 	// TasksTotal tracks the total number of DNS tasks by status
 	TasksTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -62,16 +112,58 @@ var (
 		},
 	)
 
-	// DNSResponseTime tracks DNS resolution time (Python dnstester compat).
-	DNSResponseTime = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "dns_response_time_seconds",
-			Help:    "Time taken for DNS resolution",
-			Buckets: prometheus.DefBuckets,
+	// DNSResponseRCodeTotal tracks every DNS response by target and rcode
+	DNSResponseRCodeTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dns_response_rcode_total",
+			Help: "Total number of DNS responses by response code",
 		},
-		[]string{"server"},
+		[]string{"target", "rcode"},
+	)
+
+	// TaskQueueDepth tracks tasks waiting to be processed per queue
+	TaskQueueDepth = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dns_task_queue_depth",
+			Help: "Number of pending DNS tasks per queue",
+		},
+		[]string{"queue"},
+	)
+
+	// ActiveWorkers tracks the number of connected task workers
+	ActiveWorkers = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dns_active_workers",
+			Help: "Number of workers connected to the task queue",
+		},
+	)
+
+	// UpstreamConnectionsCreated counts connections opened to DNS servers by protocol
+	UpstreamConnectionsCreated = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dns_upstream_connections_created_total",
+			Help: "Connections opened to DNS servers (one per TLS or QUIC handshake for encrypted protocols)",
+		},
+		[]string{"protocol"},
+	)
+
+	// UpstreamConnectionsReused counts encrypted queries answered over an existing connection
+	UpstreamConnectionsReused = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dns_upstream_connections_reused_total",
+			Help: "Encrypted DNS queries answered without a new handshake",
+		},
+		[]string{"protocol"},
 	)
 
+	// DNSResponseTime tracks DNS resolution time (Python dnstester compat).
+	DNSResponseTime = promauto.NewHistogramVec(responseTimeOpts, responseTimeLabels)
+
+	// DNSDoHPhaseDuration breaks DoH requests down into endpoint DNS, connect, TLS,
+	// time to first byte and total, each measured from the start of its own phase
+	// except first_byte and total, which run from the start of the request.
+	DNSDoHPhaseDuration = promauto.NewHistogramVec(dohPhaseOpts, dohPhaseLabels)
+
 	// DNSTotalQueries tracks total DNS queries (Python dnstester compat).
 	DNSTotalQueries = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -124,6 +216,7 @@ func RecordQueryMetrics(server string, responseTimeSec float64, rcode, qtype str
 	DNSResponseTime.WithLabelValues(server).Observe(responseTimeSec)
 	DNSAvgResponseTime.WithLabelValues(server).Set(responseTimeSec)
 	DNSQueryTypesCount.WithLabelValues(qtype).Inc()
+	DNSResponseRCodeTotal.WithLabelValues(server, rcodeLabel(rcode)).Inc()
 
 	if rcode == "NOERROR" {
 		DNSNoErrorCount.WithLabelValues(server).Inc()
@@ -131,3 +224,80 @@ func RecordQueryMetrics(server string, responseTimeSec float64, rcode, qtype str
 		DNSFailureCount.WithLabelValues(server, rcode).Inc()
 	}
 }
+
+// RecordLookupResults records lookup totals and durations for a finished task.
+// Called where the task runs (worker or memory client) so unpolled results are counted too.
+// Error reasons are already recorded by the resolver as each query fails.
+func RecordLookupResults(details map[string]models.DNSLookupResult) {
+	for target, detail := range details {
+		qtype := detail.QType
+		if qtype == "" {
+			qtype = "A"
+		}
+
+		if detail.CommandStatus != "ok" {
+			DNSLookupTotal.WithLabelValues(target, qtype, "error").Inc()
+			continue
+		}
+
+		DNSLookupTotal.WithLabelValues(target, qtype, "success").Inc()
+		// A cached result carries the original query's time_ms, which was observed back then
+		if detail.Cached {
+			continue
+		}
+		DNSLookupDuration.WithLabelValues(target, qtype).Observe(detail.TimeMs / 1000.0)
+		protocol := detail.DNSProtocol
+		if protocol == "" {
+			protocol = "Unknown"
+		}
+		DNSProtocolDuration.WithLabelValues(protocol).Observe(detail.TimeMs / 1000.0)
+	}
+}
+
+// SetDurationBuckets re-registers the duration histograms with custom bucket bounds.
+// Call it at startup before any lookup is observed; empty buckets keep the defaults.
+func SetDurationBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return nil
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("histogram buckets must be strictly ascending (%g follows %g)", buckets[i], buckets[i-1])
+		}
+	}
+
+	var err error
+	if DNSLookupDuration, err = replaceHistogram(DNSLookupDuration, lookupDurationOpts, lookupDurationLabels, buckets); err != nil {
+		return err
+	}
+	if DNSProtocolDuration, err = replaceHistogram(DNSProtocolDuration, protocolDurationOpts, protocolDurationLabels, buckets); err != nil {
+		return err
+	}
+	if DNSResponseTime, err = replaceHistogram(DNSResponseTime, responseTimeOpts, responseTimeLabels, buckets); err != nil {
+		return err
+	}
+	if DNSDoHPhaseDuration, err = replaceHistogram(DNSDoHPhaseDuration, dohPhaseOpts, dohPhaseLabels, buckets); err != nil {
+		return err
+	}
+	return nil
+}
+
+// replaceHistogram swaps a registered histogram for one with the given buckets.
+func replaceHistogram(old *prometheus.HistogramVec, opts prometheus.HistogramOpts, labels []string, buckets []float64) (*prometheus.HistogramVec, error) {
+	prometheus.Unregister(old)
+	opts.Buckets = buckets
+	vec := prometheus.NewHistogramVec(opts, labels)
+	if err := prometheus.Register(vec); err != nil {
+		_ = prometheus.Register(old)
+		return old, fmt.Errorf("register %s: %w", opts.Name, err)
+	}
+	return vec, nil
+}
+
+// rcodeLabel folds unmapped rcodes like "UNKNOWN(23)" into a single UNKNOWN bucket.
+func rcodeLabel(rcode string) string {
+	if rcode == "" || strings.HasPrefix(rcode, "UNKNOWN") {
+		return "UNKNOWN"
+	}
+	return rcode
+}
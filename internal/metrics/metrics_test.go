@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestRecordQueryMetricsRCode(t *testing.T) {
+	const target = "udp://192.0.2.1:53"
+
+	before := testutil.ToFloat64(DNSResponseRCodeTotal.WithLabelValues(target, "NXDOMAIN"))
+	RecordQueryMetrics(target, 0.01, "NXDOMAIN", "A")
+
+	if got := testutil.ToFloat64(DNSResponseRCodeTotal.WithLabelValues(target, "NXDOMAIN")); got != before+1 {
+		t.Errorf("Expected NXDOMAIN counter %v, got %v", before+1, got)
+	}
+	if got := testutil.ToFloat64(DNSResponseRCodeTotal.WithLabelValues(target, "NOERROR")); got != 0 {
+		t.Errorf("Expected NOERROR counter untouched, got %v", got)
+	}
+}
+
+func TestRecordQueryMetricsUnknownRCode(t *testing.T) {
+	const target = "udp://192.0.2.2:53"
+
+	RecordQueryMetrics(target, 0.01, "UNKNOWN(23)", "A")
+	RecordQueryMetrics(target, 0.01, "", "A")
+
+	if got := testutil.ToFloat64(DNSResponseRCodeTotal.WithLabelValues(target, "UNKNOWN")); got != 2 {
+		t.Errorf("Expected 2 UNKNOWN responses, got %v", got)
+	}
+}
+
+func TestRecordLookupResultsSkipsCachedDurations(t *testing.T) {
+	const fresh, cached = "udp://192.0.2.3:53", "udp://192.0.2.4:53"
+
+	RecordLookupResults(map[string]models.DNSLookupResult{
+		fresh:  {CommandStatus: "ok", QType: "A", TimeMs: 20},
+		cached: {CommandStatus: "ok", QType: "A", TimeMs: 20, Cached: true},
+	})
+
+	if got := testutil.ToFloat64(DNSLookupTotal.WithLabelValues(cached, "A", "success")); got != 1 {
+		t.Errorf("Expected the cached lookup to be counted, got %v", got)
+	}
+	if got := histogramSamples(t, "dns_lookup_duration_seconds", fresh); got != 1 {
+		t.Errorf("Expected 1 duration sample for the fresh lookup, got %d", got)
+	}
+	if got := histogramSamples(t, "dns_lookup_duration_seconds", cached); got != 0 {
+		t.Errorf("Expected no duration sample for the cached lookup, got %d", got)
+	}
+}
+
+// histogramSamples returns how many observations a histogram metric holds for server.
+func histogramSamples(t *testing.T, name, server string) uint64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "server" && label.GetValue() == server {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// histogramBounds returns the bucket upper bounds exported for a histogram metric.
+func histogramBounds(t *testing.T, name string) []float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() != name || len(mf.GetMetric()) == 0 {
+			continue
+		}
+		var bounds []float64
+		for _, b := range mf.GetMetric()[0].GetHistogram().GetBucket() {
+			bounds = append(bounds, b.GetUpperBound())
+		}
+		return bounds
+	}
+	t.Fatalf("metric %s not found", name)
+	return nil
+}
+
+func TestSetDurationBuckets(t *testing.T) {
+	buckets := []float64{0.001, 0.005, 0.01, 0.1, 1}
+	if err := SetDurationBuckets(buckets); err != nil {
+		t.Fatalf("SetDurationBuckets failed: %v", err)
+	}
+	defer func() {
+		if err := SetDurationBuckets(prometheus.DefBuckets); err != nil {
+			t.Errorf("restoring default buckets failed: %v", err)
+		}
+	}()
+
+	DNSLookupDuration.WithLabelValues("udp://192.0.2.3:53", "A").Observe(0.002)
+	DNSProtocolDuration.WithLabelValues("Do53").Observe(0.002)
+
+	for _, name := range []string{"dns_lookup_duration_seconds", "dns_protocol_duration_seconds"} {
+		if got := histogramBounds(t, name); !reflect.DeepEqual(got, buckets) {
+			t.Errorf("%s buckets = %v, want %v", name, got, buckets)
+		}
+	}
+}
+
+func TestSetDurationBucketsRejectsUnordered(t *testing.T) {
+	if err := SetDurationBuckets([]float64{0.1, 0.05}); err == nil {
+		t.Error("Expected error for non-ascending buckets")
+	}
+}
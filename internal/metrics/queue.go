@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// DefaultQueueMetricsInterval is how often queue gauges are refreshed.
+const DefaultQueueMetricsInterval = 15 * time.Second
+
+// QueueInspector reports task backlog and worker count for the queue gauges.
+type QueueInspector interface {
+	QueueDepths(ctx context.Context) (map[string]int, error)
+	ActiveWorkers(ctx context.Context) (int, error)
+}
+
+// UpdateQueueMetrics refreshes TaskQueueDepth and ActiveWorkers from the inspector.
+// Queues that no longer exist are dropped so their series do not go stale.
+func UpdateQueueMetrics(ctx context.Context, insp QueueInspector) error {
+	depths, err := insp.QueueDepths(ctx)
+	if err != nil {
+		return err
+	}
+	workers, err := insp.ActiveWorkers(ctx)
+	if err != nil {
+		return err
+	}
+
+	TaskQueueDepth.Reset()
+	for queue, depth := range depths {
+		TaskQueueDepth.WithLabelValues(queue).Set(float64(depth))
+	}
+	ActiveWorkers.Set(float64(workers))
+	return nil
+}
+
+// RunQueueMetrics updates queue gauges immediately and then on every tick until ctx is done.
+func RunQueueMetrics(ctx context.Context, insp QueueInspector, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultQueueMetricsInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := UpdateQueueMetrics(ctx, insp); err != nil {
+			slog.Warn("Failed to update queue metrics", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type fakeInspector struct {
+	depths  map[string]int
+	workers int
+	err     error
+}
+
+func (f *fakeInspector) QueueDepths(_ context.Context) (map[string]int, error) {
+	return f.depths, f.err
+}
+
+func (f *fakeInspector) ActiveWorkers(_ context.Context) (int, error) {
+	return f.workers, f.err
+}
+
+func TestQueueMetricsRegistered(t *testing.T) {
+	TaskQueueDepth.WithLabelValues("default").Set(0)
+	ActiveWorkers.Set(0)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather failed: %v", err)
+	}
+
+	found := map[string]bool{}
+	for _, mf := range families {
+		found[mf.GetName()] = true
+	}
+	for _, name := range []string{"dns_task_queue_depth", "dns_active_workers"} {
+		if !found[name] {
+			t.Errorf("Expected metric %s to be registered", name)
+		}
+	}
+}
+
+func TestUpdateQueueMetrics(t *testing.T) {
+	insp := &fakeInspector{
+		depths:  map[string]int{"default": 7, "critical": 2},
+		workers: 3,
+	}
+	if err := UpdateQueueMetrics(context.Background(), insp); err != nil {
+		t.Fatalf("UpdateQueueMetrics failed: %v", err)
+	}
+
+	if got := testutil.ToFloat64(TaskQueueDepth.WithLabelValues("default")); got != 7 {
+		t.Errorf("Expected default queue depth 7, got %v", got)
+	}
+	if got := testutil.ToFloat64(TaskQueueDepth.WithLabelValues("critical")); got != 2 {
+		t.Errorf("Expected critical queue depth 2, got %v", got)
+	}
+	if got := testutil.ToFloat64(ActiveWorkers); got != 3 {
+		t.Errorf("Expected 3 active workers, got %v", got)
+	}
+
+	// A queue that disappears must not keep reporting its last depth
+	insp.depths = map[string]int{"default": 1}
+	if err := UpdateQueueMetrics(context.Background(), insp); err != nil {
+		t.Fatalf("UpdateQueueMetrics failed: %v", err)
+	}
+	if got := testutil.CollectAndCount(TaskQueueDepth); got != 1 {
+		t.Errorf("Expected 1 queue series after update, got %d", got)
+	}
+}
+
+func TestUpdateQueueMetricsError(t *testing.T) {
+	ActiveWorkers.Set(5)
+	insp := &fakeInspector{err: errors.New("redis down")}
+
+	if err := UpdateQueueMetrics(context.Background(), insp); err == nil {
+		t.Fatal("Expected error from failing inspector")
+	}
+	if got := testutil.ToFloat64(ActiveWorkers); got != 5 {
+		t.Errorf("Expected gauges to keep previous value on error, got %v", got)
+	}
+}
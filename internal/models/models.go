@@ -3,6 +3,9 @@ package models
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
@@ -11,13 +14,33 @@ import (
 const (
 	// MaxDNSServersPerReq limits servers per request to prevent resource exhaustion.
 	MaxDNSServersPerReq = 50
+
+	// DoHMethodGET sends DoH queries as RFC 8484 GET requests (cache friendly, dnsproxy default)
+	DoHMethodGET = "GET"
+	// DoHMethodPOST sends DoH queries as RFC 8484 POST requests
+	DoHMethodPOST = "POST"
+
+	// QClassIN is the Internet class used by default
+	QClassIN = "IN"
+	// QClassCH is the CHAOS class (version.bind, id.server fingerprinting)
+	QClassCH = "CH"
+	// QClassHS is the Hesiod class
+	QClassHS = "HS"
+
+	// IPFamilyAuto lets hostname targets be dialled over either address family (default)
+	IPFamilyAuto = "auto"
+	// IPFamilyIPv4 only dials the A addresses of hostname targets
+	IPFamilyIPv4 = "ipv4"
+	// IPFamilyIPv6 only dials the AAAA addresses of hostname targets
+	IPFamilyIPv6 = "ipv6"
 )
 
 // DNSServer represents a DNS server target with optional tags
 // @Description DNS server configuration with protocol://host:port format
 type DNSServer struct {
-	Target string   `json:"target" example:"udp://8.8.8.8:53"`              // DNS server in format protocol://host:port
-	Tags   []string `json:"tags,omitempty" example:"GOOGLE,PRIMARY,PUBLIC"` // Optional tags for identification
+	Target    string   `json:"target" example:"udp://8.8.8.8:53"`              // DNS server in format protocol://host:port
+	Tags      []string `json:"tags,omitempty" example:"GOOGLE,PRIMARY,PUBLIC"` // Optional tags for identification
+	PinSHA256 []string `json:"pin_sha256,omitempty"`                           // Base64 SHA-256 SPKI digests; one must match a presented certificate (DoT/DoH/DoQ)
 }
 
 // Validate delegates target validation to normalize.Target.
@@ -30,32 +53,215 @@ func (d *DNSServer) Validate() error {
 		return fmt.Errorf("invalid DNS server target '%s': %w", d.Target, err)
 	}
 
+	if len(d.PinSHA256) == 0 {
+		return nil
+	}
+	if !normalize.SupportsPins(d.Target) {
+		return fmt.Errorf("DNS server '%s': pin_sha256 requires a DoT, DoH or DoQ target", d.Target)
+	}
+	for _, pin := range d.PinSHA256 {
+		if _, err := normalize.ParsePin(pin); err != nil {
+			return fmt.Errorf("DNS server '%s': %w", d.Target, err)
+		}
+	}
+	return nil
+}
+
+// QueryOptions tunes how every server of a lookup is queried
+// @Description Per-request query options applied to every server
+type QueryOptions struct {
+	TLSInsecureSkipVerify bool                `json:"tls_insecure_skip_verify,omitempty" example:"false"`  // Skip TLS certificate verification (testing only)
+	DoHMethod             string              `json:"doh_method,omitempty" example:"GET" enums:"GET,POST"` // HTTP method for DoH targets (default GET)
+	DoHHTTP3              bool                `json:"doh_http3,omitempty" example:"false"`                 // Send DoH over HTTP/3 (QUIC) instead of HTTP/2 (GET only)
+	ProxyURL              string              `json:"proxy_url,omitempty"`                                 // SOCKS5 or HTTP CONNECT proxy for DoH/DoT/Do53 TCP, e.g. socks5://10.0.0.1:1080
+	SourceIP              string              `json:"source_ip,omitempty" example:"192.0.2.10"`            // Local address queries are sent from (must be assigned to the worker host)
+	Expected              map[string][]string `json:"expected,omitempty"`                                  // Expected answer values per record type, e.g. {"A": ["93.184.216.34"]}
+	RecursionDesired      *bool               `json:"recursion_desired,omitempty" example:"true"`          // RD flag (default true); false to test authoritative servers
+	CheckingDisabled      bool                `json:"checking_disabled,omitempty" example:"false"`         // CD flag: ask the resolver to skip DNSSEC validation
+	AuthenticatedData     bool                `json:"authenticated_data,omitempty" example:"false"`        // AD flag: ask for the AD bit in the reply (RFC 6840)
+	EDNSPadding           bool                `json:"edns_padding,omitempty" example:"false"`              // Send an EDNS0 padding option (RFC 7830) and report whether the reply is padded
+	DNSCookie             bool                `json:"dns_cookie,omitempty" example:"false"`                // Send a DNS Cookie option (RFC 7873) and report whether the server returns a valid server cookie
+	NormalizeAnswers      bool                `json:"normalize_answers,omitempty" example:"false"`         // Sort answers by type then value and drop exact duplicates (default: wire order)
+	QClass                string              `json:"qclass,omitempty" example:"IN" enums:"IN,CH,HS"`      // Question class (default IN); CH for version.bind/id.server
+	NoCache               bool                `json:"no_cache,omitempty" example:"false"`                  // Always query the servers, bypassing the worker's result cache
+	ForwardConfirm        bool                `json:"forward_confirm,omitempty" example:"false"`           // PTR lookups: resolve each PTR name's A/AAAA and report whether it maps back to the IP (FCrDNS)
+	IPFamily              string              `json:"ip_family,omitempty" enums:"auto,ipv4,ipv6"`          // Address family hostname targets are dialled over (default auto)
+	ServerName            string              `json:"server_name,omitempty"`                               // SNI presented and verified by DoT/DoH/DoQ instead of the target host, which is still dialled
+	HTTPHost              string              `json:"http_host,omitempty"`                                 // Host header sent by DoH instead of the target host (HTTP/1.1 and HTTP/2 only)
+}
+
+// WantRecursion reports the RD flag to send; unset means true.
+func (o *QueryOptions) WantRecursion() bool {
+	return o.RecursionDesired == nil || *o.RecursionDesired
+}
+
+// Validate uppercases and checks option values.
+func (o *QueryOptions) Validate() error {
+	method := strings.ToUpper(strings.TrimSpace(o.DoHMethod))
+	switch method {
+	case "", DoHMethodGET, DoHMethodPOST:
+	default:
+		return fmt.Errorf("invalid doh_method '%s' (must be GET or POST)", o.DoHMethod)
+	}
+	o.DoHMethod = method
+	if o.DoHHTTP3 && o.DoHMethod == DoHMethodPOST {
+		return fmt.Errorf("doh_http3 is only supported with doh_method GET")
+	}
+	if o.ProxyURL != "" {
+		u, err := normalize.ParseProxyURL(o.ProxyURL)
+		if err != nil {
+			return err
+		}
+		if o.DoHHTTP3 {
+			return fmt.Errorf("doh_http3 cannot be combined with proxy_url (QUIC does not go through SOCKS5 or HTTP CONNECT)")
+		}
+		o.ProxyURL = u.String()
+	}
+	if o.SourceIP != "" {
+		ip := net.ParseIP(strings.TrimSpace(o.SourceIP))
+		if ip == nil {
+			return fmt.Errorf("invalid source_ip '%s' (must be an IP address)", o.SourceIP)
+		}
+		o.SourceIP = ip.String()
+	}
+
+	if o.ServerName != "" {
+		name, err := normalize.Domain(o.ServerName)
+		if err != nil || net.ParseIP(name) != nil {
+			return fmt.Errorf("invalid server_name '%s' (must be a hostname)", o.ServerName)
+		}
+		o.ServerName = name
+	}
+	if o.HTTPHost != "" {
+		host := strings.ToLower(strings.TrimSpace(o.HTTPHost))
+		if u, err := url.Parse("//" + host); err != nil || u.Host != host || u.Hostname() == "" {
+			return fmt.Errorf("invalid http_host '%s' (must be host or host:port)", o.HTTPHost)
+		}
+		if o.DoHHTTP3 {
+			return fmt.Errorf("http_host cannot be combined with doh_http3")
+		}
+		o.HTTPHost = host
+	}
+
+	qclass := strings.ToUpper(strings.TrimSpace(o.QClass))
+	switch qclass {
+	case "", QClassIN, QClassCH, QClassHS:
+	default:
+		return fmt.Errorf("invalid qclass '%s' (must be IN, CH or HS)", o.QClass)
+	}
+	o.QClass = qclass
+
+	family := strings.ToLower(strings.TrimSpace(o.IPFamily))
+	switch family {
+	case IPFamilyAuto:
+		family = ""
+	case "", IPFamilyIPv4, IPFamilyIPv6:
+	default:
+		return fmt.Errorf("invalid ip_family '%s' (must be auto, ipv4 or ipv6)", o.IPFamily)
+	}
+	o.IPFamily = family
+	if family != "" && o.ProxyURL != "" {
+		return fmt.Errorf("ip_family cannot be combined with proxy_url (the proxy resolves the target)")
+	}
+	if ip := net.ParseIP(o.SourceIP); family != "" && ip != nil && (ip.To4() != nil) != (family == IPFamilyIPv4) {
+		return fmt.Errorf("source_ip %s does not belong to ip_family %s", o.SourceIP, family)
+	}
+
+	if len(o.Expected) == 0 {
+		return nil
+	}
+	expected := make(map[string][]string, len(o.Expected))
+	for rrtype, values := range o.Expected {
+		upper := strings.ToUpper(strings.TrimSpace(rrtype))
+		if !normalize.IsValidQType(upper) {
+			return fmt.Errorf("invalid expected record type '%s'", rrtype)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("expected %s must list at least one value", upper)
+		}
+		expected[upper] = append(expected[upper], values...)
+	}
+	o.Expected = expected
+	return nil
+}
+
+// TaskOptions controls what happens around a lookup task rather than each query
+// @Description Per-request task options
+type TaskOptions struct {
+	CallbackURL string `json:"callback_url,omitempty" example:"https://hooks.example.com/dns"` // URL receiving the completed task status as a POST
+	TaskID      string `json:"task_id,omitempty" example:"nightly-example-com"`                // Client-chosen task ID; resubmitting it returns the existing task instead of a new one
+	DryRun      bool   `json:"dry_run,omitempty" example:"false"`                              // Validate and return what would be queried without enqueuing anything
+}
+
+// MaxTaskIDLength bounds client-supplied task IDs.
+const MaxTaskIDLength = 128
+
+// Validate requires an absolute https callback URL (http only when allowHTTP is set)
+// and a URL-safe task ID.
+func (o *TaskOptions) Validate(allowHTTP bool) error {
+	if o.TaskID != "" && !validTaskID(o.TaskID) {
+		return fmt.Errorf("invalid task_id '%s' (1-%d letters, digits, '.', '_' or '-')", o.TaskID, MaxTaskIDLength)
+	}
+	if o.CallbackURL == "" {
+		return nil
+	}
+
+	u, err := url.Parse(o.CallbackURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid callback_url '%s'", o.CallbackURL)
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && allowHTTP:
+	default:
+		return fmt.Errorf("invalid callback_url '%s' (must use https)", o.CallbackURL)
+	}
 	return nil
 }
 
+// validTaskID keeps task IDs usable as URL path segments and Redis key suffixes.
+func validTaskID(id string) bool {
+	if len(id) > MaxTaskIDLength {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
 // DNSLookupRequest represents a DNS lookup API request
 // @Description DNS lookup request with domain, query type, and optional DNS servers
 type DNSLookupRequest struct {
-	Domain                string      `json:"domain" binding:"required" example:"example.com"`    // Domain name to query
-	DNSServers            []DNSServer `json:"dns_servers,omitempty"`                              // DNS servers to query (optional, uses config if empty)
-	QType                 string      `json:"qtype" binding:"required" example:"A"`               // Query type (A, AAAA, MX, TXT, etc.)
-	TLSInsecureSkipVerify bool        `json:"tls_insecure_skip_verify,omitempty" example:"false"` // Skip TLS certificate verification (testing only)
+	Domain     string      `json:"domain" binding:"required" example:"example.com"` // Domain name to query
+	DNSServers []DNSServer `json:"dns_servers,omitempty"`                           // DNS servers to query (optional, uses config if empty)
+	QType      string      `json:"qtype" binding:"required" example:"A"`            // Query type (A, AAAA, MX, TXT, etc.)
+	QueryOptions
+	TaskOptions
 }
 
-// Validate checks if domain and qtype are valid.
-func (r *DNSLookupRequest) Validate() error {
+// Validate checks if domain, qtype and query options are valid.
+// qtype must be in allowedQTypes (normalize.DefaultAllowedQTypes when empty).
+func (r *DNSLookupRequest) Validate(allowedQTypes []string) error {
 	normalized, err := normalize.Domain(r.Domain)
 	if err != nil {
-		return fmt.Errorf("invalid domain: %w", err)
+		return &ValidationError{Code: ErrCodeInvalidDomain, Err: fmt.Errorf("invalid domain: %w", err)}
 	}
 	r.Domain = normalized
 
-	normalizedQType, err := normalize.QType(r.QType)
+	normalizedQType, err := normalize.AllowedQType(r.QType, allowedQTypes)
 	if err != nil {
-		return fmt.Errorf("invalid query type: %w", err)
+		return &ValidationError{Code: ErrCodeInvalidQType, Err: fmt.Errorf("invalid query type: %w", err)}
 	}
 	r.QType = normalizedQType
 
+	if err := r.QueryOptions.Validate(); err != nil {
+		return &ValidationError{Code: ErrCodeInvalidOptions, Err: err}
+	}
 	return nil
 }
 
@@ -66,6 +272,19 @@ type TaskResponse struct {
 	Message string `json:"message" example:"DNS lookup enqueued"` // Status message
 }
 
+// DryRunResponse previews a validated lookup request that was not enqueued
+// @Description What a dry_run request would query: normalized targets, resolved qtype and effective settings
+type DryRunResponse struct {
+	Domain     string       `json:"domain" example:"example.com"`                                  // Normalized domain
+	QType      string       `json:"qtype" example:"A"`                                             // Query type, after applying the default
+	DNSServers []DNSServer  `json:"dns_servers"`                                                   // Normalized targets, from the config when the request has none
+	Options    QueryOptions `json:"options"`                                                       // Validated query options
+	Timeout    int          `json:"timeout" example:"5"`                                           // Per-query timeout in seconds
+	MaxRetries int          `json:"max_retries" example:"3"`                                       // Attempts per server
+	Queue      string       `json:"queue,omitempty" example:"bulk"`                                // Queue the task would go to (default queue when empty)
+	Message    string       `json:"message" example:"Dry run: request is valid, nothing enqueued"` // Status message
+}
+
 // DNSAnswer represents a single DNS resource record
 // @Description DNS resource record with name, type, TTL, and value
 type DNSAnswer struct {
@@ -78,15 +297,31 @@ type DNSAnswer struct {
 // DNSLookupResult contains the outcome of a single DNS server query
 // @Description Result from a single DNS server query
 type DNSLookupResult struct {
-	CommandStatus string      `json:"command_status" example:"success"`             // Command execution status
-	TimeMs        float64     `json:"time_ms,omitempty" example:"23.45"`            // Query execution time in milliseconds
-	Tags          []string    `json:"tags,omitempty" example:"GOOGLE,PRIMARY"`      // Server tags
-	RCode         string      `json:"rcode,omitempty" example:"NOERROR"`            // DNS response code
-	Name          string      `json:"name,omitempty" example:"example.com."`        // Queried name
-	QType         string      `json:"qtype,omitempty" example:"A"`                  // Query type
-	Answers       []DNSAnswer `json:"answers,omitempty"`                            // DNS answers
-	Error         string      `json:"error,omitempty" example:"connection timeout"` // Error message if query failed
-	DNSProtocol   string      `json:"dns_protocol,omitempty" example:"udp"`         // Protocol used (udp, tcp, tls, https, quic)
+	CommandStatus      string      `json:"command_status" example:"success"`             // Command execution status
+	TimeMs             float64     `json:"time_ms,omitempty" example:"23.45"`            // Query execution time in milliseconds
+	QueriedAt          time.Time   `json:"queried_at,omitempty"`                         // When the query to this server started (UTC); kept from the original query when cached
+	Tags               []string    `json:"tags,omitempty" example:"GOOGLE,PRIMARY"`      // Server tags
+	RCode              string      `json:"rcode,omitempty" example:"NOERROR"`            // DNS response code
+	Name               string      `json:"name,omitempty" example:"example.com."`        // Queried name
+	QType              string      `json:"qtype,omitempty" example:"A"`                  // Query type
+	Answers            []DNSAnswer `json:"answers,omitempty"`                            // DNS answers
+	CNAMEChain         []string    `json:"cname_chain,omitempty"`                        // CNAME targets followed from the queried name, in order
+	AssertionPassed    *bool       `json:"assertion_passed,omitempty"`                   // Whether answers matched the request's expected values (unset without expectations)
+	SuspiciousResponse bool        `json:"suspicious_response,omitempty"`                // Reply ID or question section did not match the query
+	SuspiciousReason   string      `json:"suspicious_reason,omitempty"`                  // Why the reply was flagged as suspicious
+	EDNSUDPSize        uint16      `json:"edns_udp_size,omitempty" example:"1232"`       // UDP payload size advertised in the reply's OPT record
+	EDNSPadded         *bool       `json:"edns_padded,omitempty"`                        // Whether the reply carried EDNS0 padding (unset unless edns_padding was requested)
+	CookieSupported    *bool       `json:"cookie_supported,omitempty"`                   // Whether the reply echoed our client cookie with a valid server cookie (unset unless dns_cookie was requested)
+	CookieEchoed       *bool       `json:"cookie_echoed,omitempty"`                      // Whether the reply echoed our client cookie, with or without a server cookie (unset unless dns_cookie was requested)
+	ForwardConfirmed   *bool       `json:"forward_confirmed,omitempty"`                  // Whether a PTR name resolves back to the queried IP (unset unless forward_confirm was requested)
+	HTTPVersion        string      `json:"http_version,omitempty" example:"h2"`          // HTTP version negotiated with DoH targets (http/1.1, h2, h3)
+	TLSVersion         string      `json:"tls_version,omitempty" example:"TLS 1.3"`      // TLS version negotiated with DoT/DoH/DoQ targets
+	TLSCipher          string      `json:"tls_cipher,omitempty"`                         // TLS cipher suite negotiated with DoT/DoH/DoQ targets
+	IPFamily           string      `json:"ip_family,omitempty" example:"ipv6"`           // Address family the query was sent over (ipv4 or ipv6; unset behind a proxy)
+	Error              string      `json:"error,omitempty" example:"connection timeout"` // Error message if query failed
+	ErrorCategory      string      `json:"error_category,omitempty" example:"timeout"`   // Failure class: timeout, connection_refused, tls_handshake, dns_error, no_route or query_failed
+	DNSProtocol        string      `json:"dns_protocol,omitempty" example:"udp"`         // Protocol used (udp, tcp, tls, https, quic)
+	Cached             bool        `json:"cached,omitempty"`                             // Served from the worker's result cache; time_ms is from the original query
 }
 
 // DNSLookupResults aggregates results from multiple servers
@@ -94,19 +329,86 @@ type DNSLookupResult struct {
 type DNSLookupResults struct {
 	Details  map[string]DNSLookupResult `json:"details"`                  // Results per DNS server (keyed by target)
 	Duration float64                    `json:"duration" example:"0.125"` // Total query duration in seconds
+	ByTag    map[string]TagSummary      `json:"by_tag,omitempty"`         // Rollup per server tag (only with ?group_by=tag)
+}
+
+// TagSummary aggregates the results of every server carrying a tag
+// @Description Success count and average latency of the servers sharing a tag
+type TagSummary struct {
+	Servers    int     `json:"servers" example:"3"`                  // Servers carrying the tag
+	Successful int     `json:"successful" example:"2"`               // Servers that answered (command_status ok)
+	AvgTimeMs  float64 `json:"avg_time_ms,omitempty" example:"21.5"` // Mean time_ms of the successful servers
+}
+
+// GroupByTag rolls Details up per tag. A server counts towards each of its tags;
+// untagged servers are left out.
+func (r *DNSLookupResults) GroupByTag() map[string]TagSummary {
+	groups := make(map[string]TagSummary)
+	totals := make(map[string]float64)
+	for _, result := range r.Details {
+		for _, tag := range result.Tags {
+			g := groups[tag]
+			g.Servers++
+			if result.CommandStatus == "ok" {
+				g.Successful++
+				totals[tag] += result.TimeMs
+			}
+			groups[tag] = g
+		}
+	}
+	for tag, g := range groups {
+		if g.Successful > 0 {
+			g.AvgTimeMs = totals[tag] / float64(g.Successful)
+			groups[tag] = g
+		}
+	}
+	return groups
+}
+
+// Task statuses, the only values every tasks client reports in task_status.
+const (
+	TaskStatusPending = "PENDING" // Queued or scheduled, not picked up yet
+	TaskStatusActive  = "ACTIVE"  // Being processed by a worker
+	TaskStatusRetry   = "RETRY"   // Failed and waiting for another attempt
+	TaskStatusSuccess = "SUCCESS" // Finished; the result is available
+	TaskStatusFailure = "FAILURE" // Failed for good; error says why
+)
+
+// TaskStatuses lists the task statuses in lifecycle order.
+var TaskStatuses = []string{TaskStatusPending, TaskStatusActive, TaskStatusRetry, TaskStatusSuccess, TaskStatusFailure}
+
+// IsFinalTaskStatus reports whether a task in status will not change anymore.
+func IsFinalTaskStatus(status string) bool {
+	return status == TaskStatusSuccess || status == TaskStatusFailure
 }
 
 // TaskStatusResponse represents task status and optional result
 // @Description Task status response with result when completed
 type TaskStatusResponse struct {
 	TaskID      string            `json:"task_id" example:"abc123def456789"`        // Task identifier
-	Status      string            `json:"task_status" example:"SUCCESS"`            // Task status (PENDING, ACTIVE, SUCCESS, FAILURE)
+	Status      string            `json:"task_status" example:"SUCCESS"`            // Task status (PENDING, ACTIVE, RETRY, SUCCESS, FAILURE)
 	Result      *DNSLookupResults `json:"task_result,omitempty"`                    // Query results (populated when status is SUCCESS)
 	Error       *string           `json:"error,omitempty" example:"worker timeout"` // Error message (populated when status is FAILURE)
 	CreatedAt   time.Time         `json:"created_at,omitempty"`                     // Task creation timestamp
 	CompletedAt time.Time         `json:"completed_at,omitempty"`                   // Task completion timestamp
 }
 
+// TaskSummary is one entry of the task list
+// @Description Task identifier and status without results
+type TaskSummary struct {
+	TaskID      string    `json:"task_id" example:"abc123def456789"` // Task identifier
+	Status      string    `json:"task_status" example:"PENDING"`     // Task status (PENDING, ACTIVE, RETRY, SUCCESS, FAILURE)
+	CreatedAt   time.Time `json:"created_at,omitempty"`              // Task creation (or next processing) timestamp
+	CompletedAt time.Time `json:"completed_at,omitempty"`            // Task completion timestamp
+}
+
+// TaskListResponse is a page of recent tasks
+// @Description Paginated list of recent tasks, newest first
+type TaskListResponse struct {
+	Tasks      []TaskSummary `json:"tasks"`                              // Tasks on this page
+	NextCursor string        `json:"next_cursor,omitempty" example:"50"` // Cursor for the next page (empty on the last page)
+}
+
 // HealthResponse indicates API health status
 // @Description Health check response
 type HealthResponse struct {
@@ -114,16 +416,92 @@ type HealthResponse struct {
 	Warning string `json:"warning,omitempty" example:"no active workers detected"` // Warning message if degraded
 }
 
+// ServersResponse lists configured DNS servers and the protocols they support
+// @Description Configured DNS servers with services and normalized targets grouped by protocol
+type ServersResponse struct {
+	Servers []ServerCapabilities `json:"servers"` // Configured servers
+}
+
+// ServerCapabilities describes the services a configured server supports
+// @Description A configured server with its services and normalized targets grouped by protocol (Do53, DoT, DoH, DoQ)
+type ServerCapabilities struct {
+	IP       string              `json:"ip,omitempty" example:"9.9.9.9"`             // Server IP address
+	Hostname string              `json:"hostname,omitempty" example:"dns.quad9.net"` // Server hostname
+	Services []string            `json:"services"`                                   // Configured services, e.g. do53/udp, dot, doh
+	Targets  map[string][]string `json:"targets"`                                    // Normalized targets per protocol display name
+	Tags     []string            `json:"tags,omitempty"`                             // Labels from the config
+}
+
 // ErrorResponse represents an API error response
 // @Description Error response returned for failed requests
 type ErrorResponse struct {
-	Error string `json:"error" example:"rate limit exceeded"` // Error message
+	Error     string `json:"error" example:"rate limit exceeded"`                   // Error message
+	Code      string `json:"code" example:"rate_limited"`                           // Machine-readable error code, one of the ErrCode constants
+	RequestID string `json:"request_id,omitempty" example:"host/AbCdEf1234-000001"` // Correlation ID, also sent as X-Request-ID
+}
+
+// Error codes carried by ErrorResponse, stable for clients to branch on.
+const (
+	ErrCodeInvalidRequest = "invalid_request"  // Malformed body or query parameters
+	ErrCodeBodyTooLarge   = "body_too_large"   // Body above server.max_body_bytes
+	ErrCodeInvalidDomain  = "invalid_domain"   // Domain is not a valid DNS name
+	ErrCodeInvalidQType   = "invalid_qtype"    // Unknown or disallowed query type
+	ErrCodeInvalidOptions = "invalid_options"  // Invalid query or task options
+	ErrCodeInvalidTarget  = "invalid_target"   // A dns_servers target cannot be parsed
+	ErrCodeInvalidIP      = "invalid_ip"       // reverse_ip is not an IP address
+	ErrCodeInvalidCIDR    = "invalid_cidr"     // cidr cannot be parsed
+	ErrCodeRangeTooLarge  = "range_too_large"  // cidr above dns.max_reverse_range addresses
+	ErrCodeUnknownQueue   = "unknown_queue"    // X-Queue not listed in worker.queues
+	ErrCodeNoServers      = "no_servers"       // No dns_servers in the request or the config
+	ErrCodeTooManyServers = "too_many_servers" // More servers than max_servers_per_request
+	ErrCodeUnauthorized   = "unauthorized"     // Missing or invalid API key
+	ErrCodeForbidden      = "forbidden"        // Endpoint disabled by the configuration
+	ErrCodeTaskNotFound   = "task_not_found"   // Unknown or expired task ID
+	ErrCodeRateLimited    = "rate_limited"     // Rate limit exceeded
+	ErrCodeNoWorkers      = "no_workers"       // No worker is consuming the queue
+	ErrCodeInternalError  = "internal_error"   // Unexpected server-side failure
+)
+
+// ValidationError is a request validation failure tagged with its ErrorResponse code.
+type ValidationError struct {
+	Code string
+	Err  error
 }
 
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
 // ReverseLookupRequest represents a reverse DNS lookup request
 // @Description Reverse DNS lookup request for an IP address
 type ReverseLookupRequest struct {
-	ReverseIP             string      `json:"reverse_ip" binding:"required" example:"8.8.8.8"`    // IP address to reverse lookup
-	DNSServers            []DNSServer `json:"dns_servers,omitempty"`                              // DNS servers to query (optional)
-	TLSInsecureSkipVerify bool        `json:"tls_insecure_skip_verify,omitempty" example:"false"` // Skip TLS certificate verification
+	ReverseIP  string      `json:"reverse_ip" binding:"required" example:"8.8.8.8"` // IP address to reverse lookup
+	DNSServers []DNSServer `json:"dns_servers,omitempty"`                           // DNS servers to query (optional)
+	QueryOptions
+	TaskOptions
+}
+
+// ReverseRangeRequest represents a reverse DNS lookup of a CIDR range
+// @Description Reverse DNS lookup of every address in a CIDR range, one task per address
+type ReverseRangeRequest struct {
+	CIDR       string      `json:"cidr" binding:"required" example:"192.0.2.0/28"` // Range to look up (at most dns.max_reverse_range addresses)
+	DNSServers []DNSServer `json:"dns_servers,omitempty"`                          // DNS servers to query (optional)
+	QueryOptions
+	CallbackURL string `json:"callback_url,omitempty" example:"https://hooks.example.com/dns"` // URL receiving each completed task status as a POST
+}
+
+// ReverseRangeTask is the task enqueued for one address of a range
+// @Description Address and the task looking up its PTR record
+type ReverseRangeTask struct {
+	IP     string `json:"ip" example:"192.0.2.1"`            // Address
+	TaskID string `json:"task_id" example:"abc123def456789"` // Task identifier for polling
+}
+
+// ReverseRangeResponse lists the tasks enqueued for a range
+// @Description Tasks enqueued for a CIDR range, in address order. When enqueuing stops partway, error and code say why and tasks lists the ones that were enqueued.
+type ReverseRangeResponse struct {
+	Tasks   []ReverseRangeTask `json:"tasks"`                                             // One task per address
+	Message string             `json:"message" example:"16 reverse DNS lookups enqueued"` // Status message
+	Error   string             `json:"error,omitempty"`                                   // Why enqueuing stopped partway
+	Code    string             `json:"code,omitempty"`                                    // Machine-readable code of Error, one of the ErrCode constants
 }
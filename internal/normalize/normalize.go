@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/miekg/dns"
@@ -64,10 +65,19 @@ func Target(raw string) (string, error) {
 		return "", fmt.Errorf("target contains invalid control characters")
 	}
 
+	// Stamps stay verbatim so the resolver can enforce their certificate pins
+	if IsStamp(raw) {
+		if _, err := ParseStamp(raw); err != nil {
+			return "", err
+		}
+		return raw, nil
+	}
+
 	// Default to UDP for bare addresses
 	if !strings.Contains(raw, "://") {
 		raw = "udp://" + raw
 	}
+	raw = bracketIPv6(raw)
 
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -79,7 +89,7 @@ func Target(raw string) (string, error) {
 	switch scheme {
 	case SchemeUDP, SchemeTCP, SchemeTLS, SchemeHTTPS, SchemeQUIC:
 	default:
-		return "", fmt.Errorf("unsupported scheme '%s' (must be udp, tcp, tls, https, or quic)", scheme)
+		return "", fmt.Errorf("unsupported scheme '%s' (must be udp, tcp, tls, https, quic, or sdns)", scheme)
 	}
 
 	host := u.Hostname()
@@ -97,13 +107,42 @@ func Target(raw string) (string, error) {
 		raw += "/dns-query"
 	}
 
-	// Let AdGuard dnsproxy handle ports, IPv6 brackets, host parsing
+	// Let AdGuard dnsproxy handle ports and host parsing
 	return raw, nil
 }
 
+// bracketIPv6 wraps a bare IPv6 host in brackets so its colons are not read as a port.
+// An unbracketed host is taken as a whole address: udp://2001:db8::1:53 has no port.
+func bracketIPv6(raw string) string {
+	scheme, rest, _ := strings.Cut(raw, "://")
+	host, path := rest, ""
+	if i := strings.IndexAny(rest, "/?#"); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if !strings.Contains(host, ":") || net.ParseIP(host) == nil {
+		return raw
+	}
+	return scheme + "://[" + host + "]" + path
+}
+
+// JoinHostPort builds host:port, bracketing IPv6 literals (already bracketed ones are accepted).
+func JoinHostPort(host string, port int) string {
+	return net.JoinHostPort(trimIP(host), strconv.Itoa(port))
+}
+
 // IsValidIP delegates to net.ParseIP for RFC compliance.
+// Accepts IPv4, IPv6 and bracketed IPv6 literals ([2001:db8::1]).
 func IsValidIP(s string) bool {
-	return net.ParseIP(s) != nil
+	return net.ParseIP(trimIP(s)) != nil
+}
+
+// trimIP strips whitespace and the brackets around an IPv6 literal.
+func trimIP(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		s = s[1 : len(s)-1]
+	}
+	return s
 }
 
 // IsValidDomain delegates RFC 1035 validation to miekg/dns.
@@ -135,6 +174,9 @@ func Domain(domain string) (string, error) {
 	return normalized, nil
 }
 
+// DefaultAllowedQTypes are the query types accepted when no allowlist is configured.
+var DefaultAllowedQTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT", "NS", "SOA", "SRV", "CAA", "PTR"}
+
 // IsValidQType uses miekg/dns type map to avoid maintaining our own list.
 func IsValidQType(qtype string) bool {
 	_, ok := dns.StringToType[strings.ToUpper(qtype)]
@@ -155,8 +197,44 @@ func QType(qtype string) (string, error) {
 	return normalized, nil
 }
 
+// IsZoneTransfer reports AXFR and IXFR, which need a multi-message transfer the resolver cannot read.
+func IsZoneTransfer(qtype string) bool {
+	switch strings.ToUpper(qtype) {
+	case "AXFR", "IXFR":
+		return true
+	}
+	return false
+}
+
+// AllowedQType normalizes qtype like QType and checks it against allowed,
+// falling back to DefaultAllowedQTypes when allowed is empty.
+func AllowedQType(qtype string, allowed []string) (string, error) {
+	if len(allowed) == 0 {
+		allowed = DefaultAllowedQTypes
+	}
+
+	normalized, err := QType(qtype)
+	if IsZoneTransfer(normalized) {
+		return "", fmt.Errorf("%s: zone transfers are not supported", normalized)
+	}
+	if err == nil {
+		for _, a := range allowed {
+			if strings.EqualFold(a, normalized) {
+				return normalized, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("%s is not supported (allowed: %s)", qtype, strings.Join(allowed, ", "))
+}
+
 // IPToReverseDNS delegates reverse DNS formatting to dns.ReverseAddr.
+// IPv6 addresses are fully expanded into 32 reversed nibbles under ip6.arpa.
 func IPToReverseDNS(ip string) (string, error) {
+	ip = trimIP(ip)
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("invalid IP address: %s", ip)
+	}
 	rev, err := dns.ReverseAddr(ip)
 	if err != nil {
 		return "", err
@@ -164,3 +242,33 @@ func IPToReverseDNS(ip string) (string, error) {
 	// Trim trailing dot from dns.ReverseAddr output
 	return strings.TrimSuffix(rev, "."), nil
 }
+
+// ReverseDNSToIP is the inverse of IPToReverseDNS: it parses an in-addr.arpa name with four
+// labels or an ip6.arpa name with 32 nibbles back into the address.
+func ReverseDNSToIP(name string) (net.IP, error) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	var ip net.IP
+	switch {
+	case strings.HasSuffix(name, ".in-addr.arpa"):
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) == 4 {
+			ip = net.ParseIP(labels[3] + "." + labels[2] + "." + labels[1] + "." + labels[0]).To4()
+		}
+	case strings.HasSuffix(name, ".ip6.arpa"):
+		nibbles := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+		if len(nibbles) == 32 {
+			var b strings.Builder
+			for i := 31; i >= 0; i-- {
+				b.WriteString(nibbles[i])
+				if i%4 == 0 && i > 0 {
+					b.WriteByte(':')
+				}
+			}
+			ip = net.ParseIP(b.String())
+		}
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("not a reverse DNS name: %s", name)
+	}
+	return ip, nil
+}
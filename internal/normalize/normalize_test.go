@@ -1,6 +1,11 @@
 package normalize
 
-import "testing"
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestTarget(t *testing.T) {
 	tests := []struct {
@@ -9,13 +14,16 @@ func TestTarget(t *testing.T) {
 		want string
 		ok   bool
 	}{
-		// AdGuard dnsproxy handles port and IPv6 bracketing automatically
+		// AdGuard dnsproxy handles ports; bare IPv6 literals get bracketed
 		{"plain IPv4", "8.8.8.8", "udp://8.8.8.8", true},
 		{"udp explicit", "udp://9.9.9.9:53", "udp://9.9.9.9:53", true},
 		{"https default path", "https://dns.google", "https://dns.google/dns-query", true},
 		{"https with path", "https://dns.google/dns-query", "https://dns.google/dns-query", true},
+		{"https custom path", "https://dns.nextdns.io/abc123", "https://dns.nextdns.io/abc123", true},
 		{"quic default port", "quic://dns.adguard.com", "quic://dns.adguard.com", true},
-		{"ipv6 plain", "2001:4860:4860::8888", "udp://2001:4860:4860::8888", true},
+		{"ipv6 plain", "2001:4860:4860::8888", "udp://[2001:4860:4860::8888]", true},
+		{"ipv6 with scheme", "tls://2001:db8::1", "tls://[2001:db8::1]", true},
+		{"ipv6 https path", "https://2001:db8::1/dns-query", "https://[2001:db8::1]/dns-query", true},
 		{"ipv6 with brackets and port", "[2001:4860:4860::8888]:853", "udp://[2001:4860:4860::8888]:853", true},
 		{"unsupported scheme", "ftp://example.com", "", false},
 		{"empty input", "", "", false},
@@ -38,3 +46,128 @@ func TestTarget(t *testing.T) {
 		})
 	}
 }
+
+func TestJoinHostPort(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"9.9.9.9", "9.9.9.9:53"},
+		{"2001:db8::1", "[2001:db8::1]:53"},
+		{"[2001:db8::1]", "[2001:db8::1]:53"},
+		{"dns.quad9.net", "dns.quad9.net:53"},
+	}
+
+	for _, tt := range tests {
+		if got := JoinHostPort(tt.host, 53); got != tt.want {
+			t.Errorf("JoinHostPort(%q) = %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestIPToReverseDNS(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+		ok   bool
+	}{
+		{"ipv4", "8.8.4.4", "4.4.8.8.in-addr.arpa", true},
+		{"ipv6 compressed", "2001:4860:4860::8888", "8.8.8.8.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.6.8.4.0.6.8.4.1.0.0.2.ip6.arpa", true},
+		{"ipv6 full", "2001:0db8:0000:0000:0000:0000:0000:0001", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", true},
+		{"ipv6 bracketed", "[2001:db8::1]", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", true},
+		{"not an ip", "example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidIP(tt.in); got != tt.ok {
+				t.Fatalf("IsValidIP(%q) = %v, want %v", tt.in, got, tt.ok)
+			}
+			got, err := IPToReverseDNS(tt.in)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("expected error, got none")
+			}
+			if tt.ok && got != tt.want {
+				t.Fatalf("got %q want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseDNSToIP(t *testing.T) {
+	for _, ip := range []string{"8.8.4.4", "2001:4860:4860::8888", "2001:db8::1"} {
+		rev, err := IPToReverseDNS(ip)
+		if err != nil {
+			t.Fatalf("IPToReverseDNS(%q): %v", ip, err)
+		}
+		got, err := ReverseDNSToIP(rev + ".")
+		if err != nil || !got.Equal(net.ParseIP(ip)) {
+			t.Errorf("ReverseDNSToIP(%q) = %v, %v; want %s", rev, got, err, ip)
+		}
+	}
+
+	for _, name := range []string{"example.com", "4.8.8.in-addr.arpa", "x.4.8.8.in-addr.arpa", "1.0.ip6.arpa", "1.2.3.4.5.in-addr.arpa"} {
+		if _, err := ReverseDNSToIP(name); err == nil {
+			t.Errorf("ReverseDNSToIP(%q): expected an error", name)
+		}
+	}
+}
+
+func TestParseStamp(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		hashes []string
+		ok     bool
+	}{
+		{
+			name: "doh stamp",
+			in:   "sdns://AgEAAAAAAAAABzguOC44LjgACmRucy5nb29nbGUKL2Rucy1xdWVyeQ",
+			want: "https://dns.google/dns-query",
+			ok:   true,
+		},
+		{
+			name:   "dot stamp with pinned hash",
+			in:     "sdns://AwEAAAAAAAAABzEuMS4xLjEgb7fXe5h9VZcYPCu32PkyQgUcWbEyFw_du1OVV_OOUpoPb25lLm9uZS5vbmUub25l",
+			want:   "tls://one.one.one.one",
+			hashes: []string{"6fb7d77b987d5597183c2bb7d8f93242051c59b132170fddbb539557f38e529a"},
+			ok:     true,
+		},
+		{name: "not base64", in: "sdns://%%%", ok: false},
+		{name: "truncated", in: "sdns://AgEAAAAAAAAABzgu", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStamp(tt.in)
+			if !tt.ok {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				if !strings.Contains(err.Error(), "invalid DNS stamp") {
+					t.Errorf("expected descriptive error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.Target != tt.want {
+				t.Errorf("got target %q want %q", got.Target, tt.want)
+			}
+			if !reflect.DeepEqual(got.CertHashes, tt.hashes) {
+				t.Errorf("got hashes %v want %v", got.CertHashes, tt.hashes)
+			}
+
+			// Target accepts stamps and keeps them verbatim for the resolver
+			if norm, err := Target(tt.in); err != nil || norm != tt.in {
+				t.Errorf("Target(%q) = %q, %v", tt.in, norm, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package normalize
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// ParsePin decodes a pin_sha256 value: the base64 SHA-256 digest of a certificate's
+// SubjectPublicKeyInfo, as printed by `openssl x509 -pubkey | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64`.
+func ParsePin(pin string) ([]byte, error) {
+	sum, err := base64.StdEncoding.DecodeString(strings.TrimSpace(pin))
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid pin_sha256 '%s' (must be a base64 SHA-256 SPKI digest)", pin)
+	}
+	return sum, nil
+}
+
+// SupportsPins reports whether target runs over TLS (DoT, DoH, DoQ), the only protocols
+// where a certificate pin can be checked.
+func SupportsPins(target string) bool {
+	scheme, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(target)), "://")
+	return scheme == SchemeTLS || scheme == SchemeHTTPS || scheme == SchemeQUIC
+}
@@ -0,0 +1,73 @@
+package normalize
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ameshkov/dnsstamps"
+)
+
+// SchemeSDNS is the URI scheme of DNS Stamps (https://dnscrypt.info/stamps-specifications)
+const SchemeSDNS = "sdns"
+
+// Stamp is a decoded DNS Stamp.
+type Stamp struct {
+	// Target is the equivalent udp://, https://, tls:// or quic:// target
+	Target string
+	// CertHashes are hex SHA-256 digests of TBS certificates; one must appear in the server chain
+	CertHashes []string
+}
+
+// IsStamp reports whether raw is an sdns:// DNS Stamp.
+func IsStamp(raw string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(raw)), SchemeSDNS+"://")
+}
+
+// TargetScheme returns the lowercase scheme of target; stamps report the scheme they decode to,
+// or "" when they do not decode.
+func TargetScheme(target string) string {
+	if IsStamp(target) {
+		stamp, err := ParseStamp(target)
+		if err != nil {
+			return ""
+		}
+		target = stamp.Target
+	}
+	scheme, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(target)), "://")
+	return scheme
+}
+
+// ParseStamp decodes an sdns:// URI with ameshkov/dnsstamps, the parser AdGuard dnsproxy uses.
+// DNSCrypt stamps are rejected - only plain, DoH, DoT and DoQ map to supported targets.
+func ParseStamp(raw string) (Stamp, error) {
+	st, err := dnsstamps.NewServerStampFromString(strings.TrimSpace(raw))
+	if err != nil {
+		return Stamp{}, fmt.Errorf("invalid DNS stamp: %w", err)
+	}
+
+	var target string
+	switch st.Proto {
+	case dnsstamps.StampProtoTypePlain:
+		target = SchemeUDP + "://" + st.ServerAddrStr
+	case dnsstamps.StampProtoTypeDoH:
+		target = SchemeHTTPS + "://" + st.ProviderName + st.Path
+	case dnsstamps.StampProtoTypeTLS:
+		target = SchemeTLS + "://" + st.ProviderName
+	case dnsstamps.StampProtoTypeDoQ:
+		target = SchemeQUIC + "://" + st.ProviderName
+	default:
+		return Stamp{}, fmt.Errorf("unsupported DNS stamp protocol 0x%02x (must be plain, DoH, DoT or DoQ)", uint8(st.Proto))
+	}
+
+	norm, err := Target(target)
+	if err != nil {
+		return Stamp{}, fmt.Errorf("invalid DNS stamp: %w", err)
+	}
+
+	stamp := Stamp{Target: norm}
+	for _, h := range st.Hashes {
+		stamp.CertHashes = append(stamp.CertHashes, hex.EncodeToString(h))
+	}
+	return stamp, nil
+}
@@ -0,0 +1,94 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"net/netip"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+)
+
+// bootstrapResolver resolves the hostnames of encrypted targets; nil means the system resolver.
+// Set once at startup by SetBootstrap, read-only afterwards.
+var (
+	bootstrapResolver  upstream.Resolver
+	bootstrapUpstreams []*upstream.UpstreamResolver
+)
+
+// SetBootstrap makes hostname-based DoT/DoH/DoQ targets resolve through the given plain DNS
+// servers (IP addresses, optionally with scheme and port) instead of the system resolver.
+// Call it at startup before any lookup; an empty list keeps the system resolver.
+func SetBootstrap(addrs []string, timeout time.Duration) error {
+	closeBootstrap()
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	resolvers := make(upstream.ParallelResolver, 0, len(addrs))
+	for _, addr := range addrs {
+		r, err := upstream.NewUpstreamResolver(addr, &upstream.Options{Timeout: timeout})
+		if err != nil {
+			if r != nil {
+				_ = r.Close()
+			}
+			closeBootstrap()
+			return fmt.Errorf("bootstrap resolver '%s': %w", addr, err)
+		}
+		bootstrapUpstreams = append(bootstrapUpstreams, r)
+		resolvers = append(resolvers, r)
+	}
+	bootstrapResolver = resolvers
+	return nil
+}
+
+// closeBootstrap releases the current bootstrap upstreams and falls back to the system resolver.
+func closeBootstrap() {
+	for _, r := range bootstrapUpstreams {
+		_ = r.Close()
+	}
+	bootstrapResolver, bootstrapUpstreams = nil, nil
+}
+
+// bootstrapDialContext dials through boot so the DoH client resolves hosts like dnsproxy does.
+// The lookup is reported to the request's httptrace, which only sees the system resolver itself.
+func bootstrapDialContext(boot upstream.Resolver) dialFunc {
+	dialer := &net.Dialer{}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := netip.ParseAddr(host); err == nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		trace := httptrace.ContextClientTrace(ctx)
+		if trace != nil && trace.DNSStart != nil {
+			trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+		}
+		ips, err := boot.LookupNetIP(ctx, "ip", host)
+		if trace != nil && trace.DNSDone != nil {
+			trace.DNSDone(httptrace.DNSDoneInfo{Err: err})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bootstrap lookup of %s: %w", host, err)
+		}
+		if len(ips) == 0 {
+			return nil, fmt.Errorf("bootstrap lookup of %s returned no addresses", host)
+		}
+
+		var errs []error
+		for _, ip := range ips {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			errs = append(errs, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+}
@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// cacheSweepSize is the entry count above which storing a result first drops expired entries.
+// A cache still that full after the sweep stores nothing until entries expire.
+const cacheSweepSize = 10000
+
+// resultCache reuses successful query results for the smallest answer TTL, capped at maxTTL.
+// Set once at startup by SetCache; nil disables caching.
+var resultCache *queryCache
+
+type queryCache struct {
+	mu      sync.Mutex
+	maxTTL  time.Duration
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	result  models.DNSLookupResult
+	stored  time.Time
+	expires time.Time
+}
+
+// SetCache enables the query result cache with entries living at most maxTTL.
+// Call it at startup before any lookup; zero disables the cache.
+func SetCache(maxTTL time.Duration) {
+	if maxTTL <= 0 {
+		resultCache = nil
+		return
+	}
+	resultCache = &queryCache{maxTTL: maxTTL, entries: make(map[string]cacheEntry)}
+}
+
+// cacheKey identifies a query by everything that can change its result: target, pins,
+// name, type and every query option. The no_cache flag itself is left out.
+func cacheKey(domain, qtype string, server models.DNSServer, opts models.QueryOptions) string {
+	opts.NoCache = false
+	encoded, _ := json.Marshal(struct {
+		Target string
+		Pins   []string
+		Domain string
+		QType  string
+		Opts   models.QueryOptions
+	}{server.Target, server.PinSHA256, strings.ToLower(dns.Fqdn(domain)), strings.ToUpper(qtype), opts})
+	return string(encoded)
+}
+
+// get returns a cached result with its answer TTLs reduced by the time spent in the cache.
+func (c *queryCache) get(key string) (models.DNSLookupResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return models.DNSLookupResult{}, false
+	}
+	now := queryClock.Now()
+	if !now.Before(entry.expires) {
+		delete(c.entries, key)
+		return models.DNSLookupResult{}, false
+	}
+
+	result := entry.result
+	age := uint32(now.Sub(entry.stored) / time.Second)
+	result.Answers = make([]models.DNSAnswer, len(entry.result.Answers))
+	for i, answer := range entry.result.Answers {
+		answer.TTL -= min(age, answer.TTL)
+		result.Answers[i] = answer
+	}
+	result.Cached = true
+	return result, true
+}
+
+// put stores a successful result for its smallest answer TTL. Failures and empty answers are not
+// cached: their lifetime would come from the SOA, which the result does not carry.
+func (c *queryCache) put(key string, result models.DNSLookupResult) {
+	if result.CommandStatus != CommandStatusOK || len(result.Answers) == 0 {
+		return
+	}
+	ttl := c.maxTTL
+	for _, answer := range result.Answers {
+		ttl = min(ttl, time.Duration(answer.TTL)*time.Second)
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := queryClock.Now()
+	if len(c.entries) >= cacheSweepSize {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= cacheSweepSize {
+			return
+		}
+	}
+	c.entries[key] = cacheEntry{result: result, stored: now, expires: now.Add(ttl)}
+}
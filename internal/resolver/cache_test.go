@@ -0,0 +1,117 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// startCountingServer answers A queries with a 60s record and counts the queries it receives.
+func startCountingServer(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	var queries atomic.Int32
+	target := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		queries.Add(1)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.10"),
+		}}
+		_ = w.WriteMsg(m)
+	})
+	return target, &queries
+}
+
+// useCache enables the cache under a fake query clock for one test.
+func useCache(t *testing.T, maxTTL time.Duration) *fakeClock {
+	t.Helper()
+	clock := &fakeClock{now: time.Now()}
+	SetCache(maxTTL)
+	queryClock = clock
+	t.Cleanup(func() {
+		SetCache(0)
+		queryClock = realClock{}
+	})
+	return clock
+}
+
+func TestQueryServer_CacheHit(t *testing.T) {
+	clock := useCache(t, time.Hour)
+	target, queries := startCountingServer(t)
+	server := models.DNSServer{Target: target, Tags: []string{"FIRST"}}
+
+	_, first := QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	if first.CommandStatus != CommandStatusOK || first.Cached {
+		t.Fatalf("Expected an uncached ok result, got %+v", first)
+	}
+
+	clock.Advance(15 * time.Second)
+	server.Tags = []string{"SECOND"}
+	_, second := QueryServer(context.Background(), "EXAMPLE.com.", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	if got := queries.Load(); got != 1 {
+		t.Fatalf("Expected the second lookup to be served from cache, server saw %d queries", got)
+	}
+	if !second.Cached || len(second.Answers) != 1 || second.Answers[0].TTL != 45 {
+		t.Errorf("Expected a cached answer with TTL 45, got %+v", second)
+	}
+	if len(second.Tags) != 1 || second.Tags[0] != "SECOND" {
+		t.Errorf("Expected the current server tags, got %v", second.Tags)
+	}
+
+	// Options that change the query are part of the key, and no_cache bypasses the cache
+	for _, opts := range []models.QueryOptions{{TLSInsecureSkipVerify: true}, {CheckingDisabled: true}, {NoCache: true}} {
+		_, result := QueryServer(context.Background(), "example.com", "A", server, opts, 1, DefaultTimeout)
+		if result.Cached {
+			t.Errorf("Expected a fresh query with options %+v", opts)
+		}
+	}
+	if got := queries.Load(); got != 4 {
+		t.Errorf("Expected 4 upstream queries, got %d", got)
+	}
+}
+
+func TestQueryServer_CacheExpiry(t *testing.T) {
+	clock := useCache(t, time.Hour)
+	target, queries := startCountingServer(t)
+	server := models.DNSServer{Target: target}
+
+	QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	clock.Advance(61 * time.Second)
+	_, result := QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	if result.Cached || queries.Load() != 2 {
+		t.Errorf("Expected a new query once the answer TTL elapsed, got cached=%v after %d queries", result.Cached, queries.Load())
+	}
+
+	// cache_max_ttl caps the answer TTL
+	clock = useCache(t, 10*time.Second)
+	QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	clock.Advance(11 * time.Second)
+	_, result = QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{}, 1, DefaultTimeout)
+	if result.Cached || queries.Load() != 4 {
+		t.Errorf("Expected cache_max_ttl to expire the entry, got cached=%v after %d queries", result.Cached, queries.Load())
+	}
+}
+
+func TestQueryServer_CacheSkipsFailures(t *testing.T) {
+	useCache(t, time.Hour)
+	var queries atomic.Int32
+	target := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		queries.Add(1)
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNameError)
+		_ = w.WriteMsg(m)
+	})
+
+	for i := 0; i < 2; i++ {
+		QueryServer(context.Background(), "missing.example.com", "A", models.DNSServer{Target: target}, models.QueryOptions{}, 1, DefaultTimeout)
+	}
+	if got := queries.Load(); got != 2 {
+		t.Errorf("Expected answerless replies not to be cached, server saw %d queries", got)
+	}
+}
@@ -0,0 +1,185 @@
+package resolver
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
+	"golang.org/x/net/proxy"
+)
+
+// dialFunc opens a TCP connection, matching http.Transport.DialContext.
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// sourceDialContext returns a dialer whose connections originate from sourceIP,
+// picking a UDP or TCP local address to match the network dialled.
+func sourceDialContext(sourceIP net.IP, timeout time.Duration) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return sourceDialer(sourceIP, network, timeout).DialContext(ctx, network, addr)
+	}
+}
+
+// sourceDialer binds to sourceIP when set; net.Dialer needs a LocalAddr of the dialled network's type.
+func sourceDialer(sourceIP net.IP, network string, timeout time.Duration) *net.Dialer {
+	d := &net.Dialer{Timeout: timeout}
+	switch {
+	case sourceIP == nil:
+	case strings.HasPrefix(network, "udp"):
+		d.LocalAddr = &net.UDPAddr{IP: sourceIP}
+	default:
+		d.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+	return d
+}
+
+// checkSourceIP fails unless ip is assigned to this host, by binding a throwaway UDP socket to it.
+func checkSourceIP(ip net.IP) error {
+	pc, err := net.ListenPacket("udp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return fmt.Errorf("source_ip %s is not a local address: %w", ip, err)
+	}
+	return pc.Close()
+}
+
+// proxyDialContext returns a dialer tunnelling every connection through proxyURL, reaching the
+// proxy over forward. Hostnames are passed to the proxy unresolved, so they resolve from its egress point.
+func proxyDialContext(proxyURL string, forward *net.Dialer) (dialFunc, error) {
+	u, err := normalize.ParseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == normalize.ProxySchemeHTTP {
+		return httpConnectDialer(u, forward), nil
+	}
+
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+	socks, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+	if err != nil {
+		return nil, fmt.Errorf("proxy_url '%s': %w", u.Redacted(), err)
+	}
+	// x/net's SOCKS5 dialer always supports contexts
+	return socks.(proxy.ContextDialer).DialContext, nil
+}
+
+// httpConnectDialer opens a tunnel with an HTTP CONNECT request, sending Basic
+// Proxy-Authorization when the proxy URL carries credentials.
+func httpConnectDialer(u *url.URL, forward *net.Dialer) dialFunc {
+	return func(ctx context.Context, _, addr string) (net.Conn, error) {
+		conn, err := forward.DialContext(ctx, "tcp", u.Host)
+		if err != nil {
+			return nil, fmt.Errorf("dial proxy %s: %w", u.Host, err)
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if u.User != nil {
+			password, _ := u.User.Password()
+			creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+			req.Header.Set("Proxy-Authorization", "Basic "+creds)
+		}
+		if err := req.Write(conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT %s: %w", addr, err)
+		}
+
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, req)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT %s: %w", addr, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			_ = conn.Close()
+			return nil, fmt.Errorf("proxy CONNECT %s: %s", addr, resp.Status)
+		}
+
+		_ = conn.SetDeadline(time.Time{})
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+}
+
+// bufferedConn keeps any bytes the proxy sent right after its CONNECT reply.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// exchangeConn sends msg over a UDP (udp://), TCP (tcp://) or TLS (tls://) connection opened by dial,
+// honoring the TLS settings of the upstream options. Used when dnsproxy cannot dial as asked,
+// e.g. through a proxy or from a source address.
+func exchangeConn(ctx context.Context, msg *dns.Msg, target string, opts *upstream.Options, dial dialFunc, timeout time.Duration) (*dns.Msg, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), strconv.Itoa(normalize.ProtocolConfigs[u.Scheme].DefaultPort))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	network := "tcp"
+	if u.Scheme == normalize.SchemeUDP {
+		network = "udp"
+	}
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	if u.Scheme == normalize.SchemeTLS {
+		// #nosec G402 - InsecureSkipVerify is user-controlled for testing encrypted protocols
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:            u.Hostname(),
+			InsecureSkipVerify:    opts.InsecureSkipVerify,
+			VerifyPeerCertificate: opts.VerifyServerCertificate,
+			VerifyConnection:      opts.VerifyConnection,
+			MinVersion:            tls.VersionTLS12,
+		})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	// UDP replies may use the full EDNS size rather than the 512-byte default
+	dc := &dns.Conn{Conn: conn, UDPSize: dns.MaxMsgSize}
+	if err := dc.WriteMsg(msg); err != nil {
+		return nil, err
+	}
+	return dc.ReadMsg()
+}
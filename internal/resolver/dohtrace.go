@@ -0,0 +1,95 @@
+package resolver
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
+)
+
+// tracingTransport records the phases of each DoH request into metrics.DNSDoHPhaseDuration.
+// A phase is observed at most once per request, so parallel dials only count the first.
+type tracingTransport struct {
+	base   http.RoundTripper
+	target string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	var (
+		mu       sync.Mutex
+		started  = map[string]time.Time{}
+		observed = map[string]bool{}
+	)
+	begin := func(phase string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, ok := started[phase]; !ok {
+			started[phase] = time.Now()
+		}
+	}
+	observe := func(phase string, from time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		if observed[phase] || from.IsZero() {
+			return
+		}
+		observed[phase] = true
+		metrics.DNSDoHPhaseDuration.WithLabelValues(t.target, phase).Observe(time.Since(from).Seconds())
+	}
+	end := func(phase string) {
+		mu.Lock()
+		from := started[phase]
+		mu.Unlock()
+		observe(phase, from)
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { begin(metrics.DoHPhaseDNS) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				end(metrics.DoHPhaseDNS)
+			}
+		},
+		ConnectStart: func(_, _ string) { begin(metrics.DoHPhaseConnect) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				end(metrics.DoHPhaseConnect)
+			}
+		},
+		TLSHandshakeStart: func() { begin(metrics.DoHPhaseTLS) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				end(metrics.DoHPhaseTLS)
+			}
+		},
+		GotFirstResponseByte: func() { observe(metrics.DoHPhaseFirstByte, start) },
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, done: func() { observe(metrics.DoHPhaseTotal, start) }}
+	return resp, nil
+}
+
+// tracedBody reports the end of a request once its body is read to EOF.
+type tracedBody struct {
+	io.ReadCloser
+	done func()
+}
+
+// Read implements io.Reader.
+func (b *tracedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.done()
+	}
+	return n, err
+}
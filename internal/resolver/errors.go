@@ -0,0 +1,75 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/miekg/dns"
+)
+
+// Error categories reported in DNSLookupResult.ErrorCategory and as the metrics reason label.
+const (
+	// ErrorCategoryTimeout means the server did not answer in time
+	ErrorCategoryTimeout = "timeout"
+	// ErrorCategoryConnectionRefused means nothing listened on the target port
+	ErrorCategoryConnectionRefused = "connection_refused"
+	// ErrorCategoryTLSHandshake covers certificate, pin and protocol failures during the TLS handshake
+	ErrorCategoryTLSHandshake = "tls_handshake"
+	// ErrorCategoryDNS means the target hostname did not resolve or the reply was malformed
+	ErrorCategoryDNS = "dns_error"
+	// ErrorCategoryNoRoute means the network or host is unreachable from the worker
+	ErrorCategoryNoRoute = "no_route"
+	// ErrorCategoryQueryFailed is any other failure
+	ErrorCategoryQueryFailed = "query_failed"
+)
+
+// classifyError maps a failed query to an error category by inspecting the wrapped error.
+// TLS failures are checked first because handshake errors may also wrap a net.Error.
+func classifyError(err error) string {
+	var (
+		alertErr     tls.AlertError
+		recordErr    tls.RecordHeaderError
+		verifyErr    *tls.CertificateVerificationError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		dnsErr       *net.DNSError
+		msgErr       *dns.Error
+		netErr       net.Error
+	)
+	switch {
+	case errors.Is(err, errPinMismatch),
+		errors.As(err, &alertErr),
+		errors.As(err, &recordErr),
+		errors.As(err, &verifyErr),
+		errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return ErrorCategoryTLSHandshake
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorCategoryConnectionRefused
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return ErrorCategoryNoRoute
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return ErrorCategoryTimeout
+		}
+		return ErrorCategoryDNS
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, os.ErrDeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorCategoryTimeout
+	case errors.As(err, &msgErr):
+		return ErrorCategoryDNS
+	case strings.Contains(err.Error(), "tls: "):
+		// Most crypto/tls handshake errors are plain strings
+		return ErrorCategoryTLSHandshake
+	}
+	return ErrorCategoryQueryFailed
+}
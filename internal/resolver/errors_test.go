@@ -0,0 +1,67 @@
+package resolver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestClassifyError(t *testing.T) {
+	dial := func(errno syscall.Errno) error {
+		return fmt.Errorf("dialing 192.0.2.1 over tcp: %w", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", errno)})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"deadline", fmt.Errorf("exchanging: %w", context.DeadlineExceeded), ErrorCategoryTimeout},
+		{"i/o timeout", &net.OpError{Op: "read", Net: "udp", Err: os.ErrDeadlineExceeded}, ErrorCategoryTimeout},
+		{"refused", dial(syscall.ECONNREFUSED), ErrorCategoryConnectionRefused},
+		{"network unreachable", dial(syscall.ENETUNREACH), ErrorCategoryNoRoute},
+		{"host unreachable", errors.Join(dial(syscall.EHOSTUNREACH)), ErrorCategoryNoRoute},
+		{"unknown authority", &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}, ErrorCategoryTLSHandshake},
+		{"alert", fmt.Errorf("handshake: %w", tls.AlertError(40)), ErrorCategoryTLSHandshake},
+		{"pin mismatch", fmt.Errorf("DNS query failed: %w", errPinMismatch), ErrorCategoryTLSHandshake},
+		{"plain tls error", errors.New("tls: server selected unsupported protocol version 301"), ErrorCategoryTLSHandshake},
+		{"hostname not found", &net.DNSError{Err: "no such host", Name: "dns.invalid", IsNotFound: true}, ErrorCategoryDNS},
+		{"hostname lookup timeout", &net.DNSError{Err: "i/o timeout", Name: "dns.example", IsTimeout: true}, ErrorCategoryTimeout},
+		{"bad reply", fmt.Errorf("exchanging: %w", dns.ErrId), ErrorCategoryDNS},
+		{"other", errors.New("DoH server returned HTTP 502"), ErrorCategoryQueryFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryServer_ErrorCategory(t *testing.T) {
+	// Grab a free port and close it so the connection is refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	_, result := QueryServer(context.Background(), "example.com", "A", models.DNSServer{Target: "tcp://" + addr}, models.QueryOptions{}, 1, DefaultTimeout)
+	if result.CommandStatus != CommandStatusError {
+		t.Fatalf("Expected error status, got %s", result.CommandStatus)
+	}
+	if result.ErrorCategory != ErrorCategoryConnectionRefused {
+		t.Errorf("Expected %s, got %q (%s)", ErrorCategoryConnectionRefused, result.ErrorCategory, result.Error)
+	}
+}
@@ -0,0 +1,112 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"sync/atomic"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// familyResolver resolves target hostnames through base, keeping only the addresses of family
+// when set. It orders IPv4 first like dnsproxy, whose dialers try the addresses in turn, and
+// remembers the family of the first address it handed out.
+type familyResolver struct {
+	base   upstream.Resolver
+	family string
+	used   atomic.Pointer[string]
+}
+
+// LookupNetIP implements upstream.Resolver.
+func (r *familyResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	ips, err := r.base.LookupNetIP(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+	ips = slices.DeleteFunc(slices.Clone(ips), func(ip netip.Addr) bool {
+		return r.family != "" && ipFamilyOf(ip) != r.family
+	})
+	if len(ips) == 0 && r.family != "" {
+		return nil, fmt.Errorf("%s has no %s address", host, r.family)
+	}
+	slices.SortStableFunc(ips, func(a, b netip.Addr) int {
+		return compareFamily(ipFamilyOf(a), ipFamilyOf(b))
+	})
+	if len(ips) > 0 {
+		family := ipFamilyOf(ips[0])
+		r.used.Store(&family)
+	}
+	return ips, nil
+}
+
+// usedFamily returns the family of the first address dialled, or "" before any lookup.
+func (r *familyResolver) usedFamily() string {
+	if family := r.used.Load(); family != nil {
+		return *family
+	}
+	return ""
+}
+
+// ipFamilyOf returns models.IPFamilyIPv4 or models.IPFamilyIPv6; IPv4-mapped addresses are IPv4.
+func ipFamilyOf(ip netip.Addr) string {
+	if ip.Unmap().Is4() {
+		return models.IPFamilyIPv4
+	}
+	return models.IPFamilyIPv6
+}
+
+// compareFamily sorts IPv4 before IPv6.
+func compareFamily(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == models.IPFamilyIPv4:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// applyFamily constrains the address family address is dialled over to queryOpts.IPFamily and
+// returns a func reporting the family used once the query is done. IP literal targets must
+// already be of that family, source-bound queries use the family of the source address, and
+// hostnames are filtered through a familyResolver installed as the bootstrap.
+func applyFamily(address string, queryOpts models.QueryOptions, sourceIP net.IP, opts *upstream.Options) (func() string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	want := queryOpts.IPFamily
+
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		got := ipFamilyOf(ip)
+		if want != "" && got != want {
+			return nil, fmt.Errorf("target %s is an %s address but ip_family is %s", ip, got, want)
+		}
+		return func() string { return got }, nil
+	}
+
+	switch {
+	case queryOpts.ProxyURL != "":
+		// The proxy resolves the hostname, so the family it dials is unknown
+		return func() string { return "" }, nil
+	case sourceIP != nil:
+		// A dialer bound to sourceIP only reaches addresses of the same family
+		ip, _ := netip.AddrFromSlice(sourceIP)
+		got := ipFamilyOf(ip)
+		return func() string { return got }, nil
+	}
+
+	boot := opts.Bootstrap
+	if boot == nil {
+		boot = net.DefaultResolver
+	}
+	r := &familyResolver{base: boot, family: want}
+	opts.Bootstrap = r
+	return r.usedFamily, nil
+}
@@ -0,0 +1,98 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// stubResolver answers every hostname lookup with addrs and counts the lookups.
+type stubResolver struct {
+	addrs   []netip.Addr
+	lookups atomic.Int32
+}
+
+func (s *stubResolver) LookupNetIP(_ context.Context, _, _ string) ([]netip.Addr, error) {
+	s.lookups.Add(1)
+	return s.addrs, nil
+}
+
+func TestQueryServer_IPFamilyIPv6(t *testing.T) {
+	pc, err := net.ListenPacket("udp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	srv := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = srv.ActivateAndServe() }()
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	// The name resolves to both families; only the IPv6 loopback has a server behind it
+	stub := &stubResolver{addrs: []netip.Addr{netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")}}
+	orig := bootstrapResolver
+	bootstrapResolver = stub
+	t.Cleanup(func() { bootstrapResolver = orig })
+
+	_, port, _ := net.SplitHostPort(pc.LocalAddr().String())
+	server := models.DNSServer{Target: "udp://dual.example.test:" + port}
+	_, result := QueryServer(context.Background(), "example.com", "A", server, models.QueryOptions{IPFamily: models.IPFamilyIPv6}, 1, 2*time.Second)
+	if result.CommandStatus != CommandStatusOK {
+		t.Fatalf("Expected the query to reach the IPv6 server, got %+v", result)
+	}
+	if result.IPFamily != models.IPFamilyIPv6 {
+		t.Errorf("Expected ip_family ipv6, got %q", result.IPFamily)
+	}
+	if stub.lookups.Load() == 0 {
+		t.Error("Expected the target hostname to be resolved through the stub")
+	}
+}
+
+func TestFamilyResolver(t *testing.T) {
+	stub := &stubResolver{addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("192.0.2.1")}}
+
+	tests := []struct {
+		family string
+		want   []string
+	}{
+		{"", []string{"192.0.2.1", "2001:db8::1"}},
+		{models.IPFamilyIPv4, []string{"192.0.2.1"}},
+		{models.IPFamilyIPv6, []string{"2001:db8::1"}},
+	}
+	for _, tt := range tests {
+		r := &familyResolver{base: stub, family: tt.family}
+		ips, err := r.LookupNetIP(context.Background(), "ip", "dual.example.test")
+		if err != nil {
+			t.Fatalf("family %q: %v", tt.family, err)
+		}
+		if len(ips) != len(tt.want) {
+			t.Fatalf("family %q: expected %v, got %v", tt.family, tt.want, ips)
+		}
+		for i, ip := range ips {
+			if ip.String() != tt.want[i] {
+				t.Errorf("family %q: expected %v, got %v", tt.family, tt.want, ips)
+			}
+		}
+		if got := r.usedFamily(); got != ipFamilyOf(ips[0]) {
+			t.Errorf("family %q: expected used family %s, got %q", tt.family, ipFamilyOf(ips[0]), got)
+		}
+	}
+
+	r := &familyResolver{base: &stubResolver{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}}, family: models.IPFamilyIPv6}
+	if _, err := r.LookupNetIP(context.Background(), "ip", "v4only.example.test"); err == nil {
+		t.Error("Expected an error for an IPv4-only name with ip_family ipv6")
+	}
+
+	// IP literal targets must match the requested family
+	if _, err := applyFamily("udp://192.0.2.1", models.QueryOptions{IPFamily: models.IPFamilyIPv6}, nil, upstreamOptions(models.QueryOptions{}, DefaultTimeout)); err == nil {
+		t.Error("Expected an IPv4 target to be rejected with ip_family ipv6")
+	}
+}
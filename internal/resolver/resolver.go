@@ -3,12 +3,26 @@
 package resolver
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
+	"net/http"
 	"net/url"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/AdguardTeam/dnsproxy/upstream"
@@ -16,6 +30,10 @@ import (
 	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/normalize"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -23,11 +41,24 @@ const (
 	CommandStatusOK = "ok"
 	// CommandStatusError indicates a failed DNS query
 	CommandStatusError = "error"
+	// CommandStatusTimeout marks a server still unanswered when the task deadline expired
+	CommandStatusTimeout = "timeout"
 
 	// DefaultTimeout is the default timeout for DNS queries
 	DefaultTimeout = 5 * time.Second
 	// RetryDelay is the brief delay between retries
 	RetryDelay = 100 * time.Millisecond // Brief delay between retries to avoid hammering
+
+	// EDNSBufferSize is the UDP payload size advertised with edns_padding or dns_cookie (DNS Flag Day 2020)
+	EDNSBufferSize = 1232
+	// PaddingBlockSize is the block length padded queries are rounded up to (RFC 8467)
+	PaddingBlockSize = 128
+
+	// ClientCookieSize is the length of the client cookie sent with dns_cookie (RFC 7873)
+	ClientCookieSize = 8
+	// Server cookies are 8 to 32 bytes long (RFC 7873 section 4.2)
+	minServerCookieSize = 8
+	maxServerCookieSize = 32
 )
 
 // RCodeMapping uses miekg/dns constants for response codes.
@@ -40,8 +71,32 @@ var RCodeMapping = map[int]string{
 	dns.RcodeRefused:        "REFUSED",
 }
 
+// Clock is the time source for queries: timestamps, round-trip times and retry delays.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time        { return time.Now() }
+func (realClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// queryClock times queries, paces retries and ages cached results; tests replace it
+// to control time without sleeping.
+var queryClock Clock = realClock{}
+
 // GetDNSProtocolFromTarget extracts display name from normalize.ProtocolConfigs.
+// DNS stamps report the protocol of the target they decode to.
 func GetDNSProtocolFromTarget(target string) string {
+	if normalize.IsStamp(target) {
+		stamp, err := normalize.ParseStamp(target)
+		if err != nil {
+			return "Unknown"
+		}
+		target = stamp.Target
+	}
+
 	u, err := url.Parse(target)
 	if err != nil || u.Scheme == "" {
 		return "Unknown"
@@ -62,6 +117,17 @@ func stringToQType(qtype string) (uint16, error) {
 	return 0, fmt.Errorf("unsupported query type: %s", qtype)
 }
 
+// stringToQClass maps IN/CH/HS to miekg/dns classes; empty means IN.
+func stringToQClass(qclass string) (uint16, error) {
+	if qclass == "" {
+		return dns.ClassINET, nil
+	}
+	if dnsClass, ok := dns.StringToClass[strings.ToUpper(qclass)]; ok {
+		return dnsClass, nil
+	}
+	return 0, fmt.Errorf("unsupported query class: %s", qclass)
+}
+
 // qtypeToString uses miekg/dns reverse mapping.
 func qtypeToString(qtype uint16) string {
 	if s, ok := dns.TypeToString[qtype]; ok {
@@ -72,8 +138,32 @@ func qtypeToString(qtype uint16) string {
 
 // QueryServer performs DNS query via AdGuard dnsproxy with retry logic.
 // Retries 3 times with 100ms delay - pragmatic default for transient network issues.
-func QueryServer(ctx context.Context, domain, qtype string, server models.DNSServer, tlsInsecure bool, retries int, timeout time.Duration) (string, models.DNSLookupResult) {
+// Each call is traced as a "dns.query" span carrying target, protocol and outcome.
+func QueryServer(ctx context.Context, domain, qtype string, server models.DNSServer, opts models.QueryOptions, retries int, timeout time.Duration) (string, models.DNSLookupResult) {
+	ctx, span := tracing.Tracer().Start(ctx, "dns.query", trace.WithAttributes(
+		attribute.String("dns.target", server.Target),
+		attribute.String("dns.protocol", GetDNSProtocolFromTarget(server.Target)),
+		attribute.String("dns.qtype", qtype),
+	))
+	defer span.End()
+
+	target, result := queryServer(ctx, domain, qtype, server, opts, retries, timeout)
+	if result.CommandStatus == CommandStatusOK {
+		span.SetAttributes(attribute.String("dns.rcode", result.RCode))
+		// NOERROR with zero answers is how filtering resolvers usually strip records.
+		// Cache hits sent no query, so they stay out of the histogram.
+		if !result.Cached {
+			metrics.DNSAnswerCount.WithLabelValues(target, strings.ToUpper(qtype)).Observe(float64(len(result.Answers)))
+		}
+	} else {
+		span.SetStatus(codes.Error, result.Error)
+	}
+	return target, result
+}
+
+func queryServer(ctx context.Context, domain, qtype string, server models.DNSServer, opts models.QueryOptions, retries int, timeout time.Duration) (string, models.DNSLookupResult) {
 	result := models.DNSLookupResult{
+		QueriedAt:   queryClock.Now().UTC(),
 		Tags:        server.Tags,
 		DNSProtocol: GetDNSProtocolFromTarget(server.Target),
 	}
@@ -86,12 +176,77 @@ func QueryServer(ctx context.Context, domain, qtype string, server models.DNSSer
 		return server.Target, result
 	}
 
+	dnsClass, err := stringToQClass(opts.QClass)
+	if err != nil {
+		result.CommandStatus = CommandStatusError
+		result.Error = err.Error()
+		metrics.DNSLookupErrors.WithLabelValues(server.Target, "invalid_qclass").Inc()
+		return server.Target, result
+	}
+
+	var pins [][]byte
+	for _, pin := range server.PinSHA256 {
+		sum, err := normalize.ParsePin(pin)
+		if err != nil {
+			result.CommandStatus = CommandStatusError
+			result.Error = err.Error()
+			metrics.DNSLookupErrors.WithLabelValues(server.Target, "invalid_pin").Inc()
+			return server.Target, result
+		}
+		pins = append(pins, sum)
+	}
+
+	// A TCP proxy cannot carry Do53 over UDP or DoQ
+	if opts.ProxyURL != "" && !normalize.SupportsProxy(server.Target) {
+		result.CommandStatus = CommandStatusError
+		result.Error = "proxy_url only supports DoH, DoT and Do53 over TCP targets"
+		metrics.DNSLookupErrors.WithLabelValues(server.Target, "proxy_unsupported").Inc()
+		return server.Target, result
+	}
+
+	// dnsproxy dials DoQ itself, so it cannot be bound to a source address
+	if opts.SourceIP != "" {
+		err := checkSourceIP(net.ParseIP(opts.SourceIP))
+		if err == nil && normalize.TargetScheme(server.Target) == normalize.SchemeQUIC {
+			err = errors.New("source_ip is not supported for DoQ targets")
+		}
+		if err != nil {
+			result.CommandStatus = CommandStatusError
+			result.Error = err.Error()
+			metrics.DNSLookupErrors.WithLabelValues(server.Target, "invalid_source_ip").Inc()
+			return server.Target, result
+		}
+	}
+
+	// Repeated lookups within the answer TTL reuse the earlier result unless no_cache is set
+	cache := resultCache
+	var key string
+	if cache != nil && !opts.NoCache {
+		key = cacheKey(domain, qtype, server, opts)
+		if cached, ok := cache.get(key); ok {
+			cached.Tags = server.Tags
+			return server.Target, cached
+		}
+	}
+
 	msg := new(dns.Msg)
 	msg.SetQuestion(dns.Fqdn(domain), dnsType)
-	msg.RecursionDesired = true
+	msg.Question[0].Qclass = dnsClass
+	msg.RecursionDesired = opts.WantRecursion()
+	msg.CheckingDisabled = opts.CheckingDisabled
+	msg.AuthenticatedData = opts.AuthenticatedData
+	var clientCookie string
+	if opts.DNSCookie {
+		clientCookie = addCookie(msg)
+	}
+	// Padding goes last so the block length includes every other option
+	if opts.EDNSPadding {
+		padQuery(msg)
+	}
 
 	var response *dns.Msg
 	var rtt time.Duration
+	var conn connInfo
 
 	for attempt := 0; attempt < retries; attempt++ {
 		select {
@@ -103,7 +258,7 @@ func QueryServer(ctx context.Context, domain, qtype string, server models.DNSSer
 		default:
 		}
 
-		response, rtt, err = performQuery(ctx, msg, server.Target, tlsInsecure, timeout)
+		response, rtt, conn, err = performQuery(ctx, msg, server.Target, pins, opts, timeout)
 
 		if err == nil && response != nil {
 			break
@@ -117,14 +272,27 @@ func QueryServer(ctx context.Context, domain, qtype string, server models.DNSSer
 		}
 
 		if attempt < retries-1 {
-			time.Sleep(RetryDelay)
+			queryClock.Sleep(RetryDelay)
+		}
+	}
+
+	// Middleboxes and off-path injection show up as answers to another question or ID
+	if response != nil {
+		if reason := checkResponse(msg, response); reason != "" {
+			result.SuspiciousResponse = true
+			result.SuspiciousReason = reason
 		}
 	}
 
 	if err != nil {
 		result.CommandStatus = CommandStatusError
 		result.Error = fmt.Sprintf("query failed: %v", err)
-		metrics.DNSLookupErrors.WithLabelValues(server.Target, "query_failed").Inc()
+		result.ErrorCategory = classifyError(err)
+		reason := result.ErrorCategory
+		if errors.Is(err, errPinMismatch) {
+			reason = "pin_mismatch"
+		}
+		metrics.DNSLookupErrors.WithLabelValues(server.Target, reason).Inc()
 		return server.Target, result
 	}
 
@@ -137,6 +305,10 @@ func QueryServer(ctx context.Context, domain, qtype string, server models.DNSSer
 
 	result.CommandStatus = CommandStatusOK
 	result.TimeMs = float64(rtt.Microseconds()) / 1000.0
+	result.HTTPVersion = conn.httpVersion
+	result.TLSVersion = conn.tlsVersion
+	result.TLSCipher = conn.tlsCipher
+	result.IPFamily = conn.ipFamily
 	result.RCode = RCodeMapping[response.Rcode]
 	if result.RCode == "" {
 		result.RCode = fmt.Sprintf("UNKNOWN(%d)", response.Rcode)
@@ -149,70 +321,419 @@ func QueryServer(ctx context.Context, domain, qtype string, server models.DNSSer
 		result.QType = qtypeToString(response.Question[0].Qtype)
 	}
 
-	// Parse answers using miekg/dns type assertions
 	result.Answers = []models.DNSAnswer{}
 	for _, rr := range response.Answer {
-		answer := models.DNSAnswer{
-			Name: strings.TrimSuffix(rr.Header().Name, "."),
-			Type: qtypeToString(rr.Header().Rrtype),
-			TTL:  rr.Header().Ttl,
-		}
-
-		// Type switch instead of reflection for performance
-		switch v := rr.(type) {
-		case *dns.A:
-			answer.Value = v.A.String()
-		case *dns.AAAA:
-			answer.Value = v.AAAA.String()
-		case *dns.CNAME:
-			answer.Value = strings.TrimSuffix(v.Target, ".")
-		case *dns.MX:
-			answer.Value = fmt.Sprintf("%d %s", v.Preference, strings.TrimSuffix(v.Mx, "."))
-		case *dns.NS:
-			answer.Value = strings.TrimSuffix(v.Ns, ".")
-		case *dns.PTR:
-			answer.Value = strings.TrimSuffix(v.Ptr, ".")
-		case *dns.TXT:
-			answer.Value = strings.Join(v.Txt, " ")
-		case *dns.SOA:
-			answer.Value = fmt.Sprintf("%s %s %d %d %d %d %d",
-				strings.TrimSuffix(v.Ns, "."),
-				strings.TrimSuffix(v.Mbox, "."),
-				v.Serial, v.Refresh, v.Retry, v.Expire, v.Minttl)
-		case *dns.SRV:
-			answer.Value = fmt.Sprintf("%d %d %d %s",
-				v.Priority, v.Weight, v.Port, strings.TrimSuffix(v.Target, "."))
-		case *dns.CAA:
-			answer.Value = fmt.Sprintf("%d %s %s", v.Flag, v.Tag, v.Value)
-		default:
-			answer.Value = rr.String()
-		}
+		result.Answers = append(result.Answers, toAnswer(rr))
+	}
+
+	if opts.NormalizeAnswers {
+		result.Answers = normalizeAnswers(result.Answers)
+	}
+
+	if len(response.Question) > 0 {
+		result.CNAMEChain = cnameChain(response.Question[0].Name, response.Answer)
+	}
+
+	if opt := response.IsEdns0(); opt != nil {
+		result.EDNSUDPSize = opt.UDPSize()
+	}
+	if opts.EDNSPadding {
+		padded := hasPadding(response)
+		result.EDNSPadded = &padded
+	}
+	if opts.DNSCookie {
+		echoed, supported := checkCookie(clientCookie, response)
+		result.CookieEchoed = &echoed
+		result.CookieSupported = &supported
+	}
+
+	if len(opts.Expected) > 0 {
+		passed := matchExpected(opts.Expected, result.Answers)
+		result.AssertionPassed = &passed
+	}
 
-		result.Answers = append(result.Answers, answer)
+	// FCrDNS: a PTR name only counts if it resolves back to the address, as mail servers check
+	if opts.ForwardConfirm && dnsType == dns.TypePTR {
+		confirmed := forwardConfirm(ctx, domain, result.Answers, server, opts, retries, timeout)
+		result.ForwardConfirmed = &confirmed
 	}
 
+	if key != "" {
+		cache.put(key, result)
+	}
 	return server.Target, result
 }
 
-// performQuery delegates DNS query execution to AdGuard upstream library.
-// Target must be prenormalized - passed directly to AdGuard for protocol handling.
-func performQuery(ctx context.Context, msg *dns.Msg, normalizedTarget string, tlsInsecure bool, timeout time.Duration) (*dns.Msg, time.Duration, error) {
-	start := time.Now()
+// forwardConfirm reports whether any PTR name in answers has an A (or AAAA for IPv6) record
+// equal to the address the reverse name was built from, asking the same server.
+func forwardConfirm(ctx context.Context, reverseName string, answers []models.DNSAnswer, server models.DNSServer, opts models.QueryOptions, retries int, timeout time.Duration) bool {
+	ip, err := normalize.ReverseDNSToIP(reverseName)
+	if err != nil {
+		return false
+	}
+	qtype := "AAAA"
+	if ip.To4() != nil {
+		qtype = "A"
+	}
 
-	opts := &upstream.Options{
-		Timeout: timeout,
+	opts.ForwardConfirm = false
+	opts.Expected = nil
+	for _, ptr := range answers {
+		if ptr.Type != "PTR" {
+			continue
+		}
+		_, forward := queryServer(ctx, ptr.Value, qtype, server, opts, retries, timeout)
+		for _, ans := range forward.Answers {
+			if ans.Type == qtype && ip.Equal(net.ParseIP(ans.Value)) {
+				return true
+			}
+		}
 	}
-	if tlsInsecure {
-		// #nosec G402 - user-controlled for testing encrypted protocols
+	return false
+}
+
+// toAnswer renders a resource record with miekg/dns type assertions.
+func toAnswer(rr dns.RR) models.DNSAnswer {
+	answer := models.DNSAnswer{
+		Name: strings.TrimSuffix(rr.Header().Name, "."),
+		Type: qtypeToString(rr.Header().Rrtype),
+		TTL:  rr.Header().Ttl,
+	}
+
+	// Type switch instead of reflection for performance
+	switch v := rr.(type) {
+	case *dns.A:
+		answer.Value = v.A.String()
+	case *dns.AAAA:
+		answer.Value = v.AAAA.String()
+	case *dns.CNAME:
+		answer.Value = strings.TrimSuffix(v.Target, ".")
+	case *dns.MX:
+		answer.Value = fmt.Sprintf("%d %s", v.Preference, strings.TrimSuffix(v.Mx, "."))
+	case *dns.NS:
+		answer.Value = strings.TrimSuffix(v.Ns, ".")
+	case *dns.PTR:
+		answer.Value = strings.TrimSuffix(v.Ptr, ".")
+	case *dns.TXT:
+		answer.Value = strings.Join(v.Txt, " ")
+	case *dns.SOA:
+		answer.Value = fmt.Sprintf("%s %s %d %d %d %d %d",
+			strings.TrimSuffix(v.Ns, "."),
+			strings.TrimSuffix(v.Mbox, "."),
+			v.Serial, v.Refresh, v.Retry, v.Expire, v.Minttl)
+	case *dns.SRV:
+		answer.Value = fmt.Sprintf("%d %d %d %s",
+			v.Priority, v.Weight, v.Port, strings.TrimSuffix(v.Target, "."))
+	case *dns.CAA:
+		answer.Value = fmt.Sprintf("%d %s %s", v.Flag, v.Tag, v.Value)
+	default:
+		answer.Value = rr.String()
+	}
+	return answer
+}
+
+// normalizeAnswers sorts by type, value, name then TTL and drops exact duplicates,
+// so round-robin order and repeated records do not show up as differences.
+func normalizeAnswers(answers []models.DNSAnswer) []models.DNSAnswer {
+	slices.SortFunc(answers, func(a, b models.DNSAnswer) int {
+		return cmp.Or(
+			cmp.Compare(a.Type, b.Type),
+			cmp.Compare(a.Value, b.Value),
+			cmp.Compare(a.Name, b.Name),
+			cmp.Compare(a.TTL, b.TTL),
+		)
+	})
+	return slices.Compact(answers)
+}
+
+// padQuery adds an OPT record with an EDNS0 padding option sized so the query is a
+// multiple of PaddingBlockSize bytes (RFC 8467 block-length padding).
+func padQuery(msg *dns.Msg) {
+	msg.SetEdns0(EDNSBufferSize, false)
+	pad := &dns.EDNS0_PADDING{}
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, pad)
+	// Len already counts the 4-byte option header, so only the payload is left to fill
+	if rem := msg.Len() % PaddingBlockSize; rem != 0 {
+		pad.Padding = make([]byte, PaddingBlockSize-rem)
+	}
+}
+
+// addCookie adds an EDNS0 cookie option with a fresh random client cookie, creating the
+// OPT record if needed, and returns the client cookie in hex.
+func addCookie(msg *dns.Msg) string {
+	if msg.IsEdns0() == nil {
+		msg.SetEdns0(EDNSBufferSize, false)
+	}
+	b := make([]byte, ClientCookieSize)
+	_, _ = rand.Read(b)
+	cookie := hex.EncodeToString(b)
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: cookie})
+	return cookie
+}
+
+// checkCookie reports whether resp echoes clientCookie and whether it also carries a
+// server cookie of valid length, which is what a cookie-aware server returns.
+func checkCookie(clientCookie string, resp *dns.Msg) (echoed, supported bool) {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return false, false
+	}
+	for _, o := range opt.Option {
+		c, ok := o.(*dns.EDNS0_COOKIE)
+		if !ok || len(c.Cookie) < len(clientCookie) || !strings.EqualFold(c.Cookie[:len(clientCookie)], clientCookie) {
+			continue
+		}
+		server := (len(c.Cookie) - len(clientCookie)) / 2
+		return true, server >= minServerCookieSize && server <= maxServerCookieSize
+	}
+	return false, false
+}
+
+// hasPadding reports whether resp's OPT record carries an EDNS0 padding option.
+func hasPadding(resp *dns.Msg) bool {
+	opt := resp.IsEdns0()
+	if opt == nil {
+		return false
+	}
+	for _, o := range opt.Option {
+		if o.Option() == dns.EDNS0PADDING {
+			return true
+		}
+	}
+	return false
+}
+
+// matchExpected reports whether, for every expected record type, the answer values equal the expected set.
+// Order, case, trailing dots and IP spelling are ignored.
+func matchExpected(expected map[string][]string, answers []models.DNSAnswer) bool {
+	for rrtype, values := range expected {
+		want := make(map[string]bool, len(values))
+		for _, v := range values {
+			want[canonicalValue(v)] = true
+		}
+		got := make(map[string]bool)
+		for _, ans := range answers {
+			if strings.EqualFold(ans.Type, rrtype) {
+				got[canonicalValue(ans.Value)] = true
+			}
+		}
+		if len(got) != len(want) {
+			return false
+		}
+		for v := range got {
+			if !want[v] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// canonicalValue makes equivalent answer values compare equal.
+func canonicalValue(v string) string {
+	v = strings.TrimSpace(v)
+	if ip := net.ParseIP(v); ip != nil {
+		return ip.String()
+	}
+	return strings.ToLower(strings.TrimSuffix(v, "."))
+}
+
+// checkResponse compares the reply's ID and question section (name, type, class) with the query.
+// Returns why the reply looks spoofed, or "" when it matches.
+func checkResponse(query, resp *dns.Msg) string {
+	if resp.Id != query.Id {
+		return fmt.Sprintf("response ID %d does not match query ID %d", resp.Id, query.Id)
+	}
+	if len(resp.Question) != 1 {
+		return fmt.Sprintf("response has %d questions, expected 1", len(resp.Question))
+	}
+
+	want, got := query.Question[0], resp.Question[0]
+	switch {
+	case !strings.EqualFold(want.Name, got.Name):
+		return fmt.Sprintf("response question name %s does not match %s", got.Name, want.Name)
+	case want.Qtype != got.Qtype:
+		return fmt.Sprintf("response question type %s does not match %s", qtypeToString(got.Qtype), qtypeToString(want.Qtype))
+	case want.Qclass != got.Qclass:
+		return fmt.Sprintf("response question class %s does not match %s", dns.Class(got.Qclass), dns.Class(want.Qclass))
+	}
+	return ""
+}
+
+// cnameChain follows CNAME records from qname, so the chain is ordered even if the answer section is not.
+// Stops at a name without a CNAME or on a loop.
+func cnameChain(qname string, answer []dns.RR) []string {
+	targets := make(map[string]string)
+	for _, rr := range answer {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			targets[strings.ToLower(cname.Hdr.Name)] = cname.Target
+		}
+	}
+
+	var chain []string
+	seen := map[string]bool{}
+	name := strings.ToLower(qname)
+	for !seen[name] {
+		seen[name] = true
+		target, ok := targets[name]
+		if !ok {
+			break
+		}
+		chain = append(chain, strings.TrimSuffix(target, "."))
+		name = strings.ToLower(target)
+	}
+	return chain
+}
+
+// connInfo describes the connection a query went over; only ipFamily is set for plaintext Do53.
+type connInfo struct {
+	httpVersion string // DoH only
+	tlsVersion  string
+	tlsCipher   string
+	ipFamily    string
+}
+
+// newConnInfo reads the negotiated versions off a TLS handshake.
+// DoH servers that skip ALPN speak HTTP/1.1.
+func newConnInfo(state *tls.ConnectionState, isDoH bool) connInfo {
+	if state == nil {
+		return connInfo{}
+	}
+	info := connInfo{
+		tlsVersion: tls.VersionName(state.Version),
+		tlsCipher:  tls.CipherSuiteName(state.CipherSuite),
+	}
+	if isDoH {
+		info.httpVersion = state.NegotiatedProtocol
+		if info.httpVersion == "" {
+			info.httpVersion = string(upstream.HTTPVersion11)
+		}
+	}
+	return info
+}
+
+// performQuery delegates DNS query execution to AdGuard upstream library.
+// Target must be prenormalized - passed directly to AdGuard for protocol handling.
+// Also reports the negotiated TLS and HTTP versions of encrypted targets.
+// With pins, the handshake fails unless a presented certificate's SPKI digest is pinned.
+// ip_family restricts the addresses a hostname target is dialled on, and the family used is reported.
+// server_name replaces the SNI of encrypted targets without changing the address dialled.
+// Every TLS or QUIC handshake counts as a new upstream connection, and so does every plain query.
+func performQuery(ctx context.Context, msg *dns.Msg, normalizedTarget string, pins [][]byte, queryOpts models.QueryOptions, timeout time.Duration) (*dns.Msg, time.Duration, connInfo, error) {
+	start := queryClock.Now()
+
+	opts := upstreamOptions(queryOpts, timeout)
+	if opts.InsecureSkipVerify {
 		slog.Warn("TLS certificate verification is DISABLED - USE ONLY FOR TESTING",
 			"target", normalizedTarget)
-		opts.InsecureSkipVerify = true
+	}
+
+	// Decode stamps here rather than in normalize so their certificate pins reach the TLS handshake
+	address := normalizedTarget
+	if normalize.IsStamp(normalizedTarget) {
+		stamp, err := normalize.ParseStamp(normalizedTarget)
+		if err != nil {
+			return nil, 0, connInfo{}, err
+		}
+		address = stamp.Target
+		if len(stamp.CertHashes) > 0 {
+			opts.VerifyServerCertificate = verifyCertHashes(stamp.CertHashes)
+		}
+	}
+
+	sourceIP := net.ParseIP(queryOpts.SourceIP)
+	usedFamily, err := applyFamily(address, queryOpts, sourceIP, opts)
+	if err != nil {
+		return nil, 0, connInfo{}, err
+	}
+
+	isDoH := strings.HasPrefix(address, normalize.SchemeHTTPS+"://")
+	protocol := GetDNSProtocolFromTarget(address)
+	encrypted := normalize.SupportsPins(address)
+	if !encrypted {
+		metrics.UpstreamConnectionsCreated.WithLabelValues(protocol).Inc()
+	}
+
+	// Present server_name in place of the target host while still connecting to that host
+	var connectHost string
+	if queryOpts.ServerName != "" && encrypted {
+		if address, connectHost, err = applyServerName(address, queryOpts.ServerName, opts); err != nil {
+			return nil, 0, connInfo{}, err
+		}
+	}
+
+	// dnsproxy keeps its connections private, so read the negotiated versions off the TLS handshake.
+	// The same hook enforces certificate pins on every TLS-based protocol.
+	var handshake atomic.Pointer[tls.ConnectionState]
+	var pinFailed atomic.Bool
+	opts.VerifyConnection = func(state tls.ConnectionState) error {
+		handshake.Store(&state)
+		metrics.UpstreamConnectionsCreated.WithLabelValues(protocol).Inc()
+		if len(pins) > 0 && !matchPins(state.PeerCertificates, pins) {
+			pinFailed.Store(true)
+			return errPinMismatch
+		}
+		return nil
+	}
+	// TLS and QUIC stacks do not all wrap the handshake error, so report the pin failure directly
+	queryErr := func(err error) error {
+		if pinFailed.Load() {
+			err = errPinMismatch
+		}
+		return fmt.Errorf("DNS query failed: %w", err)
+	}
+	// An encrypted answer without a handshake came over a connection opened earlier
+	connInfoFor := func(doh bool) connInfo {
+		state := handshake.Load()
+		if encrypted && state == nil {
+			metrics.UpstreamConnectionsReused.WithLabelValues(protocol).Inc()
+		}
+		info := newConnInfo(state, doh)
+		info.ipFamily = usedFamily()
+		return info
+	}
+
+	// dnsproxy keeps its dialer private, so proxied or source-bound queries go through our own clients
+	var dial dialFunc
+	switch {
+	case queryOpts.ProxyURL != "":
+		if dial, err = proxyDialContext(queryOpts.ProxyURL, sourceDialer(sourceIP, "tcp", timeout)); err != nil {
+			return nil, 0, connInfo{}, err
+		}
+	case sourceIP != nil:
+		dial = sourceDialContext(sourceIP, timeout)
+	}
+	if dial != nil && connectHost != "" {
+		dial = redirectDial(dial, queryOpts.ServerName, connectHost)
+	}
+
+	// dnsproxy hides its HTTP client and only sends GET, so DoH over HTTP/1.1 and HTTP/2 goes
+	// through a plain RFC 8484 client that can send POST and trace each request phase
+	if isDoH && (!queryOpts.DoHHTTP3 || dial != nil) {
+		method := http.MethodGet
+		if queryOpts.DoHMethod == models.DoHMethodPOST {
+			method = http.MethodPost
+		}
+		resp, err := exchangeDoH(ctx, msg, address, normalizedTarget, method, queryOpts.HTTPHost, opts, dial, timeout)
+		if err != nil {
+			return nil, 0, connInfo{}, queryErr(err)
+		}
+		return resp, queryClock.Now().Sub(start), connInfoFor(true), nil
+	}
+	if dial != nil {
+		resp, err := exchangeConn(ctx, msg, address, opts, dial, timeout)
+		if err != nil {
+			return nil, 0, connInfo{}, queryErr(err)
+		}
+		return resp, queryClock.Now().Sub(start), connInfoFor(false), nil
 	}
 
 	// AdGuard upstream.AddressToUpstream handles scheme parsing, port defaults, IPv6 brackets
-	up, err := upstream.AddressToUpstream(normalizedTarget, opts)
+	up, err := upstream.AddressToUpstream(address, opts)
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create upstream: %w", err)
+		return nil, 0, connInfo{}, fmt.Errorf("failed to create upstream: %w", err)
 	}
 	defer func() {
 		_ = up.Close()
@@ -232,39 +753,226 @@ func performQuery(ctx context.Context, msg *dns.Msg, normalizedTarget string, tl
 
 	select {
 	case <-ctx.Done():
-		return nil, 0, fmt.Errorf("query cancelled: %w", ctx.Err())
+		return nil, 0, connInfo{}, fmt.Errorf("query cancelled: %w", ctx.Err())
 	case res := <-resultCh:
 		if res.err != nil {
-			return nil, 0, fmt.Errorf("DNS query failed: %w", res.err)
+			// Keep the reply dnsproxy rejected (bad ID or question) so it can be reported
+			return res.resp, 0, connInfo{}, queryErr(res.err)
+		}
+		rtt := queryClock.Now().Sub(start)
+		return res.resp, rtt, connInfoFor(isDoH), nil
+	}
+}
+
+// upstreamOptions maps the request's query options onto dnsproxy upstream options.
+func upstreamOptions(queryOpts models.QueryOptions, timeout time.Duration) *upstream.Options {
+	opts := &upstream.Options{
+		Timeout:   timeout,
+		Bootstrap: bootstrapResolver,
+		// #nosec G402 - user-controlled for testing encrypted protocols
+		InsecureSkipVerify: queryOpts.TLSInsecureSkipVerify,
+	}
+	// Left unset, dnsproxy offers HTTP/1.1 and HTTP/2
+	if queryOpts.DoHHTTP3 {
+		// Offering only h3 makes dnsproxy dial QUIC directly instead of racing it against HTTP/2
+		opts.HTTPVersions = []upstream.HTTPVersion{upstream.HTTPVersion3}
+	}
+	return opts
+}
+
+// exchangeDoH sends msg as an RFC 8484 GET or POST, honoring the TLS settings of the upstream options.
+// Connections go through dial when set, else through the bootstrap resolver if configured.
+// Request phases are recorded under metricTarget, and host overrides the Host header when set.
+func exchangeDoH(ctx context.Context, msg *dns.Msg, target, metricTarget, method, host string, opts *upstream.Options, dial dialFunc, timeout time.Duration) (*dns.Msg, error) {
+	// Like dnsproxy, GET queries carry ID 0 so HTTP caches can share them (RFC 8484 section 4.1)
+	query := msg
+	if method == http.MethodGet && msg.Id != 0 {
+		query = msg.Copy()
+		query.Id = 0
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("pack query: %w", err)
+	}
+
+	var req *http.Request
+	if method == http.MethodPost {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(packed))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/dns-message")
 		}
-		rtt := time.Since(start)
-		return res.resp, rtt, nil
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err == nil {
+			q := req.URL.Query()
+			q.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+			req.URL.RawQuery = q.Encode()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+	// Sent blank like dnsproxy, so providers cannot tell the tester from other clients
+	req.Header.Set("User-Agent", "")
+	if host != "" {
+		req.Host = host
+	}
+
+	transport := &http.Transport{
+		// #nosec G402 - InsecureSkipVerify is user-controlled for testing encrypted protocols
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify:    opts.InsecureSkipVerify,
+			VerifyPeerCertificate: opts.VerifyServerCertificate,
+			VerifyConnection:      opts.VerifyConnection,
+			MinVersion:            tls.VersionTLS12,
+		},
+		ForceAttemptHTTP2: true,
+	}
+	switch {
+	case dial != nil:
+		transport.DialContext = dial
+	case opts.Bootstrap != nil:
+		transport.DialContext = bootstrapDialContext(opts.Bootstrap)
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Timeout: timeout, Transport: &tracingTransport{base: transport, target: metricTarget}}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server returned HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return nil, fmt.Errorf("read DoH response: %w", err)
+	}
+
+	reply := new(dns.Msg)
+	if err := reply.Unpack(body); err != nil {
+		return nil, fmt.Errorf("unpack DoH response: %w", err)
 	}
+	if method == http.MethodGet && reply.Id == 0 {
+		reply.Id = msg.Id
+	}
+	return reply, nil
+}
+
+// errPinMismatch marks a handshake rejected because no certificate matched pin_sha256 (possible MITM).
+var errPinMismatch = errors.New("no certificate presented by the server matches pin_sha256")
+
+// matchPins reports whether any presented certificate has a pinned SHA-256 SPKI digest.
+func matchPins(certs []*x509.Certificate, pins [][]byte) bool {
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verifyCertHashes enforces DNS stamp pins: some certificate in the chain must have a pinned TBS hash.
+func verifyCertHashes(hashes []string) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(cert.RawTBSCertificate)
+			if slices.Contains(hashes, hex.EncodeToString(sum[:])) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no certificate in the chain matches the DNS stamp hashes")
+	}
+}
+
+// Resolver runs one task's queries. Task clients and the worker depend on it so tests
+// can substitute canned results for the network.
+type Resolver interface {
+	RunQueries(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, timeout time.Duration, maxConcurrentQueries, maxRetries int) map[string]models.DNSLookupResult
+}
+
+// ResolverFunc adapts a function with RunQueries' signature to Resolver.
+type ResolverFunc func(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, timeout time.Duration, maxConcurrentQueries, maxRetries int) map[string]models.DNSLookupResult
+
+// RunQueries calls f.
+func (f ResolverFunc) RunQueries(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, timeout time.Duration, maxConcurrentQueries, maxRetries int) map[string]models.DNSLookupResult {
+	return f(ctx, domain, qtype, servers, opts, timeout, maxConcurrentQueries, maxRetries)
 }
 
+// Default is the Resolver that queries servers over the network with RunQueries.
+var Default Resolver = ResolverFunc(RunQueries)
+
 // RunQueries fans out queries to multiple servers with concurrency limit.
 // Semaphore pattern prevents resource exhaustion when querying many servers.
-func RunQueries(ctx context.Context, domain, qtype string, servers []models.DNSServer, tlsInsecure bool, timeout time.Duration, maxConcurrentQueries, maxRetries int) map[string]models.DNSLookupResult {
+// When ctx expires first, completed results are returned and the stragglers are
+// reported as CommandStatusTimeout instead of stalling the task on the slowest server.
+func RunQueries(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, timeout time.Duration, maxConcurrentQueries, maxRetries int) map[string]models.DNSLookupResult {
 	results := make(map[string]models.DNSLookupResult)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	pool := make(chan struct{}, maxConcurrentQueries)
 
+dispatch:
 	for _, server := range servers {
+		select {
+		case pool <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
 		wg.Add(1)
-		pool <- struct{}{}
 
 		go func(srv models.DNSServer) {
 			defer wg.Done()
 			defer func() { <-pool }()
 
-			target, result := QueryServer(ctx, domain, qtype, srv, tlsInsecure, maxRetries, timeout)
+			target, result := QueryServer(ctx, domain, qtype, srv, opts, maxRetries, timeout)
 			mu.Lock()
 			results[target] = result
 			mu.Unlock()
 		}(server)
 	}
 
-	wg.Wait()
-	return results
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return results
+	case <-ctx.Done():
+	}
+
+	// Stragglers keep writing to results, so hand back a copy
+	mu.Lock()
+	defer mu.Unlock()
+	partial := make(map[string]models.DNSLookupResult, len(servers))
+	for _, srv := range servers {
+		if result, ok := results[srv.Target]; ok {
+			partial[srv.Target] = result
+			continue
+		}
+		partial[srv.Target] = models.DNSLookupResult{
+			CommandStatus: CommandStatusTimeout,
+			Tags:          srv.Tags,
+			DNSProtocol:   GetDNSProtocolFromTarget(srv.Target),
+			Error:         fmt.Sprintf("no answer before the task deadline: %v", ctx.Err()),
+			ErrorCategory: ErrorCategoryTimeout,
+		}
+		metrics.DNSLookupErrors.WithLabelValues(srv.Target, ErrorCategoryTimeout).Inc()
+	}
+	return partial
 }
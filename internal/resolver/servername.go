@@ -0,0 +1,61 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+
+	"github.com/AdguardTeam/dnsproxy/upstream"
+)
+
+// pinnedResolver answers lookups of name with the addresses of host, so a target whose host
+// was swapped for a server_name keeps connecting where it did. Other names go through base.
+type pinnedResolver struct {
+	name string
+	host string
+	base upstream.Resolver
+}
+
+// LookupNetIP implements upstream.Resolver.
+func (r *pinnedResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if host == r.name {
+		if ip, err := netip.ParseAddr(r.host); err == nil {
+			return []netip.Addr{ip}, nil
+		}
+		host = r.host
+	}
+	base := r.base
+	if base == nil {
+		base = net.DefaultResolver
+	}
+	return base.LookupNetIP(ctx, network, host)
+}
+
+// applyServerName swaps serverName into address, so TLS presents and verifies it and DoH sends
+// it as Host, and installs a pinnedResolver that keeps resolving it to the original host.
+// It returns the new address and the original host.
+func applyServerName(address, serverName string, opts *upstream.Options) (string, string, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", "", err
+	}
+	host := u.Hostname()
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(serverName, port)
+	} else {
+		u.Host = serverName
+	}
+	opts.Bootstrap = &pinnedResolver{name: serverName, host: host, base: opts.Bootstrap}
+	return u.String(), host, nil
+}
+
+// redirectDial makes dial connect to host wherever it is asked for name, keeping the port.
+func redirectDial(dial dialFunc, name, host string) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if h, port, err := net.SplitHostPort(addr); err == nil && h == name {
+			addr = net.JoinHostPort(host, port)
+		}
+		return dial(ctx, network, addr)
+	}
+}
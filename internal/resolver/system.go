@@ -0,0 +1,48 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// SystemTarget is the pseudo-target that results from the host's own resolver are reported as.
+const SystemTarget = "system://default"
+
+// systemNameserver returns the first nameserver in /etc/resolv.conf as a udp:// target;
+// tests replace it to point at a local server.
+var systemNameserver = func() (string, error) {
+	conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return "", err
+	}
+	if len(conf.Servers) == 0 {
+		return "", errors.New("no nameserver in /etc/resolv.conf")
+	}
+	return "udp://" + net.JoinHostPort(conf.Servers[0], conf.Port), nil
+}
+
+// QuerySystem queries the resolver this host uses by default, the first nameserver of
+// /etc/resolv.conf, over plain UDP. It gives a baseline to compare tested servers against.
+// Proxy, source address and address family options do not apply to it and are dropped.
+func QuerySystem(ctx context.Context, domain, qtype string, opts models.QueryOptions, retries int, timeout time.Duration) models.DNSLookupResult {
+	target, err := systemNameserver()
+	if err != nil {
+		return models.DNSLookupResult{
+			CommandStatus: CommandStatusError,
+			DNSProtocol:   "udp",
+			Error:         fmt.Sprintf("system resolver: %v", err),
+		}
+	}
+
+	opts.ProxyURL = ""
+	opts.SourceIP = ""
+	opts.IPFamily = ""
+	_, result := QueryServer(ctx, domain, qtype, models.DNSServer{Target: target, Tags: []string{target}}, opts, retries, timeout)
+	return result
+}
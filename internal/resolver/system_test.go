@@ -0,0 +1,41 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestQuerySystem(t *testing.T) {
+	target := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.53"),
+		}}
+		_ = w.WriteMsg(m)
+	})
+	orig := systemNameserver
+	t.Cleanup(func() { systemNameserver = orig })
+	systemNameserver = func() (string, error) { return target, nil }
+
+	// A proxy would make a UDP target fail, so it must not reach the system resolver
+	result := QuerySystem(context.Background(), "example.com", "A", models.QueryOptions{ProxyURL: "socks5://192.0.2.1:1080"}, 1, DefaultTimeout)
+	if result.CommandStatus != CommandStatusOK || len(result.Answers) != 1 || result.Answers[0].Value != "192.0.2.53" {
+		t.Fatalf("Expected the system resolver's answer, got %+v", result)
+	}
+	if len(result.Tags) != 1 || result.Tags[0] != target {
+		t.Errorf("Expected the nameserver address as tag, got %v", result.Tags)
+	}
+
+	systemNameserver = func() (string, error) { return "", errors.New("no nameserver in /etc/resolv.conf") }
+	result = QuerySystem(context.Background(), "example.com", "A", models.QueryOptions{}, 1, DefaultTimeout)
+	if result.CommandStatus != CommandStatusError || result.Error == "" {
+		t.Errorf("Expected an error result without a system resolver, got %+v", result)
+	}
+}
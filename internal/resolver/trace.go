@@ -0,0 +1,192 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// MaxTraceDepth bounds the number of referrals Trace follows before giving up.
+const MaxTraceDepth = 16
+
+// RootServers are the IPv4 addresses of a.root-servers.net through m.root-servers.net.
+var RootServers = []string{
+	"198.41.0.4", "170.247.170.2", "192.33.4.12", "199.7.91.13", "192.203.230.10", "192.5.5.241", "192.112.36.4",
+	"198.97.190.53", "192.36.148.17", "192.58.128.30", "193.0.14.129", "199.7.83.42", "202.12.27.33",
+}
+
+// traceLookupHost resolves glueless nameserver names; tests replace it.
+var traceLookupHost = net.DefaultResolver.LookupHost
+
+// tracePort is the port nameservers are queried on; tests replace it to reach local servers.
+var tracePort = "53"
+
+// TraceStep is one level of an iterative resolution: the server asked and what it replied.
+type TraceStep struct {
+	Zone        string             `json:"zone"`                  // Zone the server was asked as an authority for
+	Server      string             `json:"server"`                // Nameserver name (the address for roots given by IP)
+	Address     string             `json:"address"`               // Address the query was sent to
+	TimeMs      float64            `json:"time_ms"`               // Round trip time in milliseconds
+	RCode       string             `json:"rcode,omitempty"`       // Response code
+	Referral    string             `json:"referral,omitempty"`    // Child zone the server delegated to
+	Nameservers []string           `json:"nameservers,omitempty"` // Nameservers of the child zone
+	Answers     []models.DNSAnswer `json:"answers,omitempty"`     // Final answer section
+	Error       string             `json:"error,omitempty"`       // Why this server could not be used
+}
+
+// traceServer is a nameserver to try at the current level.
+type traceServer struct {
+	name string
+	addr string
+}
+
+// Trace resolves domain iteratively from the root servers down, like dig +trace. Each level
+// queries the zone's nameservers with RD=false, trying them in order until one replies, and
+// follows the referral in the authority section. Glue is used when present; glueless
+// nameserver names are looked up with the system resolver. Steps are returned even on error.
+func Trace(ctx context.Context, domain, qtype string, roots []string, timeout time.Duration) ([]TraceStep, error) {
+	dnsType, err := stringToQType(qtype)
+	if err != nil {
+		return nil, err
+	}
+	qname := dns.Fqdn(domain)
+
+	servers := make([]traceServer, 0, len(roots))
+	for _, ip := range roots {
+		servers = append(servers, traceServer{name: ip, addr: net.JoinHostPort(ip, tracePort)})
+	}
+
+	var steps []TraceStep
+	zone := "."
+	for depth := 0; depth < MaxTraceDepth; depth++ {
+		resp, step, err := traceQuery(ctx, qname, dnsType, zone, servers, timeout)
+		steps = append(steps, step...)
+		if err != nil {
+			return steps, err
+		}
+		last := &steps[len(steps)-1]
+
+		if resp.Rcode != dns.RcodeSuccess || len(resp.Answer) > 0 {
+			for _, rr := range resp.Answer {
+				last.Answers = append(last.Answers, toAnswer(rr))
+			}
+			return steps, nil
+		}
+
+		child, names := referral(resp, zone, qname)
+		if child == "" {
+			// NODATA from an authoritative server ends the walk like an answer does
+			if resp.Authoritative {
+				return steps, nil
+			}
+			return steps, fmt.Errorf("%s gave neither an answer nor a referral below %s (lame delegation?)", last.Server, zone)
+		}
+		last.Referral = strings.TrimSuffix(child, ".")
+		for _, name := range names {
+			last.Nameservers = append(last.Nameservers, strings.TrimSuffix(name, "."))
+		}
+
+		if servers = nameserverAddresses(ctx, resp, names); len(servers) == 0 {
+			return steps, fmt.Errorf("no address found for the nameservers of %s", last.Referral)
+		}
+		zone = child
+	}
+	return steps, fmt.Errorf("delegation deeper than %d levels", MaxTraceDepth)
+}
+
+// traceQuery asks each server in turn until one replies, recording a step per attempt.
+func traceQuery(ctx context.Context, qname string, qtype uint16, zone string, servers []traceServer, timeout time.Duration) (*dns.Msg, []TraceStep, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(qname, qtype)
+	msg.RecursionDesired = false
+	msg.SetEdns0(EDNSBufferSize, false)
+
+	var steps []TraceStep
+	for _, srv := range servers {
+		step := TraceStep{Zone: strings.TrimSuffix(zone, "."), Server: srv.name, Address: srv.addr}
+		if step.Zone == "" {
+			step.Zone = "."
+		}
+
+		client := &dns.Client{Timeout: timeout}
+		resp, rtt, err := client.ExchangeContext(ctx, msg, srv.addr)
+		if err == nil && resp.Truncated {
+			client.Net = "tcp"
+			resp, rtt, err = client.ExchangeContext(ctx, msg, srv.addr)
+		}
+		step.TimeMs = float64(rtt.Microseconds()) / 1000.0
+		if err != nil {
+			step.Error = err.Error()
+			steps = append(steps, step)
+			if ctx.Err() != nil {
+				return nil, steps, ctx.Err()
+			}
+			continue
+		}
+
+		step.RCode = RCodeMapping[resp.Rcode]
+		if step.RCode == "" {
+			step.RCode = fmt.Sprintf("UNKNOWN(%d)", resp.Rcode)
+		}
+		return resp, append(steps, step), nil
+	}
+	return nil, steps, errors.New("no nameserver for " + strings.TrimSuffix(zone, ".") + " replied")
+}
+
+// referral returns the child zone and its nameserver names when resp delegates a zone strictly
+// below zone that contains qname. Upward or sideways referrals return "" so the walk cannot loop.
+func referral(resp *dns.Msg, zone, qname string) (string, []string) {
+	var child string
+	var names []string
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		owner := dns.CanonicalName(ns.Hdr.Name)
+		if !dns.IsSubDomain(owner, dns.CanonicalName(qname)) || !dns.IsSubDomain(dns.CanonicalName(zone), owner) || dns.CountLabel(owner) <= dns.CountLabel(zone) {
+			continue
+		}
+		if child == "" {
+			child = owner
+		}
+		if owner == child {
+			names = append(names, ns.Ns)
+		}
+	}
+	return child, names
+}
+
+// nameserverAddresses maps nameserver names to IPv4 addresses from the glue in the additional
+// section, looking up the names that have none.
+func nameserverAddresses(ctx context.Context, resp *dns.Msg, names []string) []traceServer {
+	glue := make(map[string][]string)
+	for _, rr := range resp.Extra {
+		if a, ok := rr.(*dns.A); ok {
+			name := dns.CanonicalName(a.Hdr.Name)
+			glue[name] = append(glue[name], a.A.String())
+		}
+	}
+
+	var servers []traceServer
+	for _, name := range names {
+		ips := glue[dns.CanonicalName(name)]
+		if len(ips) == 0 {
+			addrs, err := traceLookupHost(ctx, strings.TrimSuffix(name, "."))
+			if err != nil {
+				continue
+			}
+			ips = addrs
+		}
+		for _, ip := range ips {
+			servers = append(servers, traceServer{name: strings.TrimSuffix(name, "."), addr: net.JoinHostPort(ip, tracePort)})
+		}
+	}
+	return servers
+}
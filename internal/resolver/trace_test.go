@@ -0,0 +1,81 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// startDelegationServer serves a mock delegation chain: successive queries get a referral to
+// each zone in turn, with 127.0.0.1 as glue, and then the final reply.
+func startDelegationServer(t *testing.T, zones []string, final func(m *dns.Msg)) []string {
+	t.Helper()
+	var queries atomic.Int32
+	target := startDNSServer(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		n := int(queries.Add(1)) - 1
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if r.RecursionDesired {
+			m.Rcode = dns.RcodeRefused
+		} else if n < len(zones) {
+			ns := "ns." + zones[n]
+			m.Ns = []dns.RR{&dns.NS{Hdr: dns.RR_Header{Name: zones[n], Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 3600}, Ns: ns}}
+			m.Extra = []dns.RR{&dns.A{Hdr: dns.RR_Header{Name: ns, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600}, A: net.ParseIP("127.0.0.1")}}
+		} else {
+			final(m)
+		}
+		_ = w.WriteMsg(m)
+	})
+
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(target, "udp://"))
+	tracePort = port
+	t.Cleanup(func() { tracePort = "53" })
+	return []string{host}
+}
+
+func TestTrace(t *testing.T) {
+	roots := startDelegationServer(t, []string{"com.", "example.com."}, func(m *dns.Msg) {
+		m.Authoritative = true
+		m.Answer = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: "www.example.com.", Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300},
+			A:   net.ParseIP("192.0.2.1"),
+		}}
+	})
+
+	steps, err := Trace(context.Background(), "www.example.com", "A", roots, DefaultTimeout)
+	if err != nil {
+		t.Fatalf("Trace failed: %v", err)
+	}
+	if len(steps) != 3 {
+		t.Fatalf("Expected 3 steps, got %+v", steps)
+	}
+	for i, want := range []struct{ zone, referral, server string }{
+		{".", "com", "127.0.0.1"},
+		{"com", "example.com", "ns.com"},
+		{"example.com", "", "ns.example.com"},
+	} {
+		if steps[i].Zone != want.zone || steps[i].Referral != want.referral || steps[i].Server != want.server {
+			t.Errorf("Step %d: expected zone %q referral %q server %q, got %+v", i, want.zone, want.referral, want.server, steps[i])
+		}
+	}
+	if last := steps[2]; last.RCode != "NOERROR" || len(last.Answers) != 1 || last.Answers[0].Value != "192.0.2.1" {
+		t.Errorf("Expected the final answer on the last step, got %+v", last)
+	}
+}
+
+func TestTraceRejectsNonDescendingReferral(t *testing.T) {
+	// The com. servers refer back to com., which would loop forever if followed
+	roots := startDelegationServer(t, []string{"com.", "com."}, func(m *dns.Msg) {})
+
+	steps, err := Trace(context.Background(), "www.example.com", "A", roots, DefaultTimeout)
+	if err == nil || !strings.Contains(err.Error(), "lame delegation") {
+		t.Fatalf("Expected a lame delegation error, got %v", err)
+	}
+	if len(steps) != 2 {
+		t.Errorf("Expected the walk to stop after 2 steps, got %+v", steps)
+	}
+}
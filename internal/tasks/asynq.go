@@ -6,36 +6,83 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/hibiken/asynq"
 	"github.com/redis/go-redis/v9"
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
 )
 
 const (
 	// TaskTypeDNSLookup is the task type identifier for DNS lookup tasks
 	TaskTypeDNSLookup = "dns:lookup"
+	// ResultKeyPrefix prefixes the Redis key holding a completed task's metadata
+	ResultKeyPrefix = "dnstester:task-meta:"
+
+	// listScanCap bounds how many tasks ListTasks reads per state and queue before paginating
+	listScanCap = 1000
 )
 
+// ErrTaskExists is returned along with the task ID when a client-supplied task_id was already submitted.
+var ErrTaskExists = errors.New("task already submitted")
+
+// ResultMeta is the document a worker caches under ResultKeyPrefix once a task completes
+// (Celery-style). CreatedAt is zero for documents cached before it was recorded.
+type ResultMeta struct {
+	Status      string                   `json:"status"`
+	TaskID      string                   `json:"task_id"`
+	Result      *models.DNSLookupResults `json:"result"`
+	CreatedAt   time.Time                `json:"created_at"`
+	CompletedAt time.Time                `json:"completed_at"`
+}
+
+// PayloadCreatedAt reads the enqueue time stored in a task payload; zero if it has none.
+func PayloadCreatedAt(payload []byte) time.Time {
+	var p struct {
+		CreatedAt time.Time `json:"created_at"`
+	}
+	_ = json.Unmarshal(payload, &p)
+	return p.CreatedAt
+}
+
 // Client wraps Asynq for task enqueueing and result retrieval.
 type Client struct {
-	asynqClient *asynq.Client
+	asynqClient enqueuer
 	inspector   *asynq.Inspector
 	redisClient *redis.Client
 	resultTTL   time.Duration
+	maxRetry    int
+	retention   time.Duration
+}
+
+// enqueuer is the part of *asynq.Client used here, so tests can capture enqueue options.
+type enqueuer interface {
+	EnqueueContext(ctx context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error)
+	Close() error
+}
+
+// ConfigReloader is implemented by task clients that run lookups in-process,
+// so a config reload changes how their next lookups run.
+type ConfigReloader interface {
+	SetConfig(cfg *config.APIConfig)
 }
 
 // ClientInterface allows swapping between Asynq and memory implementations.
 type ClientInterface interface {
-	EnqueueDNSLookup(ctx context.Context, domain, qtype string, servers []models.DNSServer, tlsInsecure bool) (string, error)
+	EnqueueDNSLookup(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, task models.TaskOptions, queue string) (string, error)
 	GetTaskStatus(ctx context.Context, taskID string) (*models.TaskStatusResponse, error)
+	ListTasks(ctx context.Context, filter TaskFilter) (*models.TaskListResponse, error)
+	Ping(ctx context.Context) error
 	Close() error
 }
 
 // NewClient creates Asynq client with Redis result backend.
-func NewClient(redisAddr string) *Client {
+// Task retries and retention come from the worker config.
+func NewClient(redisAddr string, cfg *config.APIConfig) *Client {
 	redisOpts := asynq.RedisClientOpt{Addr: redisAddr}
 	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
 
@@ -44,20 +91,34 @@ func NewClient(redisAddr string) *Client {
 		inspector:   asynq.NewInspector(redisOpts),
 		redisClient: rdb,
 		resultTTL:   24 * time.Hour,
+		maxRetry:    cfg.GetTaskMaxRetry(),
+		retention:   cfg.GetTaskRetention(),
 	}
 }
 
-// EnqueueDNSLookup creates task with UUID, enqueues to Asynq with 3 retry max.
-func (c *Client) EnqueueDNSLookup(ctx context.Context, domain, qtype string, servers []models.DNSServer, tlsInsecure bool) (string, error) {
-	id := uuid.NewString()
+// EnqueueDNSLookup creates task with UUID, enqueues to Asynq with worker.task_max_retry
+// retries and worker.task_retention. Empty queue keeps Asynq's default queue.
+// A client-supplied task_id that is still queued, running or cached returns ErrTaskExists.
+func (c *Client) EnqueueDNSLookup(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, task models.TaskOptions, queue string) (string, error) {
+	id := task.TaskID
+	if id == "" {
+		id = uuid.NewString()
+	} else if c.resultCached(ctx, id) {
+		// Asynq drops completed tasks, so only the result cache remembers them
+		return id, ErrTaskExists
+	}
 
+	// tls_insecure is kept alongside options for workers that predate QueryOptions
 	payload := map[string]interface{}{
 		"task_id":      id,
 		"domain":       domain,
 		"qtype":        qtype,
 		"servers":      servers,
-		"tls_insecure": tlsInsecure,
-		"created_at":   time.Now().UTC().Format(time.RFC3339),
+		"options":      opts,
+		"tls_insecure": opts.TLSInsecureSkipVerify,
+		"trace":        tracing.Inject(ctx),
+		"callback_url": task.CallbackURL,
+		"created_at":   time.Now().UTC().Format(time.RFC3339Nano),
 	}
 
 	data, err := json.Marshal(payload)
@@ -65,20 +126,32 @@ func (c *Client) EnqueueDNSLookup(ctx context.Context, domain, qtype string, ser
 		return "", fmt.Errorf("marshal payload: %w", err)
 	}
 
-	task := asynq.NewTask(TaskTypeDNSLookup, data)
-	opts := []asynq.Option{
+	asynqTask := asynq.NewTask(TaskTypeDNSLookup, data)
+	taskOpts := []asynq.Option{
 		asynq.TaskID(id),
-		asynq.MaxRetry(3),
-		asynq.Retention(0),
+		asynq.MaxRetry(c.maxRetry),
+		asynq.Retention(c.retention),
+	}
+	if queue != "" {
+		taskOpts = append(taskOpts, asynq.Queue(queue))
 	}
 
-	if _, err := c.asynqClient.EnqueueContext(ctx, task, opts...); err != nil {
+	if _, err := c.asynqClient.EnqueueContext(ctx, asynqTask, taskOpts...); err != nil {
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			return id, ErrTaskExists
+		}
 		return "", fmt.Errorf("enqueue failed: %w", err)
 	}
 
 	return id, nil
 }
 
+// resultCached reports whether a completed result for taskID is in the cache; Redis errors count as no.
+func (c *Client) resultCached(ctx context.Context, taskID string) bool {
+	n, err := c.redisClient.Exists(ctx, ResultKeyPrefix+taskID).Result()
+	return err == nil && n > 0
+}
+
 // Close shuts down all connections.
 func (c *Client) Close() error {
 	var errs []error
@@ -98,6 +171,14 @@ func (c *Client) Close() error {
 	return errors.Join(errs...)
 }
 
+// Ping verifies the Redis connection is alive.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.redisClient.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping: %w", err)
+	}
+	return nil
+}
+
 // HasActiveWorkers checks Asynq inspector for connected workers.
 func (c *Client) HasActiveWorkers(_ context.Context) bool {
 	servers, err := c.inspector.Servers()
@@ -108,46 +189,61 @@ func (c *Client) HasActiveWorkers(_ context.Context) bool {
 	return len(servers) > 0
 }
 
+// QueueDepths returns the number of pending tasks in every known queue.
+func (c *Client) QueueDepths(_ context.Context) (map[string]int, error) {
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("list queues: %w", err)
+	}
+
+	depths := make(map[string]int, len(queues))
+	for _, q := range queues {
+		info, err := c.inspector.GetQueueInfo(q)
+		if err != nil {
+			return nil, fmt.Errorf("queue info %s: %w", q, err)
+		}
+		depths[q] = info.Pending
+	}
+	return depths, nil
+}
+
+// ActiveWorkers returns the number of worker servers connected to Redis.
+func (c *Client) ActiveWorkers(_ context.Context) (int, error) {
+	servers, err := c.inspector.Servers()
+	if err != nil {
+		return 0, fmt.Errorf("list servers: %w", err)
+	}
+	return len(servers), nil
+}
+
 // GetTaskStatus retrieves task status from Redis cache or Asynq inspector.
 // Uses simple Redis key (like Celery) for fast reads with 1 GET operation.
 func (c *Client) GetTaskStatus(ctx context.Context, taskID string) (*models.TaskStatusResponse, error) {
 	// Fast path: Check Redis cache first (Celery-style single key)
-	resultKey := fmt.Sprintf("dnstester:task-meta:%s", taskID)
+	resultKey := ResultKeyPrefix + taskID
 	data, err := c.redisClient.Get(ctx, resultKey).Result()
-
 	if err == nil {
-		var taskMeta struct {
-			Status      string                   `json:"status"`
-			Result      *models.DNSLookupResults `json:"result"`
-			TaskID      string                   `json:"task_id"`
-			CompletedAt time.Time                `json:"completed_at"`
-		}
-
-		if json.Unmarshal([]byte(data), &taskMeta) == nil && taskMeta.Status == "SUCCESS" {
-			return &models.TaskStatusResponse{
-				TaskID:      taskID,
-				Status:      "SUCCESS",
-				Result:      taskMeta.Result,
-				CompletedAt: taskMeta.CompletedAt,
-			}, nil
+		if status, ok := resultStatus(taskID, []byte(data)); ok {
+			return status, nil
 		}
 	}
 
 	// Slow path: Task not completed yet, check Asynq for status
-	taskInfo, err := c.inspector.GetTaskInfo("default", taskID)
+	taskInfo, err := c.findTaskInfo(taskID)
 	if err != nil {
 		return nil, fmt.Errorf("task not found: %w", err)
 	}
 
+	createdAt, completedAt := infoTimes(taskInfo)
 	response := &models.TaskStatusResponse{
 		TaskID:      taskID,
-		CreatedAt:   taskInfo.NextProcessAt,
-		CompletedAt: taskInfo.CompletedAt,
+		Status:      stateStatus(taskInfo.State),
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
 	}
 
 	switch taskInfo.State {
 	case asynq.TaskStateCompleted:
-		response.Status = "SUCCESS"
 		// Task just completed but cache not written yet, fallback to Asynq result
 		if len(taskInfo.Result) > 0 {
 			var res models.DNSLookupResults
@@ -155,20 +251,178 @@ func (c *Client) GetTaskStatus(ctx context.Context, taskID string) (*models.Task
 				response.Result = &res
 			}
 		}
-
-	case asynq.TaskStateActive:
-		response.Status = "ACTIVE"
-	case asynq.TaskStateRetry:
-		response.Status = "RETRY"
 	case asynq.TaskStateArchived:
-		response.Status = "FAILURE"
 		if taskInfo.LastErr != "" {
 			errMsg := taskInfo.LastErr
 			response.Error = &errMsg
 		}
-	default:
-		response.Status = "PENDING"
 	}
 
 	return response, nil
 }
+
+// resultStatus builds the status of a completed task from its cached ResultMeta.
+// It reports false when data is not a successful result.
+func resultStatus(taskID string, data []byte) (*models.TaskStatusResponse, bool) {
+	var meta ResultMeta
+	if json.Unmarshal(data, &meta) != nil || meta.Status != models.TaskStatusSuccess {
+		return nil, false
+	}
+	return &models.TaskStatusResponse{
+		TaskID:      taskID,
+		Status:      models.TaskStatusSuccess,
+		Result:      meta.Result,
+		CreatedAt:   meta.CreatedAt,
+		CompletedAt: meta.CompletedAt,
+	}, true
+}
+
+// infoTimes reports when an Asynq task was enqueued and when it finished, successfully or
+// not. The payload's created_at is preferred over NextProcessAt, which moves on each retry.
+func infoTimes(info *asynq.TaskInfo) (createdAt, completedAt time.Time) {
+	createdAt = PayloadCreatedAt(info.Payload)
+	if createdAt.IsZero() {
+		createdAt = info.NextProcessAt
+	}
+	completedAt = info.CompletedAt
+	if info.State == asynq.TaskStateArchived {
+		completedAt = info.LastFailedAt
+	}
+	return createdAt, completedAt
+}
+
+// stateStatus maps Asynq task states to the API status vocabulary.
+func stateStatus(state asynq.TaskState) string {
+	switch state {
+	case asynq.TaskStateCompleted:
+		return models.TaskStatusSuccess
+	case asynq.TaskStateActive:
+		return models.TaskStatusActive
+	case asynq.TaskStateRetry:
+		return models.TaskStatusRetry
+	case asynq.TaskStateArchived:
+		return models.TaskStatusFailure
+	default:
+		return models.TaskStatusPending
+	}
+}
+
+// taskLister is the signature shared by the inspector's List*Tasks methods.
+type taskLister func(queue string, opts ...asynq.ListOption) ([]*asynq.TaskInfo, error)
+
+// ListTasks lists queued, active, retrying and archived tasks from the inspector
+// plus completed ones from the result cache, which covers every task whatever its retention.
+// At most listScanCap tasks per state and queue are considered.
+func (c *Client) ListTasks(ctx context.Context, filter TaskFilter) (*models.TaskListResponse, error) {
+	if err := filter.normalize(); err != nil {
+		return nil, err
+	}
+
+	queues, err := c.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("list queues: %w", err)
+	}
+
+	listers := map[string][]taskLister{
+		models.TaskStatusPending: {c.inspector.ListPendingTasks, c.inspector.ListScheduledTasks},
+		models.TaskStatusActive:  {c.inspector.ListActiveTasks},
+		models.TaskStatusRetry:   {c.inspector.ListRetryTasks},
+		models.TaskStatusFailure: {c.inspector.ListArchivedTasks},
+	}
+
+	var items []models.TaskSummary
+	for status, fns := range listers {
+		if filter.Status != "" && filter.Status != status {
+			continue
+		}
+		for _, q := range queues {
+			for _, list := range fns {
+				infos, err := list(q, asynq.PageSize(listScanCap))
+				if err != nil {
+					return nil, fmt.Errorf("list %s tasks in queue %s: %w", status, q, err)
+				}
+				for _, info := range infos {
+					createdAt, completedAt := infoTimes(info)
+					items = append(items, models.TaskSummary{
+						TaskID:      info.ID,
+						Status:      stateStatus(info.State),
+						CreatedAt:   createdAt,
+						CompletedAt: completedAt,
+					})
+				}
+			}
+		}
+	}
+
+	if filter.Status == "" || filter.Status == models.TaskStatusSuccess {
+		completed, err := c.completedTasks(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, completed...)
+	}
+
+	return paginate(items, filter)
+}
+
+// completedTasks reads up to listScanCap cached results written by workers.
+func (c *Client) completedTasks(ctx context.Context) ([]models.TaskSummary, error) {
+	var keys []string
+	iter := c.redisClient.Scan(ctx, 0, ResultKeyPrefix+"*", 100).Iterator()
+	for iter.Next(ctx) && len(keys) < listScanCap {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("scan results: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	values, err := c.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read results: %w", err)
+	}
+
+	items := make([]models.TaskSummary, 0, len(keys))
+	for i, key := range keys {
+		raw, ok := values[i].(string)
+		if !ok {
+			continue // expired between SCAN and MGET
+		}
+		var meta struct {
+			CreatedAt   time.Time `json:"created_at"`
+			CompletedAt time.Time `json:"completed_at"`
+		}
+		_ = json.Unmarshal([]byte(raw), &meta)
+		if meta.CreatedAt.IsZero() {
+			// Cached before created_at was recorded; completion is the closest for ordering
+			meta.CreatedAt = meta.CompletedAt
+		}
+		items = append(items, models.TaskSummary{
+			TaskID:      strings.TrimPrefix(key, ResultKeyPrefix),
+			Status:      models.TaskStatusSuccess,
+			CreatedAt:   meta.CreatedAt,
+			CompletedAt: meta.CompletedAt,
+		})
+	}
+	return items, nil
+}
+
+// findTaskInfo looks the task up in every known queue since requests may be routed via X-Queue.
+func (c *Client) findTaskInfo(taskID string) (*asynq.TaskInfo, error) {
+	queues, err := c.inspector.Queues()
+	if err != nil || len(queues) == 0 {
+		queues = []string{"default"}
+	}
+
+	var lastErr error
+	for _, q := range queues {
+		info, err := c.inspector.GetTaskInfo(q, taskID)
+		if err == nil {
+			return info, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,334 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/hibiken/asynq"
+	"github.com/redis/go-redis/v9"
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// fakeEnqueuer records the options of every EnqueueContext call and, like Asynq,
+// rejects a task ID it has already seen.
+type fakeEnqueuer struct {
+	opts     [][]asynq.Option
+	payloads [][]byte
+	ids      map[string]bool
+}
+
+func (f *fakeEnqueuer) EnqueueContext(_ context.Context, task *asynq.Task, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	f.opts = append(f.opts, opts)
+	f.payloads = append(f.payloads, task.Payload())
+	if id, ok := optionValue(opts, asynq.TaskIDOpt); ok {
+		if f.ids == nil {
+			f.ids = make(map[string]bool)
+		}
+		if f.ids[id.(string)] {
+			return nil, asynq.ErrTaskIDConflict
+		}
+		f.ids[id.(string)] = true
+	}
+	return &asynq.TaskInfo{Type: task.Type()}, nil
+}
+
+func (f *fakeEnqueuer) Close() error { return nil }
+
+// optionValue returns the value of the first option of type typ.
+func optionValue(opts []asynq.Option, typ asynq.OptionType) (interface{}, bool) {
+	for _, o := range opts {
+		if o.Type() == typ {
+			return o.Value(), true
+		}
+	}
+	return nil, false
+}
+
+func TestEnqueueDNSLookupTaskOptions(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		name          string
+		worker        config.WorkerConfig
+		wantRetry     int
+		wantRetention time.Duration
+	}{
+		{"defaults", config.WorkerConfig{}, config.DefaultTaskMaxRetry, 0},
+		{"configured", config.WorkerConfig{TaskMaxRetry: &zero, TaskRetention: 3600}, 0, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.APIConfig{Worker: tt.worker}
+			fake := &fakeEnqueuer{}
+			c := &Client{asynqClient: fake, maxRetry: cfg.GetTaskMaxRetry(), retention: cfg.GetTaskRetention()}
+
+			if _, err := c.EnqueueDNSLookup(context.Background(), "example.com", "A", []models.DNSServer{{Target: "udp://9.9.9.9:53"}}, models.QueryOptions{}, models.TaskOptions{}, ""); err != nil {
+				t.Fatalf("EnqueueDNSLookup failed: %v", err)
+			}
+			if len(fake.opts) != 1 {
+				t.Fatalf("Expected 1 enqueue, got %d", len(fake.opts))
+			}
+			if got, ok := optionValue(fake.opts[0], asynq.MaxRetryOpt); !ok || got != tt.wantRetry {
+				t.Errorf("Expected MaxRetry(%d), got %v", tt.wantRetry, got)
+			}
+			if got, ok := optionValue(fake.opts[0], asynq.RetentionOpt); !ok || got != tt.wantRetention {
+				t.Errorf("Expected Retention(%s), got %v", tt.wantRetention, got)
+			}
+		})
+	}
+}
+
+func TestEnqueueDNSLookupTaskIDDedup(t *testing.T) {
+	fake := &fakeEnqueuer{}
+	// Nothing listens on port 1, so the result cache lookup misses
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	t.Cleanup(func() { _ = rdb.Close() })
+	c := &Client{asynqClient: fake, redisClient: rdb, maxRetry: config.DefaultTaskMaxRetry}
+
+	task := models.TaskOptions{TaskID: "nightly-example-com"}
+	servers := []models.DNSServer{{Target: "udp://9.9.9.9:53"}}
+	first, err := c.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, task, "")
+	if err != nil || first != task.TaskID {
+		t.Fatalf("Expected task %s, got %q (%v)", task.TaskID, first, err)
+	}
+	second, err := c.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, task, "")
+	if !errors.Is(err, ErrTaskExists) || second != first {
+		t.Fatalf("Expected ErrTaskExists for %s, got %q (%v)", first, second, err)
+	}
+	if len(fake.ids) != 1 {
+		t.Errorf("Expected one task in the queue, got %v", fake.ids)
+	}
+}
+
+func TestEnqueueDNSLookupCreatedAt(t *testing.T) {
+	fake := &fakeEnqueuer{}
+	c := &Client{asynqClient: fake, maxRetry: config.DefaultTaskMaxRetry}
+
+	before := time.Now()
+	if _, err := c.EnqueueDNSLookup(context.Background(), "example.com", "A", []models.DNSServer{{Target: "udp://9.9.9.9:53"}}, models.QueryOptions{}, models.TaskOptions{}, ""); err != nil {
+		t.Fatalf("EnqueueDNSLookup failed: %v", err)
+	}
+	if got := PayloadCreatedAt(fake.payloads[0]); got.Before(before) || got.After(time.Now()) {
+		t.Errorf("Expected the payload created_at to be the enqueue time, got %s", got)
+	}
+}
+
+func TestResultStatus(t *testing.T) {
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	completedAt := createdAt.Add(2 * time.Second)
+	data, _ := json.Marshal(ResultMeta{
+		Status:      models.TaskStatusSuccess,
+		TaskID:      "cached",
+		Result:      &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{}},
+		CreatedAt:   createdAt,
+		CompletedAt: completedAt,
+	})
+
+	status, ok := resultStatus("cached", data)
+	if !ok {
+		t.Fatal("Expected a cached success")
+	}
+	if status.Status != models.TaskStatusSuccess || status.Result == nil {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if !status.CreatedAt.Equal(createdAt) || !status.CompletedAt.Equal(completedAt) {
+		t.Errorf("Expected created %s / completed %s, got %s / %s", createdAt, completedAt, status.CreatedAt, status.CompletedAt)
+	}
+
+	// Documents cached by older workers have no created_at but keep completed_at
+	status, ok = resultStatus("old", []byte(`{"status":"SUCCESS","task_id":"old","result":{"details":{},"duration":0.1},"completed_at":"2025-01-01T12:00:02Z"}`))
+	if !ok || status.CompletedAt.IsZero() || !status.CreatedAt.IsZero() {
+		t.Errorf("Expected a non-zero completed_at only, got %+v", status)
+	}
+
+	if _, ok := resultStatus("bad", []byte(`{"status":"PENDING"}`)); ok {
+		t.Error("Expected a non-success document to be ignored")
+	}
+}
+
+func TestInfoTimes(t *testing.T) {
+	createdAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	payload, _ := json.Marshal(map[string]string{"created_at": createdAt.Format(time.RFC3339Nano)})
+	retryAt := createdAt.Add(time.Minute)
+	failedAt := createdAt.Add(30 * time.Second)
+
+	tests := []struct {
+		name          string
+		info          asynq.TaskInfo
+		wantCreated   time.Time
+		wantCompleted time.Time
+	}{
+		{"retrying", asynq.TaskInfo{State: asynq.TaskStateRetry, Payload: payload, NextProcessAt: retryAt}, createdAt, time.Time{}},
+		{"completed", asynq.TaskInfo{State: asynq.TaskStateCompleted, Payload: payload, CompletedAt: failedAt}, createdAt, failedAt},
+		{"archived", asynq.TaskInfo{State: asynq.TaskStateArchived, Payload: payload, LastFailedAt: failedAt}, createdAt, failedAt},
+		{"payload without created_at", asynq.TaskInfo{State: asynq.TaskStatePending, Payload: []byte(`{}`), NextProcessAt: retryAt}, retryAt, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, completed := infoTimes(&tt.info)
+			if !created.Equal(tt.wantCreated) || !completed.Equal(tt.wantCompleted) {
+				t.Errorf("infoTimes() = %s / %s, want %s / %s", created, completed, tt.wantCreated, tt.wantCompleted)
+			}
+		})
+	}
+}
+
+// newRedisTestClient starts an in-memory Redis and a Client on it, closed with the test.
+func newRedisTestClient(t *testing.T, cfg *config.APIConfig) (*Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	c := NewClient(mr.Addr(), cfg)
+	t.Cleanup(func() { _ = c.Close() })
+	return c, mr
+}
+
+// seedResult caches a completed task the way the worker does, with its 24h TTL.
+func seedResult(t *testing.T, mr *miniredis.Miniredis, meta ResultMeta) {
+	t.Helper()
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := ResultKeyPrefix + meta.TaskID
+	if err := mr.Set(key, string(data)); err != nil {
+		t.Fatal(err)
+	}
+	mr.SetTTL(key, 24*time.Hour)
+}
+
+// startTestWorker registers an Asynq server on mr, as a connected worker would, until the test ends.
+func startTestWorker(t *testing.T, mr *miniredis.Miniredis) {
+	t.Helper()
+	srv := asynq.NewServer(asynq.RedisClientOpt{Addr: mr.Addr()}, asynq.Config{Concurrency: 1, LogLevel: asynq.FatalLevel})
+	if err := srv.Start(asynq.HandlerFunc(func(context.Context, *asynq.Task) error { return nil })); err != nil {
+		t.Fatalf("start worker: %v", err)
+	}
+	t.Cleanup(srv.Shutdown)
+}
+
+func TestGetTaskStatusServesWorkerDocument(t *testing.T) {
+	// The document exactly as the worker caches it on completion
+	const doc = `{"status":"SUCCESS","task_id":"cached-task","result":{"details":{"udp://9.9.9.9:53":{"command_status":"ok","time_ms":12.5,"rcode":"NOERROR","answers":[{"name":"example.com.","type":"A","ttl":60,"value":"192.0.2.1"}],"dns_protocol":"udp"}},"duration":0.0125},"created_at":"2025-01-01T12:00:00Z","completed_at":"2025-01-01T12:00:01Z"}`
+	c, mr := newRedisTestClient(t, &config.APIConfig{})
+	if err := mr.Set("dnstester:task-meta:cached-task", doc); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := c.GetTaskStatus(context.Background(), "cached-task")
+	if err != nil {
+		t.Fatalf("GetTaskStatus failed: %v", err)
+	}
+	if status.Status != models.TaskStatusSuccess || status.TaskID != "cached-task" {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if status.Result == nil || status.Result.Duration != 0.0125 {
+		t.Fatalf("Expected the cached result, got %+v", status.Result)
+	}
+	result, ok := status.Result.Details["udp://9.9.9.9:53"]
+	if !ok || result.CommandStatus != "ok" || len(result.Answers) != 1 || result.Answers[0].Value != "192.0.2.1" {
+		t.Errorf("Unexpected cached details: %+v", status.Result.Details)
+	}
+	if want := time.Date(2025, 1, 1, 12, 0, 1, 0, time.UTC); !status.CompletedAt.Equal(want) {
+		t.Errorf("Expected completed_at %s, got %s", want, status.CompletedAt)
+	}
+	if want := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC); !status.CreatedAt.Equal(want) {
+		t.Errorf("Expected created_at %s, got %s", want, status.CreatedAt)
+	}
+}
+
+func TestClientEnqueueAndStatus(t *testing.T) {
+	c, _ := newRedisTestClient(t, &config.APIConfig{})
+	ctx := context.Background()
+	servers := []models.DNSServer{{Target: "udp://9.9.9.9:53"}}
+
+	id, err := c.EnqueueDNSLookup(ctx, "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{TaskID: "queued-task"}, "")
+	if err != nil || id != "queued-task" {
+		t.Fatalf("EnqueueDNSLookup = %q, %v", id, err)
+	}
+	if _, err := c.EnqueueDNSLookup(ctx, "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{TaskID: id}, ""); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("Expected ErrTaskExists for a queued task_id, got %v", err)
+	}
+
+	// Not cached yet, so the status comes from the inspector
+	status, err := c.GetTaskStatus(ctx, id)
+	if err != nil {
+		t.Fatalf("GetTaskStatus failed: %v", err)
+	}
+	if status.Status != models.TaskStatusPending || status.CreatedAt.IsZero() || status.Result != nil {
+		t.Errorf("Expected a PENDING task with created_at, got %+v", status)
+	}
+
+	depths, err := c.QueueDepths(ctx)
+	if err != nil || depths["default"] != 1 {
+		t.Errorf("Expected 1 pending task in the default queue, got %v (%v)", depths, err)
+	}
+	list, err := c.ListTasks(ctx, TaskFilter{Status: models.TaskStatusPending})
+	if err != nil || len(list.Tasks) != 1 || list.Tasks[0].TaskID != id {
+		t.Errorf("Expected %s in the PENDING list, got %+v (%v)", id, list, err)
+	}
+
+	if _, err := c.GetTaskStatus(ctx, "unknown-task"); err == nil {
+		t.Error("Expected an error for an unknown task")
+	}
+}
+
+func TestClientResultCache(t *testing.T) {
+	c, mr := newRedisTestClient(t, &config.APIConfig{})
+	ctx := context.Background()
+	completedAt := time.Now().UTC().Truncate(time.Second)
+	seedResult(t, mr, ResultMeta{
+		Status:      models.TaskStatusSuccess,
+		TaskID:      "done-task",
+		Result:      &models.DNSLookupResults{Details: map[string]models.DNSLookupResult{}, Duration: 0.5},
+		CreatedAt:   completedAt.Add(-time.Second),
+		CompletedAt: completedAt,
+	})
+
+	status, err := c.GetTaskStatus(ctx, "done-task")
+	if err != nil {
+		t.Fatalf("GetTaskStatus failed: %v", err)
+	}
+	if status.Status != models.TaskStatusSuccess || status.Result == nil || !status.CompletedAt.Equal(completedAt) {
+		t.Errorf("Expected the cached SUCCESS, got %+v", status)
+	}
+
+	// A cached result still blocks resubmitting its task_id
+	if _, err := c.EnqueueDNSLookup(ctx, "example.com", "A", nil, models.QueryOptions{}, models.TaskOptions{TaskID: "done-task"}, ""); !errors.Is(err, ErrTaskExists) {
+		t.Errorf("Expected ErrTaskExists for a cached task_id, got %v", err)
+	}
+	list, err := c.ListTasks(ctx, TaskFilter{Status: models.TaskStatusSuccess})
+	if err != nil || len(list.Tasks) != 1 || list.Tasks[0].TaskID != "done-task" {
+		t.Errorf("Expected done-task in the SUCCESS list, got %+v (%v)", list, err)
+	}
+
+	// Once the 24h TTL runs out the result is gone, and so is the task
+	mr.FastForward(25 * time.Hour)
+	if _, err := c.GetTaskStatus(ctx, "done-task"); err == nil {
+		t.Error("Expected the expired result to be gone")
+	}
+}
+
+func TestClientHasActiveWorkers(t *testing.T) {
+	c, mr := newRedisTestClient(t, &config.APIConfig{})
+	ctx := context.Background()
+
+	if c.HasActiveWorkers(ctx) {
+		t.Fatal("Expected no active workers before one connects")
+	}
+
+	startTestWorker(t, mr)
+	deadline := time.Now().Add(5 * time.Second)
+	for !c.HasActiveWorkers(ctx) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the worker to show up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n, err := c.ActiveWorkers(ctx); err != nil || n != 1 {
+		t.Errorf("Expected 1 active worker, got %d (%v)", n, err)
+	}
+}
@@ -0,0 +1,97 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC of the body>" when callbacks.secret is set.
+const SignatureHeader = "X-DNSTester-Signature"
+
+// CallbackSender POSTs completed task statuses to client-supplied callback URLs.
+type CallbackSender struct {
+	Client   *http.Client
+	Secret   string
+	Attempts int
+	Backoff  time.Duration
+}
+
+// NewCallbackSender builds a sender from the callbacks config: 3 attempts, 1s doubling backoff.
+func NewCallbackSender(cfg *config.APIConfig) *CallbackSender {
+	return &CallbackSender{
+		Client:   &http.Client{Timeout: time.Duration(cfg.GetCallbackTimeout()) * time.Second},
+		Secret:   cfg.Callbacks.Secret,
+		Attempts: 3,
+		Backoff:  time.Second,
+	}
+}
+
+// Send delivers status to url, retrying on network errors, 429 and 5xx responses.
+func (c *CallbackSender) Send(ctx context.Context, url string, status *models.TaskStatusResponse) error {
+	body, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("marshal callback body: %w", err)
+	}
+
+	backoff := c.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= c.Attempts; attempt++ {
+		retry, err := c.post(ctx, url, body)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || attempt == c.Attempts {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	return fmt.Errorf("callback to %s failed: %w", url, lastErr)
+}
+
+// post makes one delivery attempt and reports whether a failure is worth retrying.
+func (c *CallbackSender) post(ctx context.Context, url string, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(c.Secret, body))
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+}
+
+// Sign returns the hex HMAC-SHA256 of body, as sent in SignatureHeader.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
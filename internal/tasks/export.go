@@ -0,0 +1,82 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+// ExportRecord is one line of a result export file.
+type ExportRecord struct {
+	TaskID      string                            `json:"task_id"`
+	Domain      string                            `json:"domain"`
+	QType       string                            `json:"qtype"`
+	CompletedAt time.Time                         `json:"completed_at"`
+	Duration    float64                           `json:"duration"`
+	Details     map[string]models.DNSLookupResult `json:"details"`
+}
+
+// ResultExporter appends completed task results as NDJSON to one file per UTC day in Dir,
+// named results-YYYY-MM-DD.ndjson, so files rotate daily and old ones can be pruned.
+// Each record is a single O_APPEND write, so workers sharing Dir do not interleave lines.
+type ResultExporter struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewResultExporter creates dir if needed and checks that it is writable.
+func NewResultExporter(dir string) (*ResultExporter, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("export dir: %w", err)
+	}
+	probe, err := os.CreateTemp(dir, ".probe-*")
+	if err != nil {
+		return nil, fmt.Errorf("export dir %s is not writable: %w", dir, err)
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	return &ResultExporter{Dir: dir}, nil
+}
+
+// Path returns the export file records completed at t go to.
+func (e *ResultExporter) Path(t time.Time) string {
+	return filepath.Join(e.Dir, "results-"+t.UTC().Format(time.DateOnly)+".ndjson")
+}
+
+// Export appends the result of a completed task.
+func (e *ResultExporter) Export(domain, qtype string, status *models.TaskStatusResponse) error {
+	record := ExportRecord{
+		TaskID:      status.TaskID,
+		Domain:      domain,
+		QType:       qtype,
+		CompletedAt: status.CompletedAt,
+	}
+	if status.Result != nil {
+		record.Duration = status.Result.Duration
+		record.Details = status.Result.Details
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal export record: %w", err)
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// Reopened per record so a file moved away by logrotate is recreated
+	f, err := os.OpenFile(e.Path(status.CompletedAt), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("open export file: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write export file: %w", err)
+	}
+	return f.Close()
+}
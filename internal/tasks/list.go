@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+const (
+	// DefaultListLimit is the page size when the caller does not set one
+	DefaultListLimit = 50
+	// MaxListLimit caps the page size
+	MaxListLimit = 500
+)
+
+// ListStatuses are the values accepted by TaskFilter.Status.
+var ListStatuses = models.TaskStatuses
+
+// ErrInvalidFilter is returned for an unknown status, a bad limit or a malformed cursor.
+var ErrInvalidFilter = errors.New("invalid task filter")
+
+// TaskFilter selects and paginates tasks for ListTasks.
+// Cursor is the opaque next_cursor of a previous page; empty starts from the newest task.
+type TaskFilter struct {
+	Status string
+	Cursor string
+	Limit  int
+}
+
+// normalize uppercases the status and applies the default and maximum limit.
+func (f *TaskFilter) normalize() error {
+	f.Status = strings.ToUpper(strings.TrimSpace(f.Status))
+	if f.Status != "" && !slices.Contains(ListStatuses, f.Status) {
+		return fmt.Errorf("%w: unknown status '%s' (must be one of %s)", ErrInvalidFilter, f.Status, strings.Join(ListStatuses, ", "))
+	}
+	if f.Limit < 0 {
+		return fmt.Errorf("%w: limit must be positive", ErrInvalidFilter)
+	}
+	if f.Limit == 0 {
+		f.Limit = DefaultListLimit
+	}
+	if f.Limit > MaxListLimit {
+		f.Limit = MaxListLimit
+	}
+	return nil
+}
+
+// paginate filters by status, orders newest first and cuts the page at the cursor offset.
+func paginate(items []models.TaskSummary, filter TaskFilter) (*models.TaskListResponse, error) {
+	if err := filter.normalize(); err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	if filter.Cursor != "" {
+		n, err := strconv.Atoi(filter.Cursor)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("%w: malformed cursor '%s'", ErrInvalidFilter, filter.Cursor)
+		}
+		offset = n
+	}
+
+	matched := make([]models.TaskSummary, 0, len(items))
+	for _, item := range items {
+		if filter.Status == "" || item.Status == filter.Status {
+			matched = append(matched, item)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		if !matched[i].CreatedAt.Equal(matched[j].CreatedAt) {
+			return matched[i].CreatedAt.After(matched[j].CreatedAt)
+		}
+		return matched[i].TaskID < matched[j].TaskID
+	})
+
+	resp := &models.TaskListResponse{Tasks: []models.TaskSummary{}}
+	if offset >= len(matched) {
+		return resp, nil
+	}
+	end := min(offset+filter.Limit, len(matched))
+	resp.Tasks = matched[offset:end]
+	if end < len(matched) {
+		resp.NextCursor = strconv.Itoa(end)
+	}
+	return resp, nil
+}
@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+)
+
+func TestPaginate(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var items []models.TaskSummary
+	for i := 0; i < 5; i++ {
+		status := "SUCCESS"
+		if i%2 == 0 {
+			status = "PENDING"
+		}
+		items = append(items, models.TaskSummary{TaskID: string(rune('a' + i)), Status: status, CreatedAt: base.Add(time.Duration(i) * time.Minute)})
+	}
+
+	page, err := paginate(items, TaskFilter{Limit: 2})
+	if err != nil {
+		t.Fatalf("paginate failed: %v", err)
+	}
+	if got := ids(page.Tasks); got != "ed" || page.NextCursor != "2" {
+		t.Errorf("First page = %s (cursor %q), want ed (cursor \"2\")", got, page.NextCursor)
+	}
+
+	page, err = paginate(items, TaskFilter{Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatalf("paginate failed: %v", err)
+	}
+	if got := ids(page.Tasks); got != "cb" || page.NextCursor != "4" {
+		t.Errorf("Second page = %s (cursor %q), want cb (cursor \"4\")", got, page.NextCursor)
+	}
+
+	page, err = paginate(items, TaskFilter{Status: "pending"})
+	if err != nil {
+		t.Fatalf("paginate failed: %v", err)
+	}
+	if got := ids(page.Tasks); got != "eca" || page.NextCursor != "" {
+		t.Errorf("Pending filter = %s (cursor %q), want eca", got, page.NextCursor)
+	}
+}
+
+func TestPaginateInvalidFilter(t *testing.T) {
+	for _, filter := range []TaskFilter{
+		{Status: "DONE"},
+		{Limit: -1},
+		{Cursor: "abc"},
+	} {
+		if _, err := paginate(nil, filter); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("paginate(%+v) error = %v, want ErrInvalidFilter", filter, err)
+		}
+	}
+}
+
+func TestStateStatus(t *testing.T) {
+	tests := map[asynq.TaskState]string{
+		asynq.TaskStatePending:     models.TaskStatusPending,
+		asynq.TaskStateScheduled:   models.TaskStatusPending,
+		asynq.TaskStateAggregating: models.TaskStatusPending,
+		asynq.TaskStateActive:      models.TaskStatusActive,
+		asynq.TaskStateRetry:       models.TaskStatusRetry,
+		asynq.TaskStateArchived:    models.TaskStatusFailure,
+		asynq.TaskStateCompleted:   models.TaskStatusSuccess,
+	}
+	for state, want := range tests {
+		if got := stateStatus(state); got != want {
+			t.Errorf("stateStatus(%v) = %s, want %s", state, got, want)
+		}
+	}
+
+	// Whatever the state, even one added by a future Asynq, only canonical statuses come out
+	for state := asynq.TaskState(0); state <= asynq.TaskStateAggregating+1; state++ {
+		if got := stateStatus(state); !slices.Contains(models.TaskStatuses, got) {
+			t.Errorf("stateStatus(%v) = %q, not a canonical status", state, got)
+		}
+	}
+}
+
+func ids(tasks []models.TaskSummary) string {
+	s := ""
+	for _, task := range tasks {
+		s += task.TaskID
+	}
+	return s
+}
@@ -3,56 +3,124 @@ package tasks
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/metrics"
 	"github.com/sudo-tiz/dns-tester-go/internal/models"
 	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+	"github.com/sudo-tiz/dns-tester-go/internal/tracing"
 )
 
 type memoryClient struct {
 	mu                   sync.Mutex
 	tasks                map[string]*models.DNSLookupResults
 	ttl                  map[string]time.Time
+	created              map[string]time.Time
+	deadline             map[string]time.Time
 	timeout              time.Duration
+	taskTimeout          time.Duration
 	maxConcurrentQueries int
 	maxRetries           int
+	callbacks            *CallbackSender
+	workers              chan struct{}
+	queued               int
+	resolver             resolver.Resolver
+	ctx                  context.Context
+	cancel               context.CancelFunc
 }
 
 // NewMemoryClient creates in-memory task queue for dev/testing without Redis.
-// Uses background context for queries to avoid HTTP timeout coupling.
+// Queries and callbacks run under the client's own context rather than the HTTP request's,
+// so they outlive the request but are cancelled by Close on shutdown, as are queued tasks.
 // Returns ClientInterface for consistent API with Asynq implementation.
+// At most worker.max_workers tasks run at once, like the Asynq worker's concurrency.
 func NewMemoryClient(cfg *config.APIConfig) ClientInterface {
-	timeout := time.Duration(cfg.GetDNSTimeout()) * time.Second
-	return &memoryClient{
-		tasks:                make(map[string]*models.DNSLookupResults),
-		ttl:                  make(map[string]time.Time),
-		timeout:              timeout,
-		maxConcurrentQueries: cfg.GetMaxConcurrentQueries(),
-		maxRetries:           cfg.GetMaxRetries(),
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &memoryClient{
+		tasks:    make(map[string]*models.DNSLookupResults),
+		ttl:      make(map[string]time.Time),
+		created:  make(map[string]time.Time),
+		deadline: make(map[string]time.Time),
+		workers:  make(chan struct{}, cfg.GetMaxWorkers()),
+		resolver: resolver.Default,
+		ctx:      ctx,
+		cancel:   cancel,
 	}
+	m.SetConfig(cfg)
+	return m
+}
+
+// SetConfig applies a reloaded config to tasks that have not started yet.
+// worker.max_workers is fixed when the client is created.
+func (m *memoryClient) SetConfig(cfg *config.APIConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = time.Duration(cfg.GetDNSTimeout()) * time.Second
+	m.taskTimeout = cfg.GetTaskTimeout()
+	m.maxConcurrentQueries = cfg.GetMaxConcurrentQueries()
+	m.maxRetries = cfg.GetMaxRetries()
+	m.callbacks = NewCallbackSender(cfg)
 }
 
 // EnqueueDNSLookup executes DNS query in background goroutine.
 // Pragmatic choice: decouple from HTTP request context to avoid premature cancellation.
-func (m *memoryClient) EnqueueDNSLookup(_ context.Context, domain, qtype string, servers []models.DNSServer, tlsInsecure bool) (string, error) {
-	id := "mem-" + time.Now().Format("20060102150405.000000000")
+// Queue routing only matters for Asynq workers, so it is ignored here.
+// A client-supplied task_id seen within the last hour returns ErrTaskExists.
+// The task waits for a free worker slot; its deadline starts once it runs.
+func (m *memoryClient) EnqueueDNSLookup(ctx context.Context, domain, qtype string, servers []models.DNSServer, opts models.QueryOptions, task models.TaskOptions, _ string) (string, error) {
+	id := task.TaskID
+	if id == "" {
+		id = uuid.NewString()
+	}
 
 	m.mu.Lock()
+	if expires, exists := m.ttl[id]; exists && time.Now().Before(expires) {
+		m.mu.Unlock()
+		return id, ErrTaskExists
+	}
 	m.tasks[id] = nil
 	m.ttl[id] = time.Now().Add(1 * time.Hour)
+	m.created[id] = time.Now().UTC()
+	m.queued++
 	m.mu.Unlock()
 
-	// Use independent context - HTTP request may timeout before query completes
+	// Use the client context - HTTP request may timeout before query completes.
+	// Only the trace parent is carried over so the task span joins the request trace.
+	parent := tracing.Detach(m.ctx, ctx)
 	go func() {
-		taskCtx := context.Background()
+		select {
+		case m.workers <- struct{}{}:
+		case <-m.ctx.Done():
+			return
+		}
+		defer func() { <-m.workers }()
+		if m.ctx.Err() != nil {
+			// Close raced with a slot freeing up
+			return
+		}
+
+		m.mu.Lock()
+		m.queued--
+		taskTimeout := m.taskDuration(len(servers))
+		m.deadline[id] = time.Now().Add(taskTimeout + 5*time.Second)
+		dnsTimeout, maxConcurrentQueries, maxRetries, callbacks := m.timeout, m.maxConcurrentQueries, m.maxRetries, m.callbacks
+		m.mu.Unlock()
+
+		taskCtx, cancel := context.WithTimeout(parent, taskTimeout)
+		defer cancel()
+		taskCtx, span := tracing.Tracer().Start(taskCtx, "dns.task")
+		defer span.End()
 		start := time.Now()
 		results := make(map[string]models.DNSLookupResult)
 		if len(servers) > 0 {
-			results = resolver.RunQueries(taskCtx, domain, qtype, servers, tlsInsecure, m.timeout, m.maxConcurrentQueries, m.maxRetries)
+			results = m.resolver.RunQueries(taskCtx, domain, qtype, servers, opts, dnsTimeout, maxConcurrentQueries, maxRetries)
 		}
 		duration := time.Since(start).Seconds()
+		metrics.RecordLookupResults(results)
 
 		lookupResults := &models.DNSLookupResults{
 			Details:  results,
@@ -62,16 +130,57 @@ func (m *memoryClient) EnqueueDNSLookup(_ context.Context, domain, qtype string,
 		m.mu.Lock()
 		m.tasks[id] = lookupResults
 		m.mu.Unlock()
+
+		// The callback outlives the query deadline but not Close
+		if task.CallbackURL != "" {
+			status := &models.TaskStatusResponse{TaskID: id, Status: models.TaskStatusSuccess, Result: lookupResults, CompletedAt: time.Now().UTC()}
+			if err := callbacks.Send(parent, task.CallbackURL, status); err != nil {
+				slog.Warn("Task callback failed", "task_id", id, "error", err)
+			}
+		}
 	}()
 
 	return id, nil
 }
 
+// taskDuration is how long a task may query, m.mu must be held: dns.task_timeout, or less when every query
+// batch would exhaust its retries sooner. Its deadline adds slack for the partial results
+// to be stored; past that a still-running task is reported as FAILURE instead of PENDING forever.
+func (m *memoryClient) taskDuration(servers int) time.Duration {
+	batches := 1
+	if m.maxConcurrentQueries > 0 {
+		batches = (servers + m.maxConcurrentQueries - 1) / m.maxConcurrentQueries
+	}
+	if batches < 1 {
+		batches = 1
+	}
+	return min(m.taskTimeout, time.Duration(batches*(m.maxRetries+1))*m.timeout)
+}
+
+// QueueDepths reports the tasks waiting for a free worker slot as the default queue.
+func (m *memoryClient) QueueDepths(_ context.Context) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return map[string]int{"default": m.queued}, nil
+}
+
+// ActiveWorkers reports the in-process executor as a single worker.
+func (m *memoryClient) ActiveWorkers(_ context.Context) (int, error) {
+	return 1, nil
+}
+
+// Ping always succeeds; the memory client has no external dependency.
+func (m *memoryClient) Ping(_ context.Context) error {
+	return nil
+}
+
+// Close cancels in-flight queries so they do not outlive graceful shutdown.
 func (m *memoryClient) Close() error {
+	m.cancel()
 	return nil
 }
 
-// GetTaskStatus returns PENDING while executing, SUCCESS when done.
+// GetTaskStatus returns PENDING while queued or executing, SUCCESS when done, FAILURE once past the deadline.
 func (m *memoryClient) GetTaskStatus(_ context.Context, taskID string) (*models.TaskStatusResponse, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -81,18 +190,54 @@ func (m *memoryClient) GetTaskStatus(_ context.Context, taskID string) (*models.
 		return nil, fmt.Errorf("not found")
 	}
 
+	return m.statusLocked(taskID), nil
+}
+
+// ListTasks pages through the tasks held in memory, newest first.
+func (m *memoryClient) ListTasks(_ context.Context, filter TaskFilter) (*models.TaskListResponse, error) {
+	m.mu.Lock()
+	items := make([]models.TaskSummary, 0, len(m.ttl))
+	for id := range m.ttl {
+		status := m.statusLocked(id)
+		items = append(items, models.TaskSummary{TaskID: id, Status: status.Status, CreatedAt: m.created[id]})
+	}
+	m.mu.Unlock()
+
+	return paginate(items, filter)
+}
+
+// statusLocked builds the status of a known task; m.mu must be held.
+// A task is PENDING until it gets a worker slot, which sets its deadline, then ACTIVE.
+func (m *memoryClient) statusLocked(taskID string) *models.TaskStatusResponse {
 	res := m.tasks[taskID]
 
+	deadline, started := m.deadline[taskID]
+	if res == nil && started && time.Now().After(deadline) {
+		errMsg := "task did not complete before its deadline"
+		return &models.TaskStatusResponse{
+			TaskID: taskID,
+			Status: models.TaskStatusFailure,
+			Error:  &errMsg,
+		}
+	}
+
+	if res == nil && started {
+		return &models.TaskStatusResponse{
+			TaskID: taskID,
+			Status: models.TaskStatusActive,
+		}
+	}
+
 	if res == nil {
 		return &models.TaskStatusResponse{
 			TaskID: taskID,
-			Status: "PENDING",
-		}, nil
+			Status: models.TaskStatusPending,
+		}
 	}
 
 	return &models.TaskStatusResponse{
 		TaskID: taskID,
-		Status: "SUCCESS",
+		Status: models.TaskStatusSuccess,
 		Result: res,
-	}, nil
+	}
 }
@@ -0,0 +1,407 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sudo-tiz/dns-tester-go/internal/config"
+	"github.com/sudo-tiz/dns-tester-go/internal/models"
+	"github.com/sudo-tiz/dns-tester-go/internal/resolver"
+)
+
+func TestMemoryClientListTasks(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{}).(*memoryClient)
+
+	now := time.Now()
+	add := func(id string, res *models.DNSLookupResults, age time.Duration) {
+		m.tasks[id] = res
+		m.ttl[id] = now.Add(time.Hour)
+		m.deadline[id] = now.Add(time.Minute)
+		m.created[id] = now.Add(-age)
+	}
+	add("mem-old", &models.DNSLookupResults{}, 3*time.Second)
+	add("mem-running", nil, 2*time.Second)
+	add("mem-new", &models.DNSLookupResults{}, time.Second)
+	// Still waiting for a worker slot, so no deadline yet
+	m.tasks["mem-queued"] = nil
+	m.ttl["mem-queued"] = now.Add(time.Hour)
+	m.created["mem-queued"] = now.Add(-4 * time.Second)
+
+	list, err := m.ListTasks(context.Background(), TaskFilter{})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if got := ids(list.Tasks); got != "mem-newmem-runningmem-oldmem-queued" {
+		t.Errorf("Expected newest first, got %s", got)
+	}
+
+	list, err = m.ListTasks(context.Background(), TaskFilter{Status: "ACTIVE"})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(list.Tasks) != 1 || list.Tasks[0].TaskID != "mem-running" {
+		t.Errorf("Expected only the running task, got %+v", list.Tasks)
+	}
+
+	list, err = m.ListTasks(context.Background(), TaskFilter{Status: "PENDING"})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(list.Tasks) != 1 || list.Tasks[0].TaskID != "mem-queued" {
+		t.Errorf("Expected only the queued task, got %+v", list.Tasks)
+	}
+
+	list, err = m.ListTasks(context.Background(), TaskFilter{Status: "SUCCESS", Limit: 1})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(list.Tasks) != 1 || list.Tasks[0].TaskID != "mem-new" || list.NextCursor != "1" {
+		t.Errorf("Expected first SUCCESS page with cursor, got %+v (cursor %q)", list.Tasks, list.NextCursor)
+	}
+}
+
+func TestMemoryClientCanonicalStatuses(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{}).(*memoryClient)
+
+	now := time.Now()
+	for id, tt := range map[string]struct {
+		res      *models.DNSLookupResults
+		deadline time.Time
+	}{
+		"mem-done":    {&models.DNSLookupResults{}, now.Add(time.Minute)},
+		"mem-running": {nil, now.Add(time.Minute)},
+		"mem-expired": {nil, now.Add(-time.Second)},
+	} {
+		m.tasks[id] = tt.res
+		m.ttl[id] = now.Add(time.Hour)
+		m.deadline[id] = tt.deadline
+		m.created[id] = now
+	}
+	m.tasks["mem-queued"] = nil
+	m.ttl["mem-queued"] = now.Add(time.Hour)
+	m.created["mem-queued"] = now
+
+	want := map[string]string{
+		"mem-done":    models.TaskStatusSuccess,
+		"mem-running": models.TaskStatusActive,
+		"mem-queued":  models.TaskStatusPending,
+		"mem-expired": models.TaskStatusFailure,
+	}
+	for id, status := range want {
+		got, err := m.GetTaskStatus(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetTaskStatus(%s) failed: %v", id, err)
+		}
+		if got.Status != status {
+			t.Errorf("GetTaskStatus(%s) = %s, want %s", id, got.Status, status)
+		}
+	}
+
+	list, err := m.ListTasks(context.Background(), TaskFilter{})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	for _, task := range list.Tasks {
+		if !slices.Contains(models.TaskStatuses, task.Status) {
+			t.Errorf("ListTasks reported %s as %q, not a canonical status", task.TaskID, task.Status)
+		}
+	}
+}
+
+func TestMemoryClientTaskIDDedup(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{}).(*memoryClient)
+	task := models.TaskOptions{TaskID: "nightly-example-com"}
+
+	first, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", nil, models.QueryOptions{}, task, "")
+	if err != nil || first != task.TaskID {
+		t.Fatalf("Expected task %s, got %q (%v)", task.TaskID, first, err)
+	}
+	second, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", nil, models.QueryOptions{}, task, "")
+	if !errors.Is(err, ErrTaskExists) || second != first {
+		t.Fatalf("Expected ErrTaskExists for %s, got %q (%v)", first, second, err)
+	}
+
+	list, err := m.ListTasks(context.Background(), TaskFilter{})
+	if err != nil {
+		t.Fatalf("ListTasks failed: %v", err)
+	}
+	if len(list.Tasks) != 1 {
+		t.Errorf("Expected one task, got %+v", list.Tasks)
+	}
+
+	// Generated IDs never collide, even for back-to-back enqueues
+	a, errA := m.EnqueueDNSLookup(context.Background(), "example.com", "A", nil, models.QueryOptions{}, models.TaskOptions{}, "")
+	b, errB := m.EnqueueDNSLookup(context.Background(), "example.com", "A", nil, models.QueryOptions{}, models.TaskOptions{}, "")
+	if errA != nil || errB != nil || a == b {
+		t.Errorf("Expected two distinct generated IDs, got %q (%v) and %q (%v)", a, errA, b, errB)
+	}
+}
+
+func TestMemoryClientWorkerPool(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{Worker: config.WorkerConfig{MaxWorkers: 2}}).(*memoryClient)
+
+	var (
+		mu            sync.Mutex
+		running, peak int
+	)
+	started := make(chan struct{}, 5)
+	release := make(chan struct{})
+	m.resolver = resolver.ResolverFunc(func(_ context.Context, _, _ string, _ []models.DNSServer, _ models.QueryOptions, _ time.Duration, _, _ int) map[string]models.DNSLookupResult {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		started <- struct{}{}
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return map[string]models.DNSLookupResult{}
+	})
+
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	for i := 0; i < 5; i++ {
+		task := models.TaskOptions{TaskID: fmt.Sprintf("pool-%d", i)}
+		if _, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, task, ""); err != nil {
+			t.Fatalf("EnqueueDNSLookup failed: %v", err)
+		}
+	}
+
+	<-started
+	<-started
+	select {
+	case <-started:
+		t.Fatal("Expected a third task to wait for a free worker")
+	case <-time.After(100 * time.Millisecond):
+	}
+	depths, _ := m.QueueDepths(context.Background())
+	if depths["default"] != 3 {
+		t.Errorf("Expected 3 queued tasks, got %d", depths["default"])
+	}
+
+	// Tasks holding a worker slot are ACTIVE, the ones waiting for it PENDING
+	counts := make(map[string]int)
+	for i := 0; i < 5; i++ {
+		status, err := m.GetTaskStatus(context.Background(), fmt.Sprintf("pool-%d", i))
+		if err != nil {
+			t.Fatalf("GetTaskStatus failed: %v", err)
+		}
+		counts[status.Status]++
+	}
+	if counts[models.TaskStatusActive] != 2 || counts[models.TaskStatusPending] != 3 {
+		t.Errorf("Expected 2 ACTIVE and 3 PENDING tasks, got %v", counts)
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-started
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if peak != 2 {
+		t.Errorf("Expected at most 2 tasks running at once, got %d", peak)
+	}
+}
+
+// fakeResolver returns canned results once release is closed and records what it was asked
+type fakeResolver struct {
+	results map[string]models.DNSLookupResult
+	release chan struct{}
+
+	mu      sync.Mutex
+	domain  string
+	qtype   string
+	servers []models.DNSServer
+	timeout time.Duration
+	retries int
+}
+
+func (f *fakeResolver) RunQueries(_ context.Context, domain, qtype string, servers []models.DNSServer, _ models.QueryOptions, timeout time.Duration, _, maxRetries int) map[string]models.DNSLookupResult {
+	f.mu.Lock()
+	f.domain, f.qtype, f.servers, f.timeout, f.retries = domain, qtype, servers, timeout, maxRetries
+	f.mu.Unlock()
+	<-f.release
+	return f.results
+}
+
+func TestMemoryClientFakeResolver(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{DNS: config.DNSConfig{Timeout: 3, MaxRetries: 2}}).(*memoryClient)
+	fake := &fakeResolver{
+		results: map[string]models.DNSLookupResult{
+			"udp://192.0.2.1:53": {CommandStatus: resolver.CommandStatusOK, RCode: "NOERROR", Answers: []models.DNSAnswer{{Name: "example.com.", Type: "A", TTL: 300, Value: "93.184.216.34"}}},
+		},
+		release: make(chan struct{}),
+	}
+	m.resolver = fake
+
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	id, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{}, "")
+	if err != nil {
+		t.Fatalf("EnqueueDNSLookup failed: %v", err)
+	}
+
+	// The task turns ACTIVE once it holds a worker slot, and stays so while the resolver runs
+	status, err := m.GetTaskStatus(context.Background(), id)
+	deadline := time.Now().Add(2 * time.Second)
+	for err == nil && status.Status == models.TaskStatusPending && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		status, err = m.GetTaskStatus(context.Background(), id)
+	}
+	if err != nil {
+		t.Fatalf("GetTaskStatus failed: %v", err)
+	}
+	if status.Status != models.TaskStatusActive {
+		t.Errorf("Expected %s while the resolver runs, got %s", models.TaskStatusActive, status.Status)
+	}
+
+	close(fake.release)
+	deadline = time.Now().Add(2 * time.Second)
+	for status.Status != models.TaskStatusSuccess && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		if status, err = m.GetTaskStatus(context.Background(), id); err != nil {
+			t.Fatalf("GetTaskStatus failed: %v", err)
+		}
+	}
+	if status.Status != models.TaskStatusSuccess {
+		t.Fatalf("Expected %s, got %s", models.TaskStatusSuccess, status.Status)
+	}
+	got := status.Result.Details["udp://192.0.2.1:53"]
+	if got.RCode != "NOERROR" || len(got.Answers) != 1 || got.Answers[0].Value != "93.184.216.34" {
+		t.Errorf("Expected the canned result, got %+v", status.Result.Details)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.domain != "example.com" || fake.qtype != "A" || len(fake.servers) != 1 {
+		t.Errorf("Resolver got %s %s %+v", fake.domain, fake.qtype, fake.servers)
+	}
+	if fake.timeout != 3*time.Second || fake.retries != 2 {
+		t.Errorf("Expected timeout 3s and 2 retries, got %s and %d", fake.timeout, fake.retries)
+	}
+}
+
+func TestMemoryClientTaskTimeout(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{DNS: config.DNSConfig{Timeout: 5, MaxRetries: 3, TaskTimeout: 2}}).(*memoryClient)
+
+	deadlines := make(chan time.Duration, 1)
+	m.resolver = resolver.ResolverFunc(func(ctx context.Context, _, _ string, _ []models.DNSServer, _ models.QueryOptions, _ time.Duration, _, _ int) map[string]models.DNSLookupResult {
+		deadline, _ := ctx.Deadline()
+		deadlines <- time.Until(deadline)
+		return map[string]models.DNSLookupResult{}
+	})
+
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	if _, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{}, ""); err != nil {
+		t.Fatalf("EnqueueDNSLookup failed: %v", err)
+	}
+	// Four 5s attempts would take 20s; dns.task_timeout cuts the task at 2s
+	if left := <-deadlines; left <= 0 || left > 2*time.Second {
+		t.Errorf("Expected the task deadline within dns.task_timeout, got %s", left)
+	}
+}
+
+func TestMemoryClientCloseCancelsQueries(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{}).(*memoryClient)
+
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	m.resolver = resolver.ResolverFunc(func(ctx context.Context, _, _ string, _ []models.DNSServer, _ models.QueryOptions, _ time.Duration, _, _ int) map[string]models.DNSLookupResult {
+		close(started)
+		select {
+		case <-ctx.Done():
+			cancelled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			cancelled <- nil
+		}
+		return map[string]models.DNSLookupResult{}
+	})
+
+	// The request context ending must not stop the task
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	if _, err := m.EnqueueDNSLookup(reqCtx, "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{}, ""); err != nil {
+		t.Fatalf("EnqueueDNSLookup failed: %v", err)
+	}
+	<-started
+	cancelReq()
+	select {
+	case err := <-cancelled:
+		t.Fatalf("Expected the query to outlive the request, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := <-cancelled; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected Close to cancel the in-flight query, got %v", err)
+	}
+}
+
+func TestMemoryClientCloseDropsQueuedTasks(t *testing.T) {
+	m := NewMemoryClient(&config.APIConfig{Worker: config.WorkerConfig{MaxWorkers: 1}}).(*memoryClient)
+
+	var calls atomic.Int32
+	started := make(chan struct{}, 2)
+	m.resolver = resolver.ResolverFunc(func(ctx context.Context, _, _ string, _ []models.DNSServer, _ models.QueryOptions, _ time.Duration, _, _ int) map[string]models.DNSLookupResult {
+		calls.Add(1)
+		started <- struct{}{}
+		<-ctx.Done()
+		return map[string]models.DNSLookupResult{}
+	})
+
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	for i := 0; i < 2; i++ {
+		if _, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{}, ""); err != nil {
+			t.Fatalf("EnqueueDNSLookup failed: %v", err)
+		}
+	}
+	<-started
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	select {
+	case <-started:
+		t.Fatal("Expected the queued task to be dropped after Close")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Expected only the running task to reach the resolver, got %d calls", got)
+	}
+}
+
+func TestMemoryClientSetConfig(t *testing.T) {
+	client := NewMemoryClient(&config.APIConfig{DNS: config.DNSConfig{Timeout: 3, MaxRetries: 2}})
+	m := client.(*memoryClient)
+	fake := &fakeResolver{release: make(chan struct{})}
+	close(fake.release)
+	m.resolver = fake
+
+	client.(ConfigReloader).SetConfig(&config.APIConfig{DNS: config.DNSConfig{Timeout: 7, MaxRetries: 1}})
+
+	servers := []models.DNSServer{{Target: "udp://192.0.2.1:53"}}
+	id, err := m.EnqueueDNSLookup(context.Background(), "example.com", "A", servers, models.QueryOptions{}, models.TaskOptions{}, "")
+	if err != nil {
+		t.Fatalf("EnqueueDNSLookup failed: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if status, _ := m.GetTaskStatus(context.Background(), id); status.Status == models.TaskStatusSuccess {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.timeout != 7*time.Second || fake.retries != 1 {
+		t.Errorf("Expected the reloaded timeout 7s and 1 retry, got %s and %d", fake.timeout, fake.retries)
+	}
+}
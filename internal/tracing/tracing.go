@@ -0,0 +1,71 @@
+// Package tracing wires OpenTelemetry spans across API, task queue and resolver.
+// Exporting is opt-in via the standard OTEL_EXPORTER_OTLP_* variables; otherwise spans are no-ops.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies spans created by this module.
+const instrumentationName = "github.com/sudo-tiz/dns-tester-go"
+
+// propagator carries W3C traceparent through task payloads, independent of the global one.
+var propagator = propagation.TraceContext{}
+
+// Setup installs an OTLP/HTTP tracer provider when an endpoint is configured.
+// The exporter reads OTEL_EXPORTER_OTLP_* itself; the returned shutdown flushes pending spans.
+func Setup(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create OTLP exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", serviceName))
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+// Tracer returns the module tracer from the global provider (no-op unless Setup exported).
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Inject serializes the span context in ctx (traceparent) for a task payload.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+	return carrier
+}
+
+// Extract restores a span context serialized by Inject into ctx.
+func Extract(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.MapCarrier(carrier))
+}
+
+// Detach returns base carrying the span of ctx as parent, without ctx's deadline or cancellation.
+// Used for work that outlives the HTTP request, like in-memory tasks.
+func Detach(base, ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(base, trace.SpanContextFromContext(ctx))
+}
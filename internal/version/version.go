@@ -0,0 +1,67 @@
+// Package version reports build information stamped in at link time.
+//
+//	go build -ldflags "-X github.com/sudo-tiz/dns-tester-go/internal/version.Version=1.2.0 \
+//	  -X github.com/sudo-tiz/dns-tester-go/internal/version.GitCommit=$(git rev-parse --short HEAD) \
+//	  -X github.com/sudo-tiz/dns-tester-go/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package version
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags -X; empty values fall back to the caller's default and the VCS stamp of go build.
+var (
+	Version   string
+	GitCommit string
+	BuildDate string
+)
+
+// Unknown is reported for build details that were neither stamped nor recorded by go build.
+const Unknown = "unknown"
+
+// Info describes the running build.
+type Info struct {
+	Version   string `json:"version" example:"1.0.0"`                   // Release version
+	GitCommit string `json:"git_commit" example:"2f6fce7"`              // Source commit
+	BuildDate string `json:"build_date" example:"2026-10-16T09:00:00Z"` // Build time (UTC, RFC 3339)
+	GoVersion string `json:"go_version" example:"go1.25.4"`             // Go toolchain
+}
+
+// Get returns the build information; defaultVersion is used when Version was not stamped.
+func Get(defaultVersion string) Info {
+	info := Info{
+		Version:   Version,
+		GitCommit: GitCommit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info.Version == "" {
+		info.Version = defaultVersion
+	}
+
+	// go build records the VCS state when built from a checkout
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.GitCommit == "":
+				info.GitCommit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+	if info.GitCommit == "" {
+		info.GitCommit = Unknown
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = Unknown
+	}
+	return info
+}
+
+// String formats the info for --version output.
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, built %s, %s)", i.Version, i.GitCommit, i.BuildDate, i.GoVersion)
+}
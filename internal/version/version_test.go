@@ -0,0 +1,32 @@
+package version
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	info := Get("1.0.0")
+	if info.Version != "1.0.0" {
+		t.Errorf("Expected default version when not stamped, got %q", info.Version)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if info.GitCommit == "" || info.BuildDate == "" {
+		t.Errorf("Expected commit and build date to fall back to %q, got %+v", Unknown, info)
+	}
+
+	prev := Version
+	Version, GitCommit = "2.1.0", "abc1234"
+	defer func() { Version, GitCommit = prev, "" }()
+
+	info = Get("1.0.0")
+	if info.Version != "2.1.0" || info.GitCommit != "abc1234" {
+		t.Errorf("Expected stamped values to win, got %+v", info)
+	}
+	if s := info.String(); !strings.HasPrefix(s, "2.1.0 (commit abc1234, built ") {
+		t.Errorf("Unexpected String() = %q", s)
+	}
+}